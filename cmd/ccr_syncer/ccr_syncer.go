@@ -52,19 +52,25 @@ type Syncer struct {
 	Db_user     string
 	Db_password string
 	Db_name     string
+	Db_tls      bool
+	Db_tls_ca   string
+	Db_tls_cert string
+	Db_tls_key  string
 	Pprof       bool
 	Ppof_port   int
 	Config_file string
 }
 
 var (
-	dbPath       string
-	syncer       Syncer
-	printVersion bool
+	dbPath           string
+	syncer           Syncer
+	printVersion     bool
+	reencryptSecrets bool
 )
 
 func init() {
 	flag.BoolVar(&printVersion, "version", false, "The program's version")
+	flag.BoolVar(&reencryptSecrets, "reencrypt_secrets", false, "encrypt the passwords of all jobs by the primary secret key, then exit")
 	flag.StringVar(&dbPath, "db_dir", "ccr.db", "sqlite3 db file")
 	flag.StringVar(&syncer.Db_type, "db_type", "sqlite3", "meta db type")
 	flag.StringVar(&syncer.Db_host, "db_host", "127.0.0.1", "meta db host")
@@ -72,6 +78,10 @@ func init() {
 	flag.StringVar(&syncer.Db_user, "db_user", "root", "meta db user")
 	flag.StringVar(&syncer.Db_password, "db_password", "", "meta db password")
 	flag.StringVar(&syncer.Db_name, "db_name", "ccr", "meta db name")
+	flag.BoolVar(&syncer.Db_tls, "db_tls", false, "connect to the meta db with tls")
+	flag.StringVar(&syncer.Db_tls_ca, "db_tls_ca", "", "the ca file to verify the meta db, implies db_tls")
+	flag.StringVar(&syncer.Db_tls_cert, "db_tls_cert", "", "the client cert file to connect to the meta db, implies db_tls")
+	flag.StringVar(&syncer.Db_tls_key, "db_tls_key", "", "the client key file to connect to the meta db, implies db_tls")
 	// default value of config_file is empty
 	flag.StringVar(&syncer.Config_file, "config_file", "", "meta data configuration")
 
@@ -81,6 +91,18 @@ func init() {
 	flag.BoolVar(&syncer.Pprof, "pprof", false, "use pprof or not")
 }
 
+// the tls config of the meta db, nil if the tls is not enabled
+func metaDBTLSConfig() *utils.TLSConfig {
+	if !syncer.Db_tls && syncer.Db_tls_ca == "" && syncer.Db_tls_cert == "" && syncer.Db_tls_key == "" {
+		return nil
+	}
+	return &utils.TLSConfig{
+		CA:   syncer.Db_tls_ca,
+		Cert: syncer.Db_tls_cert,
+		Key:  syncer.Db_tls_key,
+	}
+}
+
 func parseConfigFile() error {
 	file, err := os.Open(syncer.Config_file)
 	if err != nil {
@@ -140,6 +162,10 @@ func main() {
 		}
 	}
 
+	if err := base.InitSecretKeyring(); err != nil {
+		log.Fatalf("init secret keyring error: %+v", err)
+	}
+
 	// Step 1: Check db
 	var db storage.DB
 	var err error
@@ -150,9 +176,9 @@ func main() {
 		}
 		db, err = storage.NewSQLiteDB(dbPath)
 	case "mysql":
-		db, err = storage.NewMysqlDB(syncer.Db_host, syncer.Db_port, syncer.Db_user, syncer.Db_password, syncer.Db_name)
+		db, err = storage.NewMysqlDB(syncer.Db_host, syncer.Db_port, syncer.Db_user, syncer.Db_password, syncer.Db_name, metaDBTLSConfig())
 	case "postgresql":
-		db, err = storage.NewPostgresqlDB(syncer.Db_host, syncer.Db_port, syncer.Db_user, syncer.Db_password, syncer.Db_name)
+		db, err = storage.NewPostgresqlDB(syncer.Db_host, syncer.Db_port, syncer.Db_user, syncer.Db_password, syncer.Db_name, metaDBTLSConfig())
 	default:
 		err = xerror.Wrap(err, xerror.Normal, "new meta db failed.")
 	}
@@ -160,6 +186,15 @@ func main() {
 		log.Fatalf("new meta db error: %+v", err)
 	}
 
+	if reencryptSecrets {
+		count, err := ccr.ReencryptJobSecrets(db)
+		if err != nil {
+			log.Fatalf("reencrypt secrets error: %+v", err)
+		}
+		log.Infof("reencrypt the secrets of %d jobs", count)
+		os.Exit(0)
+	}
+
 	// Step 2: init factory
 	factory := ccr.NewFactory(rpc.NewRpcFactory(), ccr.NewMetaFactory(), base.NewSpecerFactory(), ccr.DefaultThriftMetaFactory)
 
@@ -16,7 +16,11 @@
 // under the License
 package base
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/selectdb/ccr_syncer/pkg/utils"
+)
 
 type Backend struct {
 	Id       int64
@@ -24,6 +28,9 @@ type Backend struct {
 	BePort   uint16
 	HttpPort uint16
 	BrpcPort uint16
+
+	// The tls config of the thrift connections, inherits from the spec of the cluster.
+	ThriftTLS *utils.TLSConfig
 }
 
 // Backend Stringer
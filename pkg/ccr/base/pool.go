@@ -18,6 +18,7 @@ package base
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"flag"
 	"sync"
 	"time"
@@ -70,15 +71,33 @@ func GetMysqlDB(dsn string) (*sql.DB, error) {
 	if db, err := sql.Open("mysql", dsn); err != nil {
 		return nil, xerror.Wrapf(err, xerror.DB, "connect to mysql failed, dsn: %s", dsn)
 	} else {
-		db.SetMaxOpenConns(MaxOpenConns)
-		if MaxOpenConns > 0 {
-			db.SetMaxIdleConns(MaxOpenConns / 4)
-		} else {
-			db.SetMaxIdleConns(DefaultMaxIdleConns)
-		}
-		db.SetConnMaxLifetime(MaxConnLifeTime)
-
+		setMysqlDBOptions(db)
 		cachedSqlDbPool.pool[dsn] = db
 		return db, nil
 	}
 }
+
+// GetMysqlDBWithConnector get mysql db connection by the connector, cached via the key.
+func GetMysqlDBWithConnector(key string, connector driver.Connector) *sql.DB {
+	cachedSqlDbPool.mu.Lock()
+	defer cachedSqlDbPool.mu.Unlock()
+
+	if db, ok := cachedSqlDbPool.pool[key]; ok {
+		return db
+	}
+
+	db := sql.OpenDB(connector)
+	setMysqlDBOptions(db)
+	cachedSqlDbPool.pool[key] = db
+	return db
+}
+
+func setMysqlDBOptions(db *sql.DB) {
+	db.SetMaxOpenConns(MaxOpenConns)
+	if MaxOpenConns > 0 {
+		db.SetMaxIdleConns(MaxOpenConns / 4)
+	} else {
+		db.SetMaxIdleConns(DefaultMaxIdleConns)
+	}
+	db.SetConnMaxLifetime(MaxConnLifeTime)
+}
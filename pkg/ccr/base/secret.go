@@ -0,0 +1,323 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package base
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RedactedSecret replaces the secrets in the responses and the logs.
+const RedactedSecret = "******"
+
+const (
+	encryptedSecretPrefix = "enc:v1:"
+	secretKeyEnv          = "CCR_SECRET_KEY"
+	secretKeySize         = 32
+)
+
+var (
+	secretKeyFile string
+
+	// The keyring to encrypt the passwords of the specs at rest, nil means plaintext.
+	secretKeyring *SecretKeyring
+)
+
+func init() {
+	flag.StringVar(&secretKeyFile, "secret_key_file", "",
+		"the file of the master keys to encrypt the passwords of the job specs, one base64 encoded 32 bytes key per line, "+
+			"the first key encrypts and all keys decrypt; the comma separated keys in env "+secretKeyEnv+" are used if it is empty")
+}
+
+type secretKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// SecretKeyring encrypts the secrets by envelope encryption: each secret is encrypted by a
+// random data key, and the data key is encrypted by the primary master key. The other master
+// keys are only used to decrypt, so the keys could be rotated.
+type SecretKeyring struct {
+	keys []*secretKey
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "new aes cipher failed")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "new gcm failed")
+	}
+	return aead, nil
+}
+
+// NewSecretKeyring creates the keyring by the master keys, the first one is the primary key.
+func NewSecretKeyring(keys [][]byte) (*SecretKeyring, error) {
+	if len(keys) == 0 {
+		return nil, xerror.New(xerror.Normal, "no secret key")
+	}
+
+	keyring := &SecretKeyring{}
+	for _, key := range keys {
+		if len(key) != secretKeySize {
+			return nil, xerror.Errorf(xerror.Normal, "the secret key must be %d bytes, but got %d", secretKeySize, len(key))
+		}
+		aead, err := newAead(key)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		keyring.keys = append(keyring.keys, &secretKey{id: hex.EncodeToString(sum[:4]), aead: aead})
+	}
+	return keyring, nil
+}
+
+func parseSecretKeys(data string, sep string) ([][]byte, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(data, sep) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, xerror.Wrap(err, xerror.Normal, "decode the secret key failed")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// InitSecretKeyring loads the master keys from the key file or the env, the passwords are
+// stored in plaintext if no key is configured.
+func InitSecretKeyring() error {
+	var keys [][]byte
+	var err error
+	if secretKeyFile != "" {
+		data, readErr := os.ReadFile(secretKeyFile)
+		if readErr != nil {
+			return xerror.Wrapf(readErr, xerror.Normal, "read secret key file %s failed", secretKeyFile)
+		}
+		keys, err = parseSecretKeys(string(data), "\n")
+	} else if env := os.Getenv(secretKeyEnv); env != "" {
+		keys, err = parseSecretKeys(env, ",")
+	} else {
+		log.Warnf("no secret key is configured, the passwords of the job specs are stored in plaintext")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	keyring, err := NewSecretKeyring(keys)
+	if err != nil {
+		return err
+	}
+	SetSecretKeyring(keyring)
+	log.Infof("load %d secret keys, the primary key id: %s", len(keyring.keys), keyring.keys[0].id)
+	return nil
+}
+
+func SetSecretKeyring(keyring *SecretKeyring) {
+	secretKeyring = keyring
+}
+
+func IsEncryptedSecret(secret string) bool {
+	return strings.HasPrefix(secret, encryptedSecretPrefix)
+}
+
+// Encrypt the secret, the result is "enc:v1:<key id>:<encrypted data key>:<encrypted secret>".
+func (k *SecretKeyring) Encrypt(secret string) (string, error) {
+	dataKey := make([]byte, secretKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", xerror.Wrap(err, xerror.Normal, "generate data key failed")
+	}
+	dataAead, err := newAead(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	primary := k.keys[0]
+	encryptedKey, err := seal(primary.aead, dataKey, []byte(primary.id))
+	if err != nil {
+		return "", err
+	}
+	encryptedSecret, err := seal(dataAead, []byte(secret), nil)
+	if err != nil {
+		return "", err
+	}
+	return encryptedSecretPrefix + primary.id + ":" +
+		base64.StdEncoding.EncodeToString(encryptedKey) + ":" +
+		base64.StdEncoding.EncodeToString(encryptedSecret), nil
+}
+
+func (k *SecretKeyring) Decrypt(encrypted string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(encrypted, encryptedSecretPrefix), ":")
+	if len(parts) != 3 {
+		return "", xerror.New(xerror.Normal, "invalid encrypted secret")
+	}
+
+	var key *secretKey
+	for _, candidate := range k.keys {
+		if candidate.id == parts[0] {
+			key = candidate
+			break
+		}
+	}
+	if key == nil {
+		return "", xerror.Errorf(xerror.Normal, "the secret key %s is not found", parts[0])
+	}
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", xerror.Wrap(err, xerror.Normal, "decode the encrypted data key failed")
+	}
+	encryptedSecret, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", xerror.Wrap(err, xerror.Normal, "decode the encrypted secret failed")
+	}
+
+	dataKey, err := open(key.aead, encryptedKey, []byte(key.id))
+	if err != nil {
+		return "", err
+	}
+	dataAead, err := newAead(dataKey)
+	if err != nil {
+		return "", err
+	}
+	secret, err := open(dataAead, encryptedSecret, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// Whether the secret is encrypted by the primary key.
+func (k *SecretKeyring) isPrimary(encrypted string) bool {
+	return strings.HasPrefix(encrypted, encryptedSecretPrefix+k.keys[0].id+":")
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "generate nonce failed")
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, xerror.New(xerror.Normal, "invalid ciphertext")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "decrypt failed")
+	}
+	return plaintext, nil
+}
+
+// The alias without the json methods of Spec.
+type specJson Spec
+
+// MarshalJSON encrypts the password if the keyring is configured, or redacts it if the spec
+// is redacted. The secret reference is not encrypted.
+func (s Spec) MarshalJSON() ([]byte, error) {
+	spec := specJson(s)
+	if s.redacted {
+		spec.Password = RedactedSecret
+	} else if password, err := EncryptSecret(spec.Password); err != nil {
+		return nil, err
+	} else {
+		spec.Password = password
+	}
+	return json.Marshal(spec)
+}
+
+// UnmarshalJSON decrypts the password, the plaintext password is accepted too.
+func (s *Spec) UnmarshalJSON(data []byte) error {
+	var spec specJson
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	*s = Spec(spec)
+
+	password, stale, err := DecryptSecret(s.Password)
+	if err != nil {
+		return err
+	}
+	s.Password = password
+	s.staleSecret = stale
+	return nil
+}
+
+// EncryptSecret encrypts the secret to store it at rest, the secret is kept as it is if no
+// keyring is configured, or it is empty or a secret reference.
+func EncryptSecret(secret string) (string, error) {
+	if secret == "" || secretKeyring == nil || IsSecretRef(secret) {
+		return secret, nil
+	}
+	return secretKeyring.Encrypt(secret)
+}
+
+// DecryptSecret decrypts the secret encrypted by EncryptSecret, the plaintext secret is accepted
+// too. Returns whether the secret is stale, that is stored in plaintext or encrypted by a
+// non-primary key, so it should be stored again.
+func DecryptSecret(secret string) (string, bool, error) {
+	if !IsEncryptedSecret(secret) {
+		return secret, secret != "" && secretKeyring != nil && !IsSecretRef(secret), nil
+	}
+	if secretKeyring == nil {
+		return "", false, xerror.New(xerror.Normal, "the secret is encrypted, but no secret key is configured")
+	}
+	decrypted, err := secretKeyring.Decrypt(secret)
+	if err != nil {
+		return "", false, err
+	}
+	return decrypted, !secretKeyring.isPrimary(secret), nil
+}
+
+// Whether the password is stored in plaintext or encrypted by a non-primary key, so it should
+// be stored again.
+func (s *Spec) IsSecretStale() bool {
+	return s.staleSecret
+}
+
+// Redact hides the password, for the responses and the logs. The secret reference is kept,
+// since it is not the secret itself.
+func (s *Spec) Redact() {
+	if IsSecretRef(s.Password) {
+		return
+	}
+	s.Password = ""
+	s.redacted = true
+}
@@ -0,0 +1,305 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package base
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The mysql error number of the access denied.
+const mysqlErrAccessDenied = 1045
+
+var (
+	secretRefCacheTTL time.Duration
+	secretFileDirs    string
+	secretEnvPrefixes string
+)
+
+func init() {
+	flag.DurationVar(&secretRefCacheTTL, "secret_ref_cache_ttl", 5*time.Minute,
+		"the duration to cache the resolved secret references of the spec user and password, "+
+			"they are resolved again once the authentication fails")
+	flag.StringVar(&secretFileDirs, "secret_file_dirs", "",
+		"the comma separated dirs allowed to be read by the file:// secret references, empty means the file:// is disabled")
+	flag.StringVar(&secretEnvPrefixes, "secret_env_prefixes", "",
+		"the comma separated name prefixes of the envs allowed to be read by the env: secret references, "+
+			"empty means the env: is disabled")
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SecretProvider resolves the secret references of a scheme, the reference is
+// "<scheme>:<path>", such as "file:///run/secrets/doris_src" and "env:DORIS_DEST_PW".
+type SecretProvider interface {
+	// Resolve the secret by the path of the reference.
+	Resolve(path string) (string, error)
+}
+
+// fileSecretProvider reads the secret files under the dirs of --secret_file_dirs, the symlinks
+// are resolved before checking, and the master key file is never read.
+type fileSecretProvider struct{}
+
+func (p *fileSecretProvider) Resolve(path string) (string, error) {
+	file, err := filepath.Abs(strings.TrimPrefix(path, "//"))
+	if err != nil {
+		return "", xerror.Wrapf(err, xerror.Normal, "invalid secret file %s", path)
+	}
+	if file, err = filepath.EvalSymlinks(file); err != nil {
+		return "", xerror.Wrapf(err, xerror.Normal, "read secret file %s failed", path)
+	}
+	if !isSecretFileAllowed(file) {
+		return "", xerror.Errorf(xerror.Normal, "the secret file %s is not under --secret_file_dirs", path)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", xerror.Wrapf(err, xerror.Normal, "read secret file %s failed", path)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func isSecretFileAllowed(file string) bool {
+	if secretKeyFile != "" {
+		if keyFile, err := filepath.Abs(secretKeyFile); err == nil {
+			if resolved, err := filepath.EvalSymlinks(keyFile); err == nil {
+				keyFile = resolved
+			}
+			if keyFile == file {
+				return false
+			}
+		}
+	}
+
+	for _, dir := range splitList(secretFileDirs) {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// envSecretProvider reads the envs with the prefixes of --secret_env_prefixes, the env of the
+// master keys is never read.
+type envSecretProvider struct{}
+
+func (p *envSecretProvider) Resolve(path string) (string, error) {
+	if !isSecretEnvAllowed(path) {
+		return "", xerror.Errorf(xerror.Normal, "the secret env %s does not match --secret_env_prefixes", path)
+	}
+	if value, ok := os.LookupEnv(path); ok {
+		return value, nil
+	}
+	return "", xerror.Errorf(xerror.Normal, "the secret env %s is not set", path)
+}
+
+func isSecretEnvAllowed(name string) bool {
+	if name == secretKeyEnv {
+		return false
+	}
+	for _, prefix := range splitList(secretEnvPrefixes) {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type resolvedSecret struct {
+	value      string
+	resolvedAt time.Time
+}
+
+var (
+	secretProvidersLock sync.RWMutex
+	secretProviders     = map[string]SecretProvider{
+		"file": &fileSecretProvider{},
+		"env":  &envSecretProvider{},
+	}
+
+	resolvedSecretsLock sync.Mutex
+	resolvedSecrets     = make(map[string]*resolvedSecret)
+)
+
+// RegisterSecretProvider registers the provider of the scheme, such as a vault-style backend.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+
+	secretProviders[scheme] = provider
+}
+
+func getSecretProvider(value string) (SecretProvider, string) {
+	scheme, path, found := strings.Cut(value, ":")
+	if !found {
+		return nil, ""
+	}
+
+	secretProvidersLock.RLock()
+	defer secretProvidersLock.RUnlock()
+
+	return secretProviders[scheme], path
+}
+
+// Whether the value is a reference of a registered secret provider.
+func IsSecretRef(value string) bool {
+	provider, _ := getSecretProvider(value)
+	return provider != nil
+}
+
+// Resolve the value if it is a secret reference, the resolved secret is cached.
+func resolveSecret(value string) (string, error) {
+	provider, path := getSecretProvider(value)
+	if provider == nil {
+		return value, nil
+	}
+
+	resolvedSecretsLock.Lock()
+	defer resolvedSecretsLock.Unlock()
+
+	if secret, ok := resolvedSecrets[value]; ok && time.Since(secret.resolvedAt) < secretRefCacheTTL {
+		return secret.value, nil
+	}
+	secret, err := provider.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	resolvedSecrets[value] = &resolvedSecret{value: secret, resolvedAt: time.Now()}
+	return secret, nil
+}
+
+func forgetSecrets(values ...string) {
+	resolvedSecretsLock.Lock()
+	defer resolvedSecretsLock.Unlock()
+
+	for _, value := range values {
+		delete(resolvedSecrets, value)
+	}
+}
+
+// Whether the user or the password of the spec is a secret reference.
+func (s *Spec) HasSecretRef() bool {
+	return IsSecretRef(s.User) || IsSecretRef(s.Password)
+}
+
+// Credentials resolves the user and the password of the spec, which might be secret references.
+func (s *Spec) Credentials() (string, string, error) {
+	user, err := resolveSecret(s.User)
+	if err != nil {
+		return "", "", err
+	}
+	password, err := resolveSecret(s.Password)
+	if err != nil {
+		return "", "", err
+	}
+	return user, password, nil
+}
+
+// RefreshCredentials drops the resolved secrets of the spec, such as after the authentication
+// fails, so the rotated secrets are resolved next time.
+func (s *Spec) RefreshCredentials() {
+	if s.HasSecretRef() {
+		log.Infof("refresh the secret references of spec %s", s)
+		forgetSecrets(s.User, s.Password)
+	}
+}
+
+// The connector resolves the secret references for each new connection, and resolves them
+// again if the access is denied.
+type secretConnector struct {
+	user     string
+	password string
+	addr     string
+	tlsName  string // the name of the registered mysql tls config
+}
+
+func (c *secretConnector) connect(ctx context.Context) (driver.Conn, error) {
+	user, err := resolveSecret(c.user)
+	if err != nil {
+		return nil, err
+	}
+	password, err := resolveSecret(c.password)
+	if err != nil {
+		return nil, err
+	}
+
+	config := mysql.NewConfig()
+	config.User = user
+	config.Passwd = password
+	config.Net = "tcp"
+	config.Addr = c.addr
+	config.TLSConfig = c.tlsName
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *secretConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connect(ctx)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrAccessDenied {
+		log.Warnf("access denied by %s, resolve the secret references again", c.addr)
+		forgetSecrets(c.user, c.password)
+		conn, err = c.connect(ctx)
+	}
+	return conn, err
+}
+
+func (c *secretConnector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
+
+func newSecretConnector(s *Spec, tlsName string) driver.Connector {
+	return &secretConnector{
+		user:     s.User,
+		password: s.Password,
+		addr:     net.JoinHostPort(s.Host, s.Port),
+		tlsName:  tlsName,
+	}
+}
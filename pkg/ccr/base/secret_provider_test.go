@@ -0,0 +1,114 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package base_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+)
+
+type staticSecretProvider map[string]string
+
+func (p staticSecretProvider) Resolve(path string) (string, error) {
+	return p[path], nil
+}
+
+func TestSpecCredentials(t *testing.T) {
+	secretDir := t.TempDir()
+	secretFile := filepath.Join(secretDir, "doris_src")
+	if err := os.WriteFile(secretFile, []byte("file_password\n"), 0600); err != nil {
+		t.Fatalf("write secret file failed: %v", err)
+	}
+	t.Setenv("DORIS_DEST_PW", "env_password")
+	setFlag(t, "secret_file_dirs", secretDir)
+	setFlag(t, "secret_env_prefixes", "DORIS_")
+	base.RegisterSecretProvider("vault", staticSecretProvider{"doris/user": "vault_user"})
+
+	spec := base.Spec{User: "vault:doris/user", Password: "file://" + secretFile}
+	user, password, err := spec.Credentials()
+	if err != nil {
+		t.Fatalf("resolve credentials failed: %v", err)
+	}
+	if user != "vault_user" || password != "file_password" {
+		t.Fatalf("unexpected credentials, user: %s, password: %s", user, password)
+	}
+
+	// the rotated secret is resolved after refreshing
+	if err := os.WriteFile(secretFile, []byte("rotated_password"), 0600); err != nil {
+		t.Fatalf("write secret file failed: %v", err)
+	}
+	if _, password, _ = spec.Credentials(); password != "file_password" {
+		t.Fatalf("the resolved secret should be cached, password: %s", password)
+	}
+	spec.RefreshCredentials()
+	if _, password, _ = spec.Credentials(); password != "rotated_password" {
+		t.Fatalf("the rotated secret should be resolved, password: %s", password)
+	}
+
+	spec = base.Spec{User: "root", Password: "env:DORIS_DEST_PW"}
+	if user, password, err = spec.Credentials(); err != nil || user != "root" || password != "env_password" {
+		t.Fatalf("unexpected credentials, user: %s, password: %s, err: %v", user, password, err)
+	}
+
+	spec = base.Spec{User: "root", Password: "env:DORIS_NOT_SET_PW"}
+	if _, _, err = spec.Credentials(); err == nil {
+		t.Fatalf("the unset env should fail")
+	}
+
+	// the secrets out of the allowlists and the master keys are never resolved
+	otherFile := filepath.Join(filepath.Dir(secretDir), "other")
+	if err := os.WriteFile(otherFile, []byte("other"), 0600); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	keyFile := filepath.Join(secretDir, "secret.key")
+	if err := os.WriteFile(keyFile, []byte("key"), 0600); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	setFlag(t, "secret_key_file", keyFile)
+	t.Setenv("OTHER_PW", "other")
+	t.Setenv("CCR_SECRET_KEY", "key")
+	setFlag(t, "secret_env_prefixes", "DORIS_,OTHER_PW_,CCR_")
+	for _, password := range []string{
+		"file://" + otherFile,
+		"file://" + secretDir + "/../other",
+		"file://" + keyFile,
+		"env:OTHER_PW",
+		"env:CCR_SECRET_KEY",
+	} {
+		spec = base.Spec{User: "root", Password: password}
+		if _, _, err = spec.Credentials(); err == nil {
+			t.Fatalf("the secret %s should be rejected", password)
+		}
+	}
+
+	spec = base.Spec{User: "root", Password: "plain:password"}
+	if spec.HasSecretRef() {
+		t.Fatalf("the unknown scheme should not be a secret reference")
+	}
+}
+
+func setFlag(t *testing.T, name, value string) {
+	saved := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatalf("set flag %s failed: %v", name, err)
+	}
+	t.Cleanup(func() { flag.Set(name, saved) })
+}
@@ -0,0 +1,104 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package base_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+)
+
+func TestSpecSecretEncryption(t *testing.T) {
+	defer base.SetSecretKeyring(nil)
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	oldKeyring, err := base.NewSecretKeyring([][]byte{oldKey})
+	if err != nil {
+		t.Fatalf("new keyring failed: %v", err)
+	}
+	base.SetSecretKeyring(oldKeyring)
+
+	spec := base.Spec{User: "root", Password: "secret_password", Database: "db"}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("marshal spec failed: %v", err)
+	}
+	if strings.Contains(string(data), spec.Password) || !strings.Contains(string(data), "enc:v1:") {
+		t.Fatalf("the password should be encrypted: %s", data)
+	}
+
+	var decoded base.Spec
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal spec failed: %v", err)
+	}
+	if decoded.Password != spec.Password || decoded.IsSecretStale() {
+		t.Fatalf("the password should be decrypted, password: %s, stale: %v", decoded.Password, decoded.IsSecretStale())
+	}
+
+	// rotate the key, the secret encrypted by the old key is stale
+	newKeyring, err := base.NewSecretKeyring([][]byte{newKey, oldKey})
+	if err != nil {
+		t.Fatalf("new keyring failed: %v", err)
+	}
+	base.SetSecretKeyring(newKeyring)
+	decoded = base.Spec{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal spec failed: %v", err)
+	}
+	if decoded.Password != spec.Password || !decoded.IsSecretStale() {
+		t.Fatalf("the password encrypted by the old key should be stale, password: %s", decoded.Password)
+	}
+
+	// the plaintext password is accepted but stale
+	decoded = base.Spec{}
+	if err := json.Unmarshal([]byte(`{"user": "root", "password": "plain"}`), &decoded); err != nil {
+		t.Fatalf("unmarshal spec failed: %v", err)
+	}
+	if decoded.Password != "plain" || !decoded.IsSecretStale() {
+		t.Fatalf("the plaintext password should be stale, password: %s", decoded.Password)
+	}
+
+	// the old key is removed
+	base.SetSecretKeyring(oldKeyring)
+	newData, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("marshal spec failed: %v", err)
+	}
+	base.SetSecretKeyring(newKeyring)
+	if err := json.Unmarshal(newData, &decoded); err != nil {
+		t.Fatalf("the old key should decrypt: %v", err)
+	}
+	onlyNewKeyring, _ := base.NewSecretKeyring([][]byte{newKey})
+	base.SetSecretKeyring(onlyNewKeyring)
+	if err := json.Unmarshal(newData, &decoded); err == nil {
+		t.Fatalf("the removed key should not decrypt")
+	}
+
+	spec.Redact()
+	data, _ = json.Marshal(spec)
+	if !strings.Contains(string(data), `"password":"******"`) {
+		t.Fatalf("the password should be redacted: %s", data)
+	}
+	if strings.Contains(fmt.Sprintf("%v %+v", base.Spec{Password: "secret_password"}, &decoded), "secret_password") {
+		t.Fatalf("the password should not be formatted")
+	}
+}
@@ -239,10 +239,17 @@ type Spec struct {
 	// The mapping of host private and public ip
 	HostMapping map[string]string `json:"host_mapping,omitempty"`
 
-	observers []utils.Observer[SpecEvent]
+	// The tls config of the mysql connections to the frontends.
+	TLS *utils.TLSConfig `json:"tls,omitempty"`
+	// The tls config of the thrift connections to the frontends and backends.
+	ThriftTLS *utils.TLSConfig `json:"thrift_tls,omitempty"`
+
+	observers   []utils.Observer[SpecEvent]
+	staleSecret bool // the password is not encrypted by the primary secret key
+	redacted    bool // the password is hidden
 }
 
-func (s *Spec) String() string {
+func (s Spec) String() string {
 	return fmt.Sprintf("host: %s, port: %s, thrift_port: %s, user: %s, cluster: %s, database: %s, database id: %d, table: %s, table id: %d",
 		s.Host, s.Port, s.ThriftPort, s.User, s.Cluster, s.Database, s.DbId, s.Table, s.TableId)
 }
@@ -275,10 +282,25 @@ func (s *Spec) Valid() error {
 		return xerror.Errorf(xerror.Normal, "user is empty")
 	}
 
+	if _, _, err := s.Credentials(); err != nil {
+		return xerror.Wrap(err, xerror.Normal, "resolve the secret references failed")
+	}
+
 	if s.Database == "" {
 		return xerror.Errorf(xerror.Normal, "database is empty")
 	}
 
+	if s.TLS != nil {
+		if err := s.TLS.Valid(); err != nil {
+			return xerror.Wrap(err, xerror.Normal, "the tls config is invalid")
+		}
+	}
+	if s.ThriftTLS != nil {
+		if err := s.ThriftTLS.Valid(); err != nil {
+			return xerror.Wrap(err, xerror.Normal, "the thrift tls config is invalid")
+		}
+	}
+
 	return nil
 }
 
@@ -288,6 +310,18 @@ func (s *Spec) Valid() error {
 // The user should not set any session variables in the connections directly.
 func (s *Spec) Connect() (*sql.DB, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/", s.User, s.Password, s.Host, s.Port)
+	tlsName := ""
+	if s.TLS != nil {
+		var err error
+		if tlsName, err = s.TLS.MysqlTLSName(); err != nil {
+			return nil, err
+		}
+		dsn += "?tls=" + tlsName
+	}
+	if s.HasSecretRef() {
+		// The dsn only contains the references, the secrets are resolved by the connector.
+		return GetMysqlDBWithConnector(dsn, newSecretConnector(s, tlsName)), nil
+	}
 	return GetMysqlDB(dsn)
 }
 
@@ -391,6 +425,114 @@ func (s *Spec) CheckTablePropertyValid() ([]string, error) {
 	return checkResult, nil
 }
 
+func (s *Spec) ShowCreateTable(tableName string) (string, error) {
+	query := fmt.Sprintf("SHOW CREATE TABLE %s.%s", utils.FormatKeywordName(s.Database), utils.FormatKeywordName(tableName))
+	results, err := s.queryResult(query, "Create Table", "SHOW CREATE TABLE")
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", xerror.Errorf(xerror.Normal, "table %s.%s not found", s.Database, tableName)
+	}
+	return results[0], nil
+}
+
+// Get the data size in bytes of the database, or the table if the spec is a table.
+func (s *Spec) GetDataSize() (int64, error) {
+	db, err := s.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("SELECT IFNULL(SUM(DATA_LENGTH), 0) FROM information_schema.tables WHERE TABLE_SCHEMA = '%s'",
+		utils.EscapeStringValue(s.Database))
+	if s.Table != "" {
+		query += fmt.Sprintf(" AND TABLE_NAME = '%s'", utils.EscapeStringValue(s.Table))
+	}
+
+	var size int64
+	if err := db.QueryRow(query).Scan(&size); err != nil {
+		return 0, xerror.Wrapf(err, xerror.Normal, "get data size failed, sql: %s", query)
+	}
+	return size, nil
+}
+
+// Get the current unix time in milliseconds of the cluster, in the precision of seconds.
+func (s *Spec) GetCurrentTimestamp() (int64, error) {
+	db, err := s.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	query := "SELECT UNIX_TIMESTAMP()"
+	var seconds int64
+	if err := db.QueryRow(query).Scan(&seconds); err != nil {
+		return 0, xerror.Wrapf(err, xerror.Normal, "get current timestamp failed, sql: %s", query)
+	}
+	return seconds * 1000, nil
+}
+
+var binlogPropertyRegex = regexp.MustCompile(`"binlog\.([a-z_]+)"\s*=\s*"([^"]*)"`)
+
+func parseBinlogProperties(createSql string) *BinlogProperties {
+	properties := &BinlogProperties{}
+	for _, match := range binlogPropertyRegex.FindAllStringSubmatch(createSql, -1) {
+		value, _ := strconv.ParseInt(match[2], 10, 64)
+		switch match[1] {
+		case "enable":
+			properties.Enable = match[2] == "true"
+		case "ttl_seconds":
+			properties.TTLSeconds = value
+		case "max_bytes":
+			properties.MaxBytes = value
+		case "max_history_nums":
+			properties.MaxHistoryNums = value
+		}
+	}
+	return properties
+}
+
+// Get the binlog properties of the database, or the table if the spec is a table and the
+// database does not enable binlog.
+func (s *Spec) GetBinlogProperties() (*BinlogProperties, error) {
+	query := fmt.Sprintf("SHOW CREATE DATABASE %s", utils.FormatKeywordName(s.Database))
+	results, err := s.queryResult(query, "Create Database", "SHOW CREATE DATABASE")
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, xerror.Errorf(xerror.Normal, "database %s not found", s.Database)
+	}
+
+	properties := parseBinlogProperties(results[0])
+	if properties.Enable || s.Table == "" {
+		return properties, nil
+	}
+
+	createTableSql, err := s.ShowCreateTable(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	properties = parseBinlogProperties(createTableSql)
+	properties.TableLevel = true
+	return properties, nil
+}
+
+// Set the binlog ttl of the database, or the table if the binlog properties are table level.
+func (s *Spec) SetBinlogTTL(ttlSeconds int64, tableLevel bool) error {
+	var sql string
+	if tableLevel {
+		sql = fmt.Sprintf("ALTER TABLE %s.%s SET (\"binlog.ttl_seconds\" = \"%d\")",
+			utils.FormatKeywordName(s.Database), utils.FormatKeywordName(s.Table), ttlSeconds)
+	} else {
+		sql = fmt.Sprintf("ALTER DATABASE %s SET PROPERTIES (\"binlog.ttl_seconds\" = \"%d\")",
+			utils.FormatKeywordName(s.Database), ttlSeconds)
+	}
+
+	log.Infof("set binlog ttl, sql: %s", sql)
+	return s.Exec(sql)
+}
+
 func (s *Spec) IsEnableRestoreSnapshotCompression() (bool, error) {
 	log.Tracef("check frontend enable restore snapshot compression")
 
@@ -1379,6 +1521,73 @@ func (s *Spec) DropPartition(destTableName string, dropPartition *record.DropPar
 	return s.Exec(dropPartitionSql)
 }
 
+// Copy the data of the partition into a new table.
+func (s *Spec) CopyPartitionToTable(tableName, partitionName, newTableName string) error {
+	dbName := utils.FormatKeywordName(s.Database)
+	tableName = utils.FormatKeywordName(tableName)
+	partitionName = utils.FormatKeywordName(partitionName)
+	newTableName = utils.FormatKeywordName(newTableName)
+	copySql := fmt.Sprintf("CREATE TABLE %s.%s AS SELECT * FROM %s.%s PARTITION (%s)",
+		dbName, newTableName, dbName, tableName, partitionName)
+	log.Infof("copy partition sql: %s", copySql)
+	return s.Exec(copySql)
+}
+
+// Count the rows of the table, or the rows of the partition if the partition name is not empty.
+func (s *Spec) CountRows(tableName, partitionName string) (int64, error) {
+	db, err := s.Connect()
+	if err != nil {
+		return 0, err
+	}
+
+	countSql := fmt.Sprintf("SELECT COUNT(*) FROM %s.%s",
+		utils.FormatKeywordName(s.Database), utils.FormatKeywordName(tableName))
+	if partitionName != "" {
+		countSql += fmt.Sprintf(" PARTITION (%s)", utils.FormatKeywordName(partitionName))
+	}
+	log.Debugf("count rows sql: %s", countSql)
+
+	var count int64
+	if err := db.QueryRow(countSql).Scan(&count); err != nil {
+		return 0, xerror.Wrapf(err, xerror.Normal, "count rows failed, sql: %s", countSql)
+	}
+	return count, nil
+}
+
+// Get the row count and the checksum of the rows of a partition. The checksum is the sum of
+// the murmur hash of all columns of each row, so it does not depend on the order of the rows.
+func (s *Spec) GetPartitionChecksum(tableName, partitionName string) (*PartitionChecksum, error) {
+	columns, err := s.queryResult(fmt.Sprintf("SHOW COLUMNS FROM %s.%s",
+		utils.FormatKeywordName(s.Database), utils.FormatKeywordName(tableName)),
+		"Field", "SHOW COLUMNS")
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, xerror.Errorf(xerror.Normal, "no columns found in table %s", tableName)
+	}
+
+	values := make([]string, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, fmt.Sprintf("IFNULL(CAST(%s AS STRING), '\\\\N')", utils.FormatKeywordName(column)))
+	}
+	checksumSql := fmt.Sprintf("SELECT COUNT(*), IFNULL(SUM(murmur_hash3_32(CONCAT_WS('|', %s))), 0) FROM %s.%s PARTITION (%s)",
+		strings.Join(values, ", "), utils.FormatKeywordName(s.Database), utils.FormatKeywordName(tableName),
+		utils.FormatKeywordName(partitionName))
+	log.Debugf("partition checksum sql: %s", checksumSql)
+
+	db, err := s.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := &PartitionChecksum{}
+	if err := db.QueryRow(checksumSql).Scan(&checksum.RowCount, &checksum.Checksum); err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "get partition checksum failed, sql: %s", checksumSql)
+	}
+	return checksum, nil
+}
+
 func (s *Spec) RenamePartition(destTableName, oldPartition, newPartition string) error {
 	dbName := utils.FormatKeywordName(s.Database)
 	destTableName = utils.FormatKeywordName(destTableName)
@@ -1578,6 +1787,35 @@ func AddDBPrefixToCreateTableOrViewSql(dbName, createSql string) string {
 	return createSql
 }
 
+var createTableOrViewNameRegex = regexp.MustCompile(
+	"(?i)^\\s*CREATE\\s+(VIEW|TABLE)\\s+(IF\\s+NOT\\s+EXISTS\\s+)?" +
+		"(?:(`[^`]+`|[A-Za-z0-9_$]+)\\s*\\.\\s*)?(`[^`]+`|[A-Za-z0-9_$]+)")
+
+// Replace the table/view name of the create sql, the new name should not have the db prefix.
+// The IF NOT EXISTS and the db prefix of the create sql are kept, and the name might be quoted
+// or not. Returns error if the create sql has no table/view name to replace.
+func RenameCreateTableOrViewSql(createSql, newName string) (string, error) {
+	matches := createTableOrViewNameRegex.FindStringSubmatch(createSql)
+	if len(matches) != 5 {
+		return "", xerror.Errorf(xerror.Normal, "no table or view name to rename in the create sql: %s", createSql)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("CREATE ")
+	builder.WriteString(strings.ToUpper(matches[1]))
+	builder.WriteString(" ")
+	if matches[2] != "" {
+		builder.WriteString("IF NOT EXISTS ")
+	}
+	if matches[3] != "" {
+		builder.WriteString(matches[3])
+		builder.WriteString(".")
+	}
+	builder.WriteString(utils.FormatKeywordName(newName))
+	builder.WriteString(createSql[len(matches[0]):])
+	return builder.String(), nil
+}
+
 func ReplaceAndEscapeComment(input string) string {
 	re := regexp.MustCompile(`COMMENT '(.*?)'`)
 
@@ -1591,20 +1829,27 @@ func ReplaceAndEscapeComment(input string) string {
 	})
 }
 
+// The table properties which are not synced to the dest cluster.
+var unsupportedProperties = map[string]struct{}{
+	"binlog.enable":            {},
+	"light_schema_change":      {},
+	"dynamic_partition.enable": {},
+	"colocate_with":            {},
+	"storage_policy":           {},
+	"replication_num":          {},
+	"replication_allocation":   {},
+	"is_being_synced":          {},
+}
+
+func IsUnsupportedProperty(prop string) bool {
+	_, exists := unsupportedProperties[prop]
+	return exists
+}
+
 func FilterUnsupportedProperties(modifyProperty *record.ModifyTableProperty) map[string]string {
-	invalidProps := map[string]struct{}{
-		"binlog.enable":            {},
-		"light_schema_change":      {},
-		"dynamic_partition.enable": {},
-		"colocate_with":            {},
-		"storage_policy":           {},
-		"replication_num":          {},
-		"replication_allocation":   {},
-		"is_being_synced":          {},
-	}
 	validProperties := make(map[string]string)
 	for prop, value := range modifyProperty.Properties {
-		if _, exists := invalidProps[prop]; !exists {
+		if !IsUnsupportedProperty(prop) {
 			validProperties[prop] = value
 		}
 	}
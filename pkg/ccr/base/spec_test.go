@@ -45,6 +45,34 @@ func TestAddDBPrefixToCreateTableOrViewSql(t *testing.T) {
 	}
 }
 
+func TestRenameCreateTableOrViewSql(t *testing.T) {
+	type TestCase struct {
+		origin, expect string
+	}
+
+	testCases := []TestCase{
+		{"CREATE VIEW `v` AS SELECT * FROM t", "CREATE VIEW `new_v` AS SELECT * FROM t"},
+		{" CREATE TABLE `v` (...", "CREATE TABLE `new_v` (..."},
+		{"CREATE TABLE `db`.`v` (...", "CREATE TABLE `db`.`new_v` (..."},
+		{"CREATE TABLE IF NOT EXISTS `v` (...", "CREATE TABLE IF NOT EXISTS `new_v` (..."},
+		{"CREATE TABLE IF NOT EXISTS `db`.`v` (...", "CREATE TABLE IF NOT EXISTS `db`.`new_v` (..."},
+		{"CREATE TABLE v(...", "CREATE TABLE `new_v`(..."},
+		{"create view db.v AS SELECT 1", "CREATE VIEW db.`new_v` AS SELECT 1"},
+	}
+
+	for i, c := range testCases {
+		if actual, err := base.RenameCreateTableOrViewSql(c.origin, "new_v"); err != nil {
+			t.Errorf("case %d failed, err: %v", i, err)
+		} else if actual != c.expect {
+			t.Errorf("case %d failed, expect %s, but got %s", i, c.expect, actual)
+		}
+	}
+
+	if _, err := base.RenameCreateTableOrViewSql("CREATE MATERIALIZED VIEW mv AS SELECT 1", "new_v"); err == nil {
+		t.Errorf("expect error for the create sql without table or view name")
+	}
+}
+
 func TestReplaceAndEscapeComment(t *testing.T) {
 	type TestCase struct {
 		origin, expect string
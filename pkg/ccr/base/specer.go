@@ -28,6 +28,22 @@ const (
 	httpNotFoundEvent SpecEvent = 1
 )
 
+// The row count and checksum of a partition, see Specer.GetPartitionChecksum.
+type PartitionChecksum struct {
+	RowCount int64 `json:"row_count"`
+	Checksum int64 `json:"checksum"`
+}
+
+// The binlog properties of a database or a table, zero means the property is not set.
+type BinlogProperties struct {
+	Enable         bool  `json:"enable"`
+	TTLSeconds     int64 `json:"ttl_seconds,omitempty"`
+	MaxBytes       int64 `json:"max_bytes,omitempty"`
+	MaxHistoryNums int64 `json:"max_history_nums,omitempty"`
+	// The properties are of the table, since the database does not enable binlog.
+	TableLevel bool `json:"table_level,omitempty"`
+}
+
 // this interface is used to for spec operation, treat it as a mysql dao
 type Specer interface {
 	Valid() error
@@ -42,6 +58,11 @@ type Specer interface {
 	CheckTableExists() (bool, error)
 	CheckTablePropertyValid() ([]string, error)
 	CheckTableExistsByName(tableName string) (bool, error)
+	ShowCreateTable(tableName string) (string, error)
+	GetDataSize() (int64, error)
+	GetCurrentTimestamp() (int64, error)
+	GetBinlogProperties() (*BinlogProperties, error)
+	SetBinlogTTL(ttlSeconds int64, tableLevel bool) error
 	GetValidBackupJob(snapshotNamePrefix string) (string, error)
 	GetValidRestoreJob(snapshotNamePrefix string) (string, error)
 	CancelRestoreIfExists(snapshotName string) error
@@ -66,6 +87,9 @@ type Specer interface {
 	AddPartition(destTableName string, addPartition *record.AddPartition) error
 	DropPartition(destTableName string, dropPartition *record.DropPartition) error
 	RenamePartition(destTableName, oldPartition, newPartition string) error
+	CopyPartitionToTable(tableName, partitionName, newTableName string) error
+	CountRows(tableName, partitionName string) (int64, error)
+	GetPartitionChecksum(tableName, partitionName string) (*PartitionChecksum, error)
 
 	LightningIndexChange(tableAlias string, changes *record.ModifyTableAddOrDropInvertedIndices) error
 	BuildIndex(tableAlias string, buildIndex *record.IndexChangeJob) error
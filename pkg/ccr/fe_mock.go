@@ -28,6 +28,7 @@ import (
 	reflect "reflect"
 
 	base "github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	rpc "github.com/selectdb/ccr_syncer/pkg/rpc"
 	frontendservice "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
 	status "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
 	types "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/types"
@@ -86,6 +87,21 @@ func (mr *MockIFeRpcMockRecorder) BeginTransaction(arg0, arg1, arg2 any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTransaction", reflect.TypeOf((*MockIFeRpc)(nil).BeginTransaction), arg0, arg1, arg2)
 }
 
+// BeginTransactionForTxnInsert mocks base method.
+func (m *MockIFeRpc) BeginTransactionForTxnInsert(arg0 *base.Spec, arg1 string, arg2 []int64, arg3 int64) (*frontendservice.TBeginTxnResult_, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginTransactionForTxnInsert", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*frontendservice.TBeginTxnResult_)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginTransactionForTxnInsert indicates an expected call of BeginTransactionForTxnInsert.
+func (mr *MockIFeRpcMockRecorder) BeginTransactionForTxnInsert(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTransactionForTxnInsert", reflect.TypeOf((*MockIFeRpc)(nil).BeginTransactionForTxnInsert), arg0, arg1, arg2, arg3)
+}
+
 // CommitTransaction mocks base method.
 func (m *MockIFeRpc) CommitTransaction(arg0 *base.Spec, arg1 int64, arg2 []*types.TTabletCommitInfo) (*frontendservice.TCommitTxnResult_, error) {
 	m.ctrl.T.Helper()
@@ -101,6 +117,21 @@ func (mr *MockIFeRpcMockRecorder) CommitTransaction(arg0, arg1, arg2 any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitTransaction", reflect.TypeOf((*MockIFeRpc)(nil).CommitTransaction), arg0, arg1, arg2)
 }
 
+// CommitTransactionForTxnInsert mocks base method.
+func (m *MockIFeRpc) CommitTransactionForTxnInsert(arg0 *base.Spec, arg1 int64, arg2 bool, arg3 []*frontendservice.TSubTxnInfo) (*frontendservice.TCommitTxnResult_, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitTransactionForTxnInsert", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*frontendservice.TCommitTxnResult_)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CommitTransactionForTxnInsert indicates an expected call of CommitTransactionForTxnInsert.
+func (mr *MockIFeRpcMockRecorder) CommitTransactionForTxnInsert(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitTransactionForTxnInsert", reflect.TypeOf((*MockIFeRpc)(nil).CommitTransactionForTxnInsert), arg0, arg1, arg2, arg3)
+}
+
 // GetBackends mocks base method.
 func (m *MockIFeRpc) GetBackends(spec *base.Spec) (*frontendservice.TGetBackendMetaResult_, error) {
 	m.ctrl.T.Helper()
@@ -177,18 +208,18 @@ func (mr *MockIFeRpcMockRecorder) GetMasterToken(arg0 any) *gomock.Call {
 }
 
 // GetSnapshot mocks base method.
-func (m *MockIFeRpc) GetSnapshot(arg0 *base.Spec, arg1 string) (*frontendservice.TGetSnapshotResult_, error) {
+func (m *MockIFeRpc) GetSnapshot(arg0 *base.Spec, arg1 string, arg2 bool) (*frontendservice.TGetSnapshotResult_, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSnapshot", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetSnapshot", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*frontendservice.TGetSnapshotResult_)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetSnapshot indicates an expected call of GetSnapshot.
-func (mr *MockIFeRpcMockRecorder) GetSnapshot(arg0, arg1 any) *gomock.Call {
+func (mr *MockIFeRpcMockRecorder) GetSnapshot(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSnapshot", reflect.TypeOf((*MockIFeRpc)(nil).GetSnapshot), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSnapshot", reflect.TypeOf((*MockIFeRpc)(nil).GetSnapshot), arg0, arg1, arg2)
 }
 
 // GetTableMeta mocks base method.
@@ -207,18 +238,18 @@ func (mr *MockIFeRpcMockRecorder) GetTableMeta(spec, tableIds any) *gomock.Call
 }
 
 // RestoreSnapshot mocks base method.
-func (m *MockIFeRpc) RestoreSnapshot(arg0 *base.Spec, arg1 []*frontendservice.TTableRef, arg2 string, arg3 *frontendservice.TGetSnapshotResult_) (*frontendservice.TRestoreSnapshotResult_, error) {
+func (m *MockIFeRpc) RestoreSnapshot(arg0 *base.Spec, arg1 *rpc.RestoreSnapshotRequest) (*frontendservice.TRestoreSnapshotResult_, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RestoreSnapshot", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "RestoreSnapshot", arg0, arg1)
 	ret0, _ := ret[0].(*frontendservice.TRestoreSnapshotResult_)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // RestoreSnapshot indicates an expected call of RestoreSnapshot.
-func (mr *MockIFeRpcMockRecorder) RestoreSnapshot(arg0, arg1, arg2, arg3 any) *gomock.Call {
+func (mr *MockIFeRpcMockRecorder) RestoreSnapshot(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSnapshot", reflect.TypeOf((*MockIFeRpc)(nil).RestoreSnapshot), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSnapshot", reflect.TypeOf((*MockIFeRpc)(nil).RestoreSnapshot), arg0, arg1)
 }
 
 // RollbackTransaction mocks base method.
@@ -18,8 +18,14 @@ package ccr
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/modern-go/gls"
 	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
@@ -36,6 +42,54 @@ import (
 
 var errNotFoundDestMappingTableId = xerror.NewWithoutStack(xerror.Meta, "not found dest mapping table id")
 
+const binlogDataSizeTimeout = 10 * time.Second
+
+var binlogDataSizeClient = &http.Client{Timeout: binlogDataSizeTimeout}
+
+// Get the data size of the rowset of the tablet binlog from the src backend, it's the sum of
+// the segment files downloaded by the dest backend when ingesting the binlog.
+func getBinlogDataSize(backend *base.Backend, tabletId, binlogVersion int64) (int64, error) {
+	baseUrl := fmt.Sprintf("http://%s:%d/api/_binlog/_download", backend.Host, backend.HttpPort)
+	infoUrl := fmt.Sprintf("%s?type=get_binlog_info&tablet_id=%d&binlog_version=%d",
+		baseUrl, tabletId, binlogVersion)
+	resp, err := binlogDataSizeClient.Get(infoUrl)
+	if err != nil {
+		return 0, xerror.Wrapf(err, xerror.BE, "get url: %s failed", infoUrl)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return 0, xerror.Wrapf(err, xerror.BE, "read body failed, url: %s", infoUrl)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, xerror.Errorf(xerror.BE, "get url: %s failed, status: %s, body: %s", infoUrl, resp.Status, body)
+	}
+
+	// the binlog info is formatted as "rowset_id:num_segments"
+	rowsetId, numSegmentsStr, found := strings.Cut(strings.TrimSpace(string(body)), ":")
+	numSegments, err := strconv.Atoi(numSegmentsStr)
+	if !found || err != nil {
+		return 0, xerror.Errorf(xerror.BE, "invalid binlog info: %s, url: %s", body, infoUrl)
+	}
+
+	var size int64
+	for i := 0; i < numSegments; i++ {
+		segmentUrl := fmt.Sprintf("%s?type=get_segment_file&tablet_id=%d&rowset_id=%s&segment_index=%d&binlog_version=%d",
+			baseUrl, tabletId, rowsetId, i, binlogVersion)
+		resp, err := binlogDataSizeClient.Head(segmentUrl)
+		if err != nil {
+			return 0, xerror.Wrapf(err, xerror.BE, "head url: %s failed", segmentUrl)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+			return 0, xerror.Errorf(xerror.BE, "head url: %s failed, status: %s, content length: %d",
+				segmentUrl, resp.Status, resp.ContentLength)
+		}
+		size += resp.ContentLength
+	}
+	return size, nil
+}
+
 type commitInfosCollector struct {
 	commitInfos     []*ttypes.TTabletCommitInfo
 	commitInfosLock sync.Mutex
@@ -180,6 +234,9 @@ func (h *tabletIngestBinlogHandler) handleReplica(srcReplica, destReplica *Repli
 		gls.Set("job", j.ccrJob.Name)
 		defer gls.ResetGls(gls.GoID(), map[interface{}]interface{}{})
 
+		// acquire the slot of the job first, then the backend.
+		release := j.ccrJob.acquireIngestSlot()
+		defer release()
 		cwind.Acquire()
 		defer cwind.Release()
 
@@ -238,7 +295,12 @@ func (h *tabletIngestBinlogHandler) handle() {
 	})
 	h.wg.Wait()
 
-	h.ingestJob.appendCommitInfos(h.CommitInfos()...)
+	commitInfos := h.CommitInfos()
+	if h.ingestJob.countDataSize && len(commitInfos) > 0 {
+		h.countDataSize(srcReplicas[0], len(commitInfos))
+	}
+
+	h.ingestJob.appendCommitInfos(commitInfos...)
 	// for txn insert
 	if h.stid != 0 {
 		commitInfos := h.SubTxnToCommitInfos()[h.stid]
@@ -246,6 +308,22 @@ func (h *tabletIngestBinlogHandler) handle() {
 	}
 }
 
+// Charge the data size of the binlog, which is downloaded by each ingested dest replica, to the ccr job.
+func (h *tabletIngestBinlogHandler) countDataSize(srcReplica *ReplicaMeta, ingestedReplicas int) {
+	srcBackend := h.ingestJob.GetSrcBackend(srcReplica.BackendId)
+	if srcBackend == nil {
+		return
+	}
+
+	size, err := getBinlogDataSize(srcBackend, h.srcTablet.Id, h.binlogVersion)
+	if err != nil {
+		log.Warnf("txn %d get data size of src tablet %d binlog version %d failed, err: %+v",
+			h.ingestJob.txnId, h.srcTablet.Id, h.binlogVersion, err)
+		return
+	}
+	h.ingestJob.ccrJob.ingestedBytes.Add(size * int64(ingestedReplicas))
+}
+
 type IngestContext struct {
 	context.Context
 	txnId        int64
@@ -291,6 +369,9 @@ type IngestBinlogJob struct {
 
 	tabletIngestJobs []*tabletIngestBinlogHandler
 
+	// count the data size of the ingested binlogs for the bytes limit of the sync schedule
+	countDataSize bool
+
 	*commitInfosCollector
 	*subTxnInfosCollector
 
@@ -316,6 +397,8 @@ func NewIngestBinlogJob(ctx context.Context, ccrJob *Job) (*IngestBinlogJob, err
 		tableRecords: ingestCtx.tableRecords,
 		stidMap:      ingestCtx.stidMapping,
 
+		countDataSize: ccrJob.Extra.Schedule.hasBytesLimit(),
+
 		commitInfosCollector: newCommitInfosCollector(),
 		subTxnInfosCollector: newSubTxnInfosCollector(),
 	}, nil
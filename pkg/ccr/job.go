@@ -25,6 +25,7 @@ import (
 	"math"
 	"math/rand"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -52,6 +53,9 @@ const (
 
 	SkipBySilence  = "silence"
 	SkipByFullSync = "fullsync"
+
+	// The prefix of the txn labels generated by the syncer.
+	syncerLabelPrefix = "ccrj-"
 )
 
 var (
@@ -120,6 +124,12 @@ type JobState int
 const (
 	JobRunning JobState = 0
 	JobPaused  JobState = 1
+	// The job has synced to the stop-at target, and stops syncing.
+	JobReachedTarget JobState = 2
+	// The job meets a binlog paused by the DDL policy, and waits for the approval.
+	JobWaitingApproval JobState = 3
+	// The automatic full sync is held by the full sync policy, and waits for the approval.
+	JobFullSyncPendingApproval JobState = 4
 )
 
 // JobState Stringer
@@ -129,6 +139,12 @@ func (j JobState) String() string {
 		return "running"
 	case JobPaused:
 		return "paused"
+	case JobReachedTarget:
+		return "reached_target"
+	case JobWaitingApproval:
+		return "waiting_approval"
+	case JobFullSyncPendingApproval:
+		return "fullsync_pending_approval"
 	default:
 		return "unknown"
 	}
@@ -138,6 +154,10 @@ type JobExtra struct {
 	// Reuse the upstream txn label as the downstream txn label.
 	ReuseBinlogLabel bool `json:"reuse_binlog_label,omitempty"`
 
+	// Skip the upserts which are written into the src by the syncer itself, so a pair of
+	// jobs in the opposite directions will not echo each other's upserts forever.
+	Bidirectional bool `json:"bidirectional,omitempty"`
+
 	allowTableExists bool `json:"-"` // Only for FirstRun(), don't need to persist.
 
 	// Skip a specified binlog or binlogs, don't need to persist.
@@ -145,6 +165,66 @@ type JobExtra struct {
 	SkipBinlog    bool   `json:"skip_binlog,omitempty"`
 	SkipCommitSeq int64  `json:"skip_commit_seq,omitempty"`
 	SkipBy        string `json:"skip_by,omitempty"`
+
+	// Only sync the tables selected by the filter, for db sync only.
+	TableFilter *TableFilter `json:"table_filter,omitempty"`
+
+	// The explicit table list of a multi-table sync job, which is a db sync job that only
+	// syncs these tables, with one snapshot and one binlog cursor for all of them.
+	Tables []string `json:"tables,omitempty"`
+	// The src table ids of the tables, resolved by the first run. The listed tables are
+	// tracked by the ids, so they are still synced after they are renamed.
+	TableIds []int64 `json:"table_ids,omitempty"`
+
+	// Rename the dest tables of a db sync job, so several src dbs could sync to one dest db.
+	TableRename *TableRename `json:"table_rename,omitempty"`
+
+	// Sync the binlogs until the commit seq or the binlog timestamp (in milliseconds), then
+	// switch to JobReachedTarget. Zero means no target.
+	StopAtCommitSeq int64 `json:"stop_at_commit_seq,omitempty"`
+	StopAtTimestamp int64 `json:"stop_at_timestamp,omitempty"`
+
+	// Limit when and how fast the binlogs are applied.
+	Schedule *SyncSchedule `json:"schedule,omitempty"`
+
+	// The rules to apply, skip or pause the DDL binlogs.
+	DDLPolicy *DDLPolicy `json:"ddl_policy,omitempty"`
+	// The binlog waits for the approval, and the last approved one.
+	PendingApproval   *DDLEvent `json:"pending_approval,omitempty"`
+	ApprovedCommitSeq int64     `json:"approved_commit_seq,omitempty"`
+	// The recent binlogs skipped by the skip_and_record rules.
+	SkippedBinlogs []*DDLEvent `json:"skipped_binlogs,omitempty"`
+
+	// Archive the dropped tables and partitions instead of dropping them.
+	SoftDelete *SoftDelete `json:"soft_delete,omitempty"`
+
+	// Export the handled binlogs to the rotating files.
+	FileSink *FileSink `json:"file_sink,omitempty"`
+	// Export the handled binlogs to the sinks, such as webhook and kafka.
+	Sinks []*SinkConfig `json:"sinks,omitempty"`
+
+	// The schema differences between the src and dest tables, found by the last check.
+	SchemaDrift *SchemaDrift `json:"schema_drift,omitempty"`
+
+	// Limit the automatic full syncs, and the full sync waits for the approval.
+	FullSyncPolicy  *FullSyncPolicy  `json:"full_sync_policy,omitempty"`
+	PendingFullSync *PendingFullSync `json:"pending_full_sync,omitempty"`
+
+	// The unsynced binlogs against the binlog retention of src, found by the last check.
+	BinlogRetention *BinlogRetention `json:"binlog_retention,omitempty"`
+	// Extend the binlog ttl of src while the job is not running, and the ttl changed by it.
+	BinlogLease     *BinlogLease     `json:"binlog_lease,omitempty"`
+	HeldBinlogLease *HeldBinlogLease `json:"held_binlog_lease,omitempty"`
+}
+
+// Compile the table filter and the ddl policy after unmarshal.
+func (e *JobExtra) compile() error {
+	if e.TableFilter != nil {
+		if err := e.TableFilter.compile(); err != nil {
+			return err
+		}
+	}
+	return e.DDLPolicy.compile()
 }
 
 type Job struct {
@@ -159,6 +239,19 @@ type Job struct {
 	State    JobState    `json:"state"`
 	Extra    JobExtra    `json:"extra"`
 
+	// The extra destinations, each of them is synced by a fan-out job with its own progress,
+	// and the binlogs of the src are shared between all destinations.
+	Dests      []base.Spec    `json:"dests,omitempty"`
+	DestStates []*FanoutState `json:"dest_states,omitempty"`
+	fanouts    []*Job         `json:"-"`
+	binlogFeed *binlogFeed    `json:"-"`
+	parent     *Job           `json:"-"` // the job of the primary destination, only for fan-out jobs
+	destIndex  int            `json:"-"` // the index in the dests of parent, only for fan-out jobs
+	fanoutLock sync.Mutex     `json:"-"` // guard the DestStates
+
+	fanoutsStopped     chan struct{} `json:"-"`
+	fanoutsStoppedOnce sync.Once     `json:"-"`
+
 	factory *Factory `json:"-"`
 
 	progress   *JobProgress `json:"-"`
@@ -171,6 +264,25 @@ type Job struct {
 
 	asyncMvTableCache  map[int64]struct{}      `json:"-"`
 	concurrencyManager *rpc.ConcurrencyManager `json:"-"`
+	ingestWindow       *rpc.ConcurrencyWindow  `json:"-"`
+	throttle           *syncThrottle           `json:"-"`
+	lastArchivePurgeAt time.Time               `json:"-"`
+	sinks              []*jobSink              `json:"-"`
+
+	lastSchemaDriftCheckAt time.Time    `json:"-"`
+	schemaDriftChecking    atomic.Bool  `json:"-"`
+	repairBudget           repairBudget `json:"-"`
+	fullSyncApproved       bool         `json:"-"`
+
+	srcDataSize         atomic.Pointer[srcDataSize] `json:"-"`
+	srcDataSizeFetching atomic.Bool                 `json:"-"`
+
+	lastBinlogRetentionCheckAt time.Time   `json:"-"`
+	lastBinlogRetentionState   JobState    `json:"-"`
+	binlogRetentionChecking    atomic.Bool `json:"-"`
+
+	// The bytes ingested by the upserts since the last applied binlogs were recorded.
+	ingestedBytes atomic.Int64 `json:"-"`
 
 	lock sync.Mutex `json:"-"`
 }
@@ -183,7 +295,24 @@ type JobContext struct {
 	SkipError        bool
 	AllowTableExists bool
 	ReuseBinlogLabel bool
+	Bidirectional    bool
+	IncludeTables    []string
+	ExcludeTables    []string
+	Tables           []string
+	TableRename      *TableRename
+	Dests            []base.Spec
+	StopAtCommitSeq  int64
+	StopAtTimestamp  int64
+	Schedule         *SyncSchedule
+	DDLPolicy        *DDLPolicy
+	SoftDelete       *SoftDelete
+	FileSink         *FileSink
+	Sinks            []*SinkConfig
+	FullSyncPolicy   *FullSyncPolicy
+	BinlogLease      *BinlogLease
 	Factory          *Factory
+	// The job is only used to plan, the sinks and the schedule are not initialized.
+	DryRun bool
 }
 
 // new job
@@ -205,11 +334,24 @@ func NewJobFromService(name string, ctx context.Context) (*Job, error) {
 		IDest:    factory.NewSpecer(&dest),
 		destMeta: factory.NewMeta(&jobContext.Dest),
 		State:    JobRunning,
+		Dests:    jobContext.Dests,
 
 		Extra: JobExtra{
 			allowTableExists: jobContext.AllowTableExists,
 			ReuseBinlogLabel: jobContext.ReuseBinlogLabel,
+			Bidirectional:    jobContext.Bidirectional,
 			SkipBinlog:       false,
+			Tables:           jobContext.Tables,
+			TableRename:      jobContext.TableRename,
+			StopAtCommitSeq:  jobContext.StopAtCommitSeq,
+			StopAtTimestamp:  jobContext.StopAtTimestamp,
+			Schedule:         jobContext.Schedule,
+			DDLPolicy:        jobContext.DDLPolicy,
+			SoftDelete:       jobContext.SoftDelete,
+			FileSink:         jobContext.FileSink,
+			Sinks:            jobContext.Sinks,
+			FullSyncPolicy:   jobContext.FullSyncPolicy,
+			BinlogLease:      jobContext.BinlogLease,
 		},
 
 		factory: factory,
@@ -221,6 +363,14 @@ func NewJobFromService(name string, ctx context.Context) (*Job, error) {
 		concurrencyManager: rpc.NewConcurrencyManager(),
 	}
 
+	if len(jobContext.IncludeTables) > 0 || len(jobContext.ExcludeTables) > 0 {
+		filter, err := NewTableFilter(jobContext.IncludeTables, jobContext.ExcludeTables)
+		if err != nil {
+			return nil, err
+		}
+		job.Extra.TableFilter = filter
+	}
+
 	if err := job.valid(); err != nil {
 		return nil, xerror.Wrap(err, xerror.Normal, "job is invalid")
 	}
@@ -232,6 +382,11 @@ func NewJobFromService(name string, ctx context.Context) (*Job, error) {
 	}
 
 	job.jobFactory = NewJobFactory()
+	if !jobContext.DryRun {
+		job.initSchedule()
+		job.initSinks()
+	}
+	job.initFanouts()
 
 	return job, nil
 }
@@ -243,6 +398,18 @@ func NewJobFromJson(jsonData string, db storage.DB, factory *Factory) (*Job, err
 		return nil, xerror.Wrapf(err, xerror.Normal, "unmarshal json failed, json: %s", jsonData)
 	}
 
+	if err := job.Extra.compile(); err != nil {
+		return nil, err
+	}
+	for _, state := range job.DestStates {
+		if state == nil {
+			continue
+		}
+		if err := state.Extra.compile(); err != nil {
+			return nil, err
+		}
+	}
+
 	// recover all not json fields
 	job.factory = factory
 	job.ISrc = factory.NewSpecer(&job.Src)
@@ -254,6 +421,17 @@ func NewJobFromJson(jsonData string, db storage.DB, factory *Factory) (*Job, err
 	job.stop = make(chan struct{})
 	job.jobFactory = NewJobFactory()
 	job.concurrencyManager = rpc.NewConcurrencyManager()
+	job.initSchedule()
+	job.initSinks()
+	job.initFanouts()
+
+	if job.hasStaleSecrets() {
+		// migrate the plaintext passwords, or the passwords encrypted by the old keys
+		log.Infof("encrypt the passwords of job %s by the primary secret key", job.Name)
+		if err := job.persistJob(); err != nil {
+			return nil, err
+		}
+	}
 	return &job, nil
 }
 
@@ -283,6 +461,72 @@ func (j *Job) valid() error {
 		return xerror.New(xerror.Normal, "src/dest are not both db or table sync")
 	}
 
+	if err := j.validFanouts(); err != nil {
+		return err
+	}
+
+	if j.Extra.StopAtCommitSeq < 0 || j.Extra.StopAtTimestamp < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid stop at target, commit seq: %d, timestamp: %d",
+			j.Extra.StopAtCommitSeq, j.Extra.StopAtTimestamp)
+	}
+
+	if err := j.Extra.Schedule.Valid(); err != nil {
+		return err
+	}
+
+	if err := j.Extra.DDLPolicy.compile(); err != nil {
+		return err
+	}
+
+	if err := j.Extra.SoftDelete.Valid(); err != nil {
+		return err
+	}
+
+	if err := j.validSinks(); err != nil {
+		return err
+	}
+
+	if err := j.Extra.FullSyncPolicy.Valid(); err != nil {
+		return err
+	}
+
+	if err := j.Extra.BinlogLease.Valid(); err != nil {
+		return err
+	}
+
+	if j.Extra.Bidirectional && j.Extra.ReuseBinlogLabel {
+		// The syncer-originated txns are recognized by the labels generated by the syncer.
+		return xerror.New(xerror.Normal, "reuse binlog label is not supported by bidirectional sync")
+	}
+
+	if j.Src.Table != "" && !j.Extra.TableFilter.IsEmpty() {
+		return xerror.New(xerror.Normal, "table filter is only supported by db sync")
+	}
+
+	if !j.Extra.TableRename.IsEmpty() {
+		if j.Src.Table != "" {
+			return xerror.New(xerror.Normal, "table rename is only supported by db sync")
+		}
+		if err := j.Extra.TableRename.Valid(); err != nil {
+			return err
+		}
+	}
+
+	if len(j.Extra.Tables) > 0 {
+		if j.Src.Table != "" {
+			return xerror.New(xerror.Normal, "src/dest table must be empty in multi-table sync")
+		}
+		tables := make(map[string]struct{})
+		for _, table := range j.Extra.Tables {
+			if table == "" {
+				return xerror.New(xerror.Normal, "table name is empty in multi-table sync")
+			} else if _, ok := tables[table]; ok {
+				return xerror.Errorf(xerror.Normal, "table %s is duplicated in multi-table sync", table)
+			}
+			tables[table] = struct{}{}
+		}
+	}
+
 	return nil
 }
 
@@ -330,6 +574,18 @@ func (j *Job) isTableSyncWithAlias() bool {
 	return j.SyncType == TableSync && j.Src.Table != j.Dest.Table
 }
 
+func (j *Job) isDBSyncWithRename() bool {
+	return j.SyncType == DBSync && !j.Extra.TableRename.IsEmpty()
+}
+
+// Get the dest table name of the src table by the table rename rules of a db sync job.
+func (j *Job) destTableName(srcTable string) string {
+	if j.SyncType != DBSync {
+		return srcTable
+	}
+	return j.Extra.TableRename.DestName(srcTable)
+}
+
 func (j *Job) isTableDropped(tableId int64) (bool, error) {
 	// Keep compatible with the old version, which doesn't have the table id in partial sync data.
 	if tableId == 0 {
@@ -625,6 +881,15 @@ func (j *Job) partialSync() error {
 				AliasName: &j.Dest.Table,
 			}
 			tableRefs = append(tableRefs, tableRef)
+		} else if j.isDBSyncWithRename() {
+			destTable := j.destTableName(table)
+			log.Infof("partial sync with table rename, table: %s, dest table: %s", table, destTable)
+			tableRefs = make([]*festruct.TTableRef, 0)
+			tableRef := &festruct.TTableRef{
+				Table:     &table,
+				AliasName: &destTable,
+			}
+			tableRefs = append(tableRefs, tableRef)
 		}
 
 		restoreReq := rpc.RestoreSnapshotRequest{
@@ -691,7 +956,7 @@ func (j *Job) partialSync() error {
 	case PersistRestoreInfo:
 		// Step 7: Update job progress && dest table id
 		// update job info, only for dest table id
-		var targetName = table
+		var targetName = j.destTableName(table)
 		if j.isTableSyncWithAlias() {
 			targetName = j.Dest.Table
 		}
@@ -800,12 +1065,24 @@ func (j *Job) fullSync() error {
 			count := 0
 			for _, table := range tables {
 				// See fe/fe-core/src/main/java/org/apache/doris/backup/BackupHandler.java:backup() for details
-				if table.Type == record.TableTypeOlap || table.Type == record.TableTypeView {
+				if table.Type != record.TableTypeOlap && table.Type != record.TableTypeView {
+					continue
+				}
+				if !j.hasTableSelector() {
 					count += 1
+				} else if j.isTableSelected(table.Name) {
+					count += 1
+					backupTableList = append(backupTableList, table.Name)
+					// the listed table might be created again, and the binlogs are missed
+					if err := j.updateListedTableId(table.Name, table.Id); err != nil {
+						return err
+					}
+				} else {
+					log.Debugf("fullsync skip table %s, it is not selected by the job", table.Name)
 				}
 			}
 			if count == 0 {
-				log.Warnf("full sync but source db is empty! retry later")
+				log.Warnf("full sync but source db is empty or no table is selected! retry later")
 				return nil
 			}
 		case TableSync:
@@ -999,6 +1276,23 @@ func (j *Job) fullSync() error {
 				tableRef.AliasName = &alias
 			}
 			tableRefs = append(tableRefs, tableRef)
+		} else if j.isDBSyncWithRename() {
+			// ATTN: the keys of the table aliases are the dest table names.
+			tableRefs = make([]*festruct.TTableRef, 0)
+			for _, tableName := range tableNameMapping {
+				destTable := j.destTableName(tableName)
+				tableRef := &festruct.TTableRef{
+					Table:     utils.ThriftValueWrapper(tableName),
+					AliasName: utils.ThriftValueWrapper(destTable),
+				}
+				if alias, ok := j.progress.TableAliases[destTable]; ok {
+					log.Infof("fullsync alias table from %s to %s", tableName, alias)
+					tableRef.AliasName = utils.ThriftValueWrapper(alias)
+				} else {
+					log.Debugf("fullsync rename table from %s to %s", tableName, destTable)
+				}
+				tableRefs = append(tableRefs, tableRef)
+			}
 		} else if len(j.progress.TableAliases) > 0 {
 			tableRefs = make([]*festruct.TTableRef, 0)
 			viewMap := make(map[string]interface{})
@@ -1050,8 +1344,24 @@ func (j *Job) fullSync() error {
 		if featureCleanTableAndPartitions {
 			// drop exists partitions, and drop tables if in db sync.
 			restoreReq.CleanPartitions = true
-			if j.SyncType == DBSync {
-				restoreReq.CleanTables = true
+			// the tables not selected by the job are not belong to this job, keep them.
+			if j.SyncType == DBSync && !j.hasTableSelector() && !j.isDBSyncWithRename() {
+				if !j.isSoftDelete() {
+					restoreReq.CleanTables = true
+				} else {
+					// the archived tables are kept until they are purged, so archive the
+					// tables which would be cleaned, rather than cleaning the tables.
+					snapshotTables := make(map[string]struct{})
+					for _, table := range tableNameMapping {
+						snapshotTables[table] = struct{}{}
+					}
+					for _, view := range inMemoryData.Views {
+						snapshotTables[view] = struct{}{}
+					}
+					if err := j.archiveOrphanTables(snapshotTables, j.progress.CommitSeq); err != nil {
+						return err
+					}
+				}
 			}
 		}
 		if featureAtomicRestore {
@@ -1231,13 +1541,13 @@ func (j *Job) fullSync() error {
 					}
 				}
 
-				destTableId, err := j.destMeta.GetTableId(srcTableName)
+				destTableId, err := j.destMeta.GetTableId(j.destTableName(srcTableName))
 				if err != nil {
 					return err
 				}
 
-				log.Debugf("fullsync table mapping, src: %d, dest: %d, name: %s",
-					srcTableId, destTableId, srcTableName)
+				log.Debugf("fullsync table mapping, src: %d, dest: %d, name: %s, dest name: %s",
+					srcTableId, destTableId, srcTableName, j.destTableName(srcTableName))
 				tableMapping[srcTableId] = destTableId
 			}
 
@@ -1272,6 +1582,13 @@ func (j *Job) fullSync() error {
 }
 
 func (j *Job) persistJob() error {
+	if j.parent != nil {
+		return j.parent.persistFanout(j)
+	}
+
+	j.fanoutLock.Lock()
+	defer j.fanoutLock.Unlock()
+
 	data, err := json.Marshal(j)
 	if err != nil {
 		return xerror.Errorf(xerror.Normal, "marshal job failed, job: %v", j)
@@ -1290,12 +1607,42 @@ func (j *Job) newLabel(commitSeq int64) string {
 	randNum := rand.Intn(65536) // hex 4 chars
 	if j.SyncType == DBSync {
 		// label "ccrj-rand:${sync_type}:${src_db_id}:${dest_db_id}:${commit_seq}"
-		return fmt.Sprintf("ccrj-%x:%s:%d:%d:%d", randNum, j.SyncType, src.DbId, dest.DbId, commitSeq)
+		return fmt.Sprintf("%s%x:%s:%d:%d:%d", syncerLabelPrefix, randNum, j.SyncType, src.DbId, dest.DbId, commitSeq)
 	} else {
 		// TableSync
 		// label "ccrj-rand:${sync_type}:${src_db_id}_${src_table_id}:${dest_db_id}_${dest_table_id}:${commit_seq}"
-		return fmt.Sprintf("ccrj-%x:%s:%d_%d:%d_%d:%d", randNum, j.SyncType, src.DbId, src.TableId, dest.DbId, dest.TableId, commitSeq)
+		return fmt.Sprintf("%s%x:%s:%d_%d:%d_%d:%d", syncerLabelPrefix, randNum, j.SyncType, src.DbId, src.TableId, dest.DbId, dest.TableId, commitSeq)
+	}
+}
+
+// ParseSyncerLabelDestDbId returns the dest db id of a txn label generated by newLabel,
+// false if the label is not generated by the syncer.
+func ParseSyncerLabelDestDbId(label string) (int64, bool) {
+	if !strings.HasPrefix(label, syncerLabelPrefix) {
+		return 0, false
+	}
+
+	// rand:sync_type:src:dest:commit_seq
+	parts := strings.Split(strings.TrimPrefix(label, syncerLabelPrefix), ":")
+	if len(parts) != 5 {
+		return 0, false
+	}
+	dest := parts[3]
+	if index := strings.Index(dest, "_"); index >= 0 {
+		dest = dest[:index]
 	}
+	destDbId, err := strconv.ParseInt(dest, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return destDbId, true
+}
+
+// Whether the upsert is written into the src db by a syncer job, eg. the job of the
+// opposite direction in bidirectional sync.
+func (j *Job) isSyncerOriginatedUpsert(upsert *record.Upsert) bool {
+	destDbId, ok := ParseSyncerLabelDestDbId(upsert.Label)
+	return ok && destDbId == j.Src.DbId
 }
 
 func (j *Job) isMaterializedViewTable(srcTableId int64) (bool, error) {
@@ -1353,7 +1700,7 @@ func (j *Job) getDestTableIdBySrc(srcTableId int64) (int64, error) {
 		return 0, err
 	} else if srcTable.Type == record.TableTypeMaterializedView {
 		return 0, ErrMaterializedViewTable
-	} else if destTableId, err := j.destMeta.GetTableId(srcTable.Name); err != nil {
+	} else if destTableId, err := j.destMeta.GetTableId(j.destTableName(srcTable.Name)); err != nil {
 		return 0, err
 	} else {
 		j.progress.TableMapping[srcTableId] = destTableId
@@ -1421,6 +1768,168 @@ func (j *Job) getDbSyncTableRecords(upsert *record.Upsert) []*record.TableRecord
 	return tableRecords
 }
 
+func (j *Job) isMultiTableSync() bool {
+	return j.SyncType == DBSync && len(j.Extra.Tables) > 0
+}
+
+// Whether the job only syncs a part of the tables of the db.
+func (j *Job) hasTableSelector() bool {
+	return j.isMultiTableSync() || !j.Extra.TableFilter.IsEmpty()
+}
+
+// Whether the src table is selected by the table list and the table filter of the job.
+func (j *Job) isTableSelected(table string) bool {
+	if j.isMultiTableSync() {
+		found := false
+		for _, name := range j.Extra.Tables {
+			if name == table {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return j.Extra.TableFilter.Match(table)
+}
+
+// Whether the tables of the multi-table sync are tracked by the ids, the jobs created before
+// the ids are saved are tracked by the names.
+func (j *Job) hasListedTableIds() bool {
+	return j.isMultiTableSync() && len(j.Extra.TableIds) == len(j.Extra.Tables)
+}
+
+// The index of the src table in the tables of the multi-table sync, -1 if it is not listed.
+func (j *Job) listedTableIndex(srcTableId int64) int {
+	for i, tableId := range j.Extra.TableIds {
+		if tableId == srcTableId {
+			return i
+		}
+	}
+	return -1
+}
+
+// Track the listed table by the new id or the new name, the caller must hold the job lock.
+func (j *Job) updateListedTable(index int, tableId int64, tableName string) error {
+	if j.Extra.TableIds[index] == tableId && j.Extra.Tables[index] == tableName {
+		return nil
+	}
+
+	log.Infof("job %s tracks the listed table %s (%d) as %s (%d)",
+		j.Name, j.Extra.Tables[index], j.Extra.TableIds[index], tableName, tableId)
+	savedExtra := j.Extra
+	j.Extra.TableIds = append([]int64(nil), j.Extra.TableIds...)
+	j.Extra.Tables = append([]string(nil), j.Extra.Tables...)
+	j.Extra.TableIds[index] = tableId
+	j.Extra.Tables[index] = tableName
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		return err
+	}
+	return nil
+}
+
+// Track the listed table by the id of the table with the same name, the caller must hold
+// the job lock.
+func (j *Job) updateListedTableId(tableName string, tableId int64) error {
+	if !j.hasListedTableIds() {
+		return nil
+	}
+	for i, table := range j.Extra.Tables {
+		if table == tableName {
+			return j.updateListedTable(i, tableId, tableName)
+		}
+	}
+	return nil
+}
+
+// Filter the table records which are not selected by the job.
+func (j *Job) filterTableRecords(tableRecords []*record.TableRecord) ([]*record.TableRecord, error) {
+	if !j.hasTableSelector() {
+		return tableRecords, nil
+	}
+
+	filtered := make([]*record.TableRecord, 0, len(tableRecords))
+	for _, tableRecord := range tableRecords {
+		if skip, err := j.isTableFilteredOut(tableRecord.Id); err != nil {
+			return nil, err
+		} else if !skip {
+			filtered = append(filtered, tableRecord)
+		}
+	}
+	return filtered, nil
+}
+
+// Whether the src table is not selected by a db sync job.
+func (j *Job) isTableFilteredOut(srcTableId int64) (bool, error) {
+	if j.SyncType != DBSync || !j.hasTableSelector() {
+		return false, nil
+	}
+
+	if j.hasListedTableIds() {
+		index := j.listedTableIndex(srcTableId)
+		if index < 0 {
+			return true, nil
+		}
+		return !j.Extra.TableFilter.Match(j.Extra.Tables[index]), nil
+	}
+
+	tableName, err := j.srcTableName(srcTableId)
+	if err != nil {
+		return false, err
+	}
+
+	// The table might be dropped, let the binlog handler decide it.
+	if tableName == "" {
+		return false, nil
+	}
+	return !j.isTableSelected(tableName), nil
+}
+
+// Get the name of the src table, empty if the table is not found.
+func (j *Job) srcTableName(srcTableId int64) (string, error) {
+	if j.SyncType == TableSync {
+		return j.Src.Table, nil
+	}
+
+	if tableName, ok := j.progress.TableNameMapping[srcTableId]; ok {
+		return tableName, nil
+	}
+	if table, ok := j.srcMeta.DirtyGetTables()[srcTableId]; ok {
+		return table.Name, nil
+	}
+	return j.srcMeta.GetTableNameById(srcTableId)
+}
+
+// Whether all tables of the binlog are not selected by a db sync job.
+//
+// The upsert, create table and drop table binlogs are filtered in the handlers, since the
+// table ids of them are not enough to decide it.
+func (j *Job) isBinlogFilteredOut(binlog *festruct.TBinlog) (bool, error) {
+	if j.SyncType != DBSync || !j.hasTableSelector() {
+		return false, nil
+	}
+
+	switch binlog.GetType() {
+	case festruct.TBinlogType_UPSERT, festruct.TBinlogType_CREATE_TABLE, festruct.TBinlogType_DROP_TABLE:
+		return false, nil
+	}
+
+	tableIds := binlog.GetTableIds()
+	if len(tableIds) == 0 {
+		return false, nil
+	}
+	for _, tableId := range tableIds {
+		if skip, err := j.isTableFilteredOut(tableId); err != nil {
+			return false, err
+		} else if !skip {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func (j *Job) getStidsByDestTableId(destTableId int64, tableRecords []*record.TableRecord, stidMaps map[int64]int64) ([]int64, error) {
 	destStids := make([]int64, 0, 1)
 	uniqStids := make(map[int64]int64)
@@ -1461,6 +1970,10 @@ func (j *Job) getRelatedTableRecords(upsert *record.Upsert) ([]*record.TableReco
 	switch j.SyncType {
 	case DBSync:
 		records := j.getDbSyncTableRecords(upsert)
+		records, err := j.filterTableRecords(records)
+		if err != nil {
+			return nil, err
+		}
 		if len(records) == 0 {
 			return nil, nil
 		}
@@ -1482,6 +1995,16 @@ func (j *Job) getRelatedTableRecords(upsert *record.Upsert) ([]*record.TableReco
 
 // Table ingestBinlog
 func (j *Job) ingestBinlog(txnId int64, tableRecords []*record.TableRecord) ([]*ttypes.TTabletCommitInfo, error) {
+	commitInfos, err := j.doIngestBinlog(txnId, tableRecords)
+	if err != nil {
+		return nil, j.newRepairableError(err, tableRecords)
+	}
+	return commitInfos, nil
+}
+
+// Ingest the binlog without annotating the tables to repair, which reads the meta caches of
+// the job, so it is safe to be called concurrently.
+func (j *Job) doIngestBinlog(txnId int64, tableRecords []*record.TableRecord) ([]*ttypes.TTabletCommitInfo, error) {
 	log.Tracef("txn %d ingest binlog", txnId)
 
 	job, err := j.jobFactory.CreateJob(NewIngestContext(txnId, tableRecords, j.progress.TableMapping), j, "IngestBinlog")
@@ -1517,7 +2040,7 @@ func (j *Job) ingestBinlogForTxnInsert(txnId int64, tableRecords []*record.Table
 
 	job.Run()
 	if err := job.Error(); err != nil {
-		return nil, err
+		return nil, j.newRepairableError(err, tableRecords)
 	}
 
 	stidToCommitInfos := ingestBinlogJob.SubTxnToCommitInfos()
@@ -1544,6 +2067,40 @@ func (j *Job) ingestBinlogForTxnInsert(txnId int64, tableRecords []*record.Table
 	return subTxnInfos, nil
 }
 
+// Get the table records of the upsert to sync, and the corresponding dest table ids.
+func (j *Job) getUpsertTableRecords(upsert *record.Upsert) ([]*record.TableRecord, []int64, error) {
+	tableRecords, err := j.getRelatedTableRecords(upsert)
+	if err != nil {
+		log.Errorf("get related table records failed, err: %+v", err)
+		return nil, nil, err
+	}
+	if len(tableRecords) == 0 {
+		return nil, nil, nil
+	}
+
+	destTableIds := make([]int64, 0, len(tableRecords))
+	if j.SyncType == DBSync {
+		savedRecords := make([]*record.TableRecord, 0, len(tableRecords))
+		for _, tableRecord := range tableRecords {
+			if isAsyncMv, err := j.isMaterializedViewTable(tableRecord.Id); err != nil {
+				return nil, nil, err
+			} else if isAsyncMv {
+				// ignore the upsert of materialized view table.
+				continue
+			} else if destTableId, err := j.getDestTableIdBySrc(tableRecord.Id); err != nil {
+				return nil, nil, err
+			} else {
+				savedRecords = append(savedRecords, tableRecord)
+				destTableIds = append(destTableIds, destTableId)
+			}
+		}
+		tableRecords = savedRecords
+	} else {
+		destTableIds = append(destTableIds, j.Dest.TableId)
+	}
+	return tableRecords, destTableIds, nil
+}
+
 func (j *Job) handleUpsertWithRetry(binlog *festruct.TBinlog) error {
 	err := j.handleUpsert(binlog)
 	if !xerror.IsCategory(err, xerror.Meta) {
@@ -1633,6 +2190,12 @@ func (j *Job) handleUpsert(binlog *festruct.TBinlog) error {
 		}
 		log.Tracef("upsert: %v", upsert)
 
+		if j.Extra.Bidirectional && j.isSyncerOriginatedUpsert(upsert) {
+			log.Debugf("skip the upsert written by syncer, commit seq: %d, label: %s",
+				upsert.CommitSeq, upsert.Label)
+			return nil
+		}
+
 		// Step 1: get related tableRecords
 		var isTxnInsert bool = false
 		if len(upsert.Stids) > 0 {
@@ -1643,9 +2206,8 @@ func (j *Job) handleUpsert(binlog *festruct.TBinlog) error {
 			isTxnInsert = true
 		}
 
-		tableRecords, err := j.getRelatedTableRecords(upsert)
+		tableRecords, destTableIds, err := j.getUpsertTableRecords(upsert)
 		if err != nil {
-			log.Errorf("get related table records failed, err: %+v", err)
 			return err
 		}
 		if len(tableRecords) == 0 {
@@ -1653,31 +2215,6 @@ func (j *Job) handleUpsert(binlog *festruct.TBinlog) error {
 			return nil
 		}
 
-		destTableIds := make([]int64, 0, len(tableRecords))
-		if j.SyncType == DBSync {
-			savedRecords := make([]*record.TableRecord, 0, len(tableRecords))
-			for _, tableRecord := range tableRecords {
-				if isAsyncMv, err := j.isMaterializedViewTable(tableRecord.Id); err != nil {
-					return err
-				} else if isAsyncMv {
-					// ignore the upsert of materialized view table.
-					continue
-				} else if destTableId, err := j.getDestTableIdBySrc(tableRecord.Id); err != nil {
-					return err
-				} else {
-					savedRecords = append(savedRecords, tableRecord)
-					destTableIds = append(destTableIds, destTableId)
-				}
-			}
-			tableRecords = savedRecords
-		} else {
-			destTableIds = append(destTableIds, j.Dest.TableId)
-		}
-		if len(tableRecords) == 0 {
-			log.Debug("no related table records")
-			return nil
-		}
-
 		log.Debugf("handle upsert, table records: %v", tableRecords)
 		inMemoryData := &inMemoryData{
 			CommitSeq:    upsert.CommitSeq,
@@ -1944,6 +2481,9 @@ func (j *Job) handleDropPartition(binlog *festruct.TBinlog) error {
 	if err != nil {
 		return err
 	}
+	if j.isArchivePartitions() {
+		return j.archivePartition(destTableName, dropPartition, binlog.GetCommitSeq())
+	}
 	return j.IDest.DropPartition(destTableName, dropPartition)
 }
 
@@ -1971,8 +2511,24 @@ func (j *Job) handleCreateTable(binlog *festruct.TBinlog) error {
 		return nil
 	}
 
+	if len(createTable.TableName) > 0 {
+		if !j.isTableSelected(createTable.TableName) {
+			log.Infof("skip create table %s, it is not selected by the job", createTable.TableName)
+			return nil
+		}
+		// the listed table is created again with a new id
+		if err := j.updateListedTableId(createTable.TableName, createTable.TableId); err != nil {
+			return err
+		}
+	} else if skip, err := j.isTableFilteredOut(createTable.TableId); err != nil {
+		return err
+	} else if skip {
+		log.Infof("skip create table %d, it is not selected by the job", createTable.TableId)
+		return nil
+	}
+
 	if featureCreateViewDropExists {
-		tableName := strings.TrimSpace(createTable.TableName)
+		tableName := j.destTableName(strings.TrimSpace(createTable.TableName))
 		if createTable.IsCreateView() && len(tableName) > 0 {
 			// drop view if exists
 			log.Infof("feature_create_view_drop_exists is enabled, try drop view %s before creating", tableName)
@@ -1995,7 +2551,7 @@ func (j *Job) handleCreateTable(binlog *festruct.TBinlog) error {
 	//
 	// See test_cds_fullsync_tbl_drop_create.groovy for details
 	if j.SyncType == DBSync && !createTable.IsCreateView() {
-		if exists, err := j.IDest.CheckTableExistsByName(createTable.TableName); err != nil {
+		if exists, err := j.IDest.CheckTableExistsByName(j.destTableName(createTable.TableName)); err != nil {
 			return err
 		} else if exists {
 			log.Warnf("the dest table %s already exists, force partial snapshot, commit seq: %d",
@@ -2009,6 +2565,12 @@ func (j *Job) handleCreateTable(binlog *festruct.TBinlog) error {
 		createTable.Sql = FilterStorageMediumFromCreateTableSql(createTable.Sql)
 	}
 
+	if j.isDBSyncWithRename() && len(createTable.TableName) > 0 {
+		if createTable.Sql, err = base.RenameCreateTableOrViewSql(createTable.Sql, j.destTableName(createTable.TableName)); err != nil {
+			return err
+		}
+	}
+
 	if err = j.IDest.CreateTableOrView(createTable, j.Src.Database); err != nil {
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "Can not found function") {
@@ -2043,7 +2605,7 @@ func (j *Job) handleCreateTable(binlog *festruct.TBinlog) error {
 	}
 
 	var destTableId int64
-	destTableId, err = j.destMeta.GetTableId(srcTableName)
+	destTableId, err = j.destMeta.GetTableId(j.destTableName(srcTableName))
 	if err != nil {
 		return err
 	}
@@ -2075,6 +2637,11 @@ func (j *Job) handleDropTable(binlog *festruct.TBinlog) error {
 		return err
 	}
 
+	if len(dropTable.TableName) > 0 && !j.isTableSelected(dropTable.TableName) {
+		log.Infof("skip drop table %s, it is not selected by the job", dropTable.TableName)
+		return nil
+	}
+
 	if !dropTable.IsView {
 		if _, ok := j.progress.TableMapping[dropTable.TableId]; !ok {
 			log.Warnf("the dest table is not found, skip drop table binlog, src table id: %d, commit seq: %d",
@@ -2104,11 +2671,16 @@ func (j *Job) handleDropTable(binlog *festruct.TBinlog) error {
 
 		tableName = srcTable.Name
 	}
+	tableName = j.destTableName(tableName)
 
 	if dropTable.IsView {
 		if err = j.IDest.DropView(tableName); err != nil {
 			return xerror.Wrapf(err, xerror.Normal, "drop view %s", tableName)
 		}
+	} else if j.isSoftDelete() {
+		if err = j.archiveTable(tableName, binlog.GetCommitSeq()); err != nil {
+			return err
+		}
 	} else {
 		if err = j.IDest.DropTable(tableName, true); err != nil {
 			// In apache/doris/common/ErrorCode.java
@@ -2245,7 +2817,7 @@ func (j *Job) handleSchemaChange(alterJob *record.AlterJobV2) error {
 	if j.SyncType == TableSync {
 		destTableName = j.Dest.Table
 	} else {
-		destTableName = alterJob.TableName
+		destTableName = j.destTableName(alterJob.TableName)
 	}
 
 	if featureSchemaChangePartialSync && alterJob.Type == record.ALTER_JOB_SCHEMA_CHANGE {
@@ -2310,6 +2882,10 @@ func (j *Job) handleLightningSchemaChange(binlog *festruct.TBinlog) error {
 	tableAlias := ""
 	if j.isTableSyncWithAlias() {
 		tableAlias = j.Dest.Table
+	} else if j.isDBSyncWithRename() {
+		if tableAlias, err = j.getDestNameBySrcId(lightningSchemaChange.TableId); err != nil {
+			return err
+		}
 	}
 	return j.IDest.LightningSchemaChange(j.Src.Database, tableAlias, lightningSchemaChange)
 }
@@ -2500,6 +3076,14 @@ func (j *Job) handleRenameTableRecord(commitSeq int64, renameTable *record.Renam
 		renameTable.OldTableName = destTableName
 	}
 
+	srcNewTableName := renameTable.NewTableName
+	if j.isDBSyncWithRename() && renameTable.NewTableName != "" {
+		renamed := *renameTable
+		renamed.OldTableName = destTableName
+		renamed.NewTableName = j.destTableName(renameTable.NewTableName)
+		renameTable = &renamed
+	}
+
 	err = j.IDest.RenameTable(destTableName, renameTable)
 	if err != nil {
 		return err
@@ -2509,8 +3093,13 @@ func (j *Job) handleRenameTableRecord(commitSeq int64, renameTable *record.Renam
 	if j.progress.TableNameMapping == nil {
 		j.progress.TableNameMapping = make(map[int64]string)
 	}
-	j.progress.TableNameMapping[renameTable.TableId] = renameTable.NewTableName
+	j.progress.TableNameMapping[renameTable.TableId] = srcNewTableName
 
+	if j.hasListedTableIds() && srcNewTableName != "" {
+		if index := j.listedTableIndex(renameTable.TableId); index >= 0 {
+			return j.updateListedTable(index, renameTable.TableId, srcNewTableName)
+		}
+	}
 	return nil
 }
 
@@ -2571,8 +3160,8 @@ func (j *Job) handleReplaceTableRecord(commitSeq int64, record *record.ReplaceTa
 		}
 	}
 
-	toName := record.OriginTableName
-	fromName := record.NewTableName
+	toName := j.destTableName(record.OriginTableName)
+	fromName := j.destTableName(record.NewTableName)
 	if err := j.IDest.ReplaceTable(fromName, toName, record.SwapTable); err != nil {
 		return err
 	}
@@ -2943,11 +3532,69 @@ func (j *Job) handleBarrier(binlog *festruct.TBinlog) error {
 func (j *Job) handleBinlogs(binlogs []*festruct.TBinlog) (error, bool) {
 	log.Tracef("handle binlogs, binlogs size: %d", len(binlogs))
 
-	for _, binlog := range binlogs {
-		// Step 1: dispatch handle binlog
-		if err := j.handleBinlog(binlog); err != nil {
-			log.Errorf("handle binlog failed, prevCommitSeq: %d, commitSeq: %d, binlog type: %s, binlog data: %s",
-				j.progress.PrevCommitSeq, j.progress.CommitSeq, binlog.GetType(), binlog.GetData())
+	for i := 0; i < len(binlogs); i++ {
+		binlog := binlogs[i]
+
+		// The binlog has been applied, it is fetched again for the sinks lagging behind.
+		if len(j.sinks) > 0 && binlog.GetCommitSeq() <= j.progress.CommitSeq {
+			action, _, err := j.checkDDLPolicy(binlog)
+			if err != nil {
+				return err, false
+			}
+			if err := j.exportBinlogs([]*festruct.TBinlog{binlog}, action != DDLActionApply); err != nil {
+				return err, false
+			}
+			continue
+		}
+
+		// Step 0: don't apply anything past the stop-at target
+		if j.isBeyondStopAt(binlog) {
+			return j.reachStopAt(), true
+		}
+
+		// Step 0.1: the binlogs out of the sync windows or over the throttle are applied later
+		if !j.allowApplyBinlog(binlog, 0) {
+			return nil, true
+		}
+
+		// Step 0.2: check the ddl policy, the binlog might be skipped or wait for the approval
+		action, tables, err := j.checkDDLPolicy(binlog)
+		if err != nil {
+			return err, false
+		}
+		if action == DDLActionPause {
+			return j.holdForApproval(binlog, tables), true
+		}
+
+		// Step 1: dispatch handle binlog, the consecutive upserts might be applied in one txn
+		appliedBinlogs := []*festruct.TBinlog{binlog}
+		if action == DDLActionSkip || action == DDLActionSkipAndRecord {
+			if err := j.skipBinlogByPolicy(binlog, action, tables); err != nil {
+				return err, false
+			}
+		} else if batch, upserts := j.collectParallelUpserts(binlogs[i:]); len(batch) > 0 {
+			if err := j.handleUpsertsInParallel(batch, upserts); err != nil {
+				log.Errorf("handle upserts in parallel failed, prevCommitSeq: %d, commitSeq: %d, batch size: %d",
+					j.progress.PrevCommitSeq, j.progress.CommitSeq, len(batch))
+				return err, false
+			}
+			appliedBinlogs = batch
+			i += len(batch) - 1
+			binlog = binlogs[i]
+		} else if batch, upserts := j.collectUpsertBatch(binlogs[i:]); len(batch) > 0 {
+			if err := j.handleUpsertBatch(batch, upserts); err != nil {
+				log.Errorf("handle upsert batch failed, prevCommitSeq: %d, commitSeq: %d, batch size: %d",
+					j.progress.PrevCommitSeq, j.progress.CommitSeq, len(batch))
+				return err, false
+			}
+			appliedBinlogs = batch
+			i += len(batch) - 1
+			binlog = binlogs[i]
+		} else if err := j.handleBinlog(binlog); err != nil {
+			if !isFullSyncDeferred(err) {
+				log.Errorf("handle binlog failed, prevCommitSeq: %d, commitSeq: %d, binlog type: %s, binlog data: %s",
+					j.progress.PrevCommitSeq, j.progress.CommitSeq, binlog.GetType(), binlog.GetData())
+			}
 			return err, false
 		}
 
@@ -2980,6 +3627,14 @@ func (j *Job) handleBinlogs(binlogs []*festruct.TBinlog) (error, bool) {
 		if !j.progress.IsDone() {
 			j.progress.Done()
 		}
+		j.recordAppliedBinlogs(appliedBinlogs)
+		if err := j.exportBinlogs(appliedBinlogs, action != DDLActionApply); err != nil {
+			return err, false
+		}
+
+		if j.Extra.StopAtCommitSeq > 0 && commitSeq >= j.Extra.StopAtCommitSeq {
+			return j.reachStopAt(), true
+		}
 	}
 	return nil, false
 }
@@ -3012,6 +3667,14 @@ func (j *Job) handleBinlog(binlog *festruct.TBinlog) error {
 		return xerror.Errorf(xerror.Normal, "fail to handle binlog by failpoint")
 	}
 
+	if skip, err := j.isBinlogFilteredOut(binlog); err != nil {
+		return err
+	} else if skip {
+		log.Infof("skip binlog %d, the tables %v are not selected by the job, binlog type: %s",
+			binlog.GetCommitSeq(), binlog.GetTableIds(), binlog.GetType())
+		return nil
+	}
+
 	switch binlog.GetType() {
 	case festruct.TBinlogType_UPSERT:
 		return j.handleUpsertWithRetry(binlog)
@@ -3089,6 +3752,11 @@ func (j *Job) incrementalSync() error {
 		return j.recoverIncrementalSync()
 	}
 
+	if j.Extra.StopAtCommitSeq > 0 && j.progress.CommitSeq >= j.Extra.StopAtCommitSeq {
+		// eg. the snapshot of the fullsync is taken after the target.
+		return j.reachStopAt()
+	}
+
 	// Force fullsync unconditionally
 	if j.Extra.SkipBinlog && j.Extra.SkipBy == SkipByFullSync {
 		info := fmt.Sprintf("the user required skipping the binlog, commit seq %d", j.progress.CommitSeq)
@@ -3107,11 +3775,22 @@ func (j *Job) incrementalSync() error {
 
 	// Step 2: handle all binlog
 	for {
-		// The CommitSeq is equals to PrevCommitSeq in here.
-		commitSeq := j.progress.CommitSeq
+		// The CommitSeq is equals to PrevCommitSeq in here, the binlogs before it are fetched
+		// again if any sink lags behind.
+		commitSeq := j.sinkFetchCommitSeq()
 		log.Tracef("src: %s, commitSeq: %d", src, commitSeq)
 
-		getBinlogResp, err := srcRpc.GetBinlog(src, commitSeq)
+		// The time of src before getting the binlogs, all binlogs before it are synced if the
+		// job has caught up.
+		var srcTimestamp int64
+		if j.Extra.StopAtTimestamp > 0 {
+			if srcTimestamp, err = j.ISrc.GetCurrentTimestamp(); err != nil {
+				log.Warnf("get the current timestamp of src failed, job: %s, err: %+v", j.Name, err)
+				srcTimestamp = 0
+			}
+		}
+
+		getBinlogResp, err := j.getBinlog(srcRpc, commitSeq)
 		if err != nil {
 			return err
 		}
@@ -3123,6 +3802,9 @@ func (j *Job) incrementalSync() error {
 		case tstatus.TStatusCode_OK:
 		case tstatus.TStatusCode_BINLOG_TOO_OLD_COMMIT_SEQ:
 		case tstatus.TStatusCode_BINLOG_TOO_NEW_COMMIT_SEQ:
+			if j.isCaughtUpStopAt(srcTimestamp) {
+				return j.reachStopAt()
+			}
 			return nil
 		case tstatus.TStatusCode_BINLOG_DISABLE:
 			return xerror.Errorf(xerror.Normal, "binlog is disabled")
@@ -3261,6 +3943,12 @@ func (j *Job) handleError(err error) error {
 	}
 
 	if xerr.Category() == xerror.Meta {
+		if healed, healErr := j.selfHeal(err); healed {
+			return nil
+		} else if errors.Is(healErr, errSelfHealBackoff) {
+			log.Errorf("job %s meets meta error, %v, err: %+v", j.Name, healErr, err)
+			return nil
+		}
 		info := fmt.Sprintf("receive meta category error, make new snapshot, job: %s, err: %v", j.Name, err)
 		log.Warnf("%s", info)
 		_ = j.newSnapshot(j.progress.CommitSeq, info)
@@ -3287,6 +3975,10 @@ func (j *Job) run() {
 			return
 
 		case <-ticker.C:
+			j.purgeArchivedTables()
+			j.maybeCheckSchemaDrift()
+			j.maybeCheckBinlogRetention()
+
 			// loop to print error, not panic, waiting for user to pause/stop/remove Job
 			if j.getJobState() != JobRunning {
 				break
@@ -3300,6 +3992,9 @@ func (j *Job) run() {
 			err := j.sync()
 			if err == nil {
 				break
+			} else if isFullSyncDeferred(err) {
+				log.Infof("job %s defers the full sync: %v", j.Name, err)
+				break
 			}
 
 			log.Warnf("job sync failed, job: %s, err: %+v", j.Name, err)
@@ -3309,6 +4004,10 @@ func (j *Job) run() {
 }
 
 func (j *Job) newSnapshot(commitSeq int64, fullSyncInfo string) error {
+	if err := j.checkFullSyncPolicy(commitSeq, fullSyncInfo); err != nil {
+		return err
+	}
+
 	log.Infof("new snapshot, commitSeq: %d, prevCommitSeq: %d, prevSyncState: %s, prevSubSyncState: %s",
 		commitSeq, j.progress.PrevCommitSeq, j.progress.SyncState, j.progress.SubSyncState)
 
@@ -3318,6 +4017,11 @@ func (j *Job) newSnapshot(commitSeq int64, fullSyncInfo string) error {
 
 	j.progress.PartialSyncData = nil
 	j.progress.TableAliases = nil
+	if len(j.progress.SinkOffsets) > 0 {
+		// The binlogs before the snapshot might be gone, the sinks start from the snapshot.
+		log.Warnf("reset the sink offsets %v of job %s for the fullsync", j.progress.SinkOffsets, j.Name)
+		j.progress.SinkOffsets = nil
+	}
 	j.progress.SyncId += 1
 	switch j.SyncType {
 	case TableSync:
@@ -3385,11 +4089,8 @@ func (j *Job) newPartialSnapshot(tableId int64, table string, partitions []strin
 	}
 }
 
-// run job
-func (j *Job) Run() error {
-	gls.ResetGls(gls.GoID(), map[interface{}]interface{}{})
-	gls.Set("job", j.Name)
-
+// Recover the progress of the job, or create it for the new job.
+func (j *Job) initProgress() error {
 	// retry 3 times to check IsProgressExist
 	var isProgressExist bool
 	var err error
@@ -3416,6 +4117,22 @@ func (j *Job) Run() error {
 			return err
 		}
 	}
+	return nil
+}
+
+// run job
+func (j *Job) Run() error {
+	gls.ResetGls(gls.GoID(), map[interface{}]interface{}{})
+	gls.Set("job", j.Name)
+	defer j.markFanoutsStopped()
+
+	if j.parent != nil {
+		if err := j.initFanoutProgress(); err != nil {
+			return err
+		}
+	} else if err := j.initProgress(); err != nil {
+		return err
+	}
 
 	// Hack: for drop table
 	if j.SyncType == DBSync {
@@ -3423,7 +4140,14 @@ func (j *Job) Run() error {
 		j.destMeta.ClearTablesCache()
 	}
 
+	if j.parent != nil {
+		j.recoverFanoutDestTableId()
+	}
+
+	waitFanouts := j.runFanouts()
 	j.run()
+	waitFanouts()
+	j.closeSinks()
 	return nil
 }
 
@@ -3447,6 +4171,10 @@ func (j *Job) desyncDB() error {
 
 	tableNames := []string{}
 	for _, tableMeta := range tables {
+		srcTableName, ok := j.Extra.TableRename.SrcName(tableMeta.Name)
+		if !ok || (j.hasTableSelector() && !j.isTableSelected(srcTableName)) {
+			continue
+		}
 		tableNames = append(tableNames, tableMeta.Name)
 	}
 
@@ -3454,6 +4182,12 @@ func (j *Job) desyncDB() error {
 }
 
 func (j *Job) Desync() error {
+	for _, fanout := range j.fanouts {
+		if err := fanout.Desync(); err != nil {
+			return err
+		}
+	}
+
 	if j.SyncType == DBSync {
 		return j.desyncDB()
 	} else {
@@ -3463,11 +4197,17 @@ func (j *Job) Desync() error {
 
 // stop job
 func (j *Job) Stop() {
+	for _, fanout := range j.fanouts {
+		fanout.Stop()
+	}
 	close(j.stop)
 }
 
 // delete job
 func (j *Job) Delete() {
+	for _, fanout := range j.fanouts {
+		fanout.Delete()
+	}
 	j.isDeleted.Store(true)
 	close(j.stop)
 }
@@ -3478,6 +4218,12 @@ func (j *Job) maybeDeleted() bool {
 	}
 
 	// job had been deleted
+	j.releaseBinlogLease()
+	if j.parent != nil {
+		// The progress of the fan-out job is removed with the job.
+		log.Infof("fan-out job deleted, job: %s", j.Name)
+		return true
+	}
 	log.Infof("job deleted, job: %s, remove in db", j.Name)
 	if err := j.db.RemoveJob(j.Name); err != nil {
 		log.Errorf("remove job failed, job: %s, err: %+v", j.Name, err)
@@ -3531,11 +4277,16 @@ func (j *Job) FirstRun() error {
 	} else if !src_db_exists {
 		return xerror.Errorf(xerror.Normal, "src database %s not exists", j.Src.Database)
 	}
+	// The multi-table sync requires the binlog of the listed tables only, if the database
+	// doesn't enable binlog.
+	srcDbBinlogEnabled := false
 	if j.SyncType == DBSync {
 		if enable, err := j.ISrc.IsDatabaseEnableBinlog(); err != nil {
 			return err
-		} else if !enable {
+		} else if !enable && !j.isMultiTableSync() {
 			return xerror.Errorf(xerror.Normal, "src database %s not enable binlog", j.Src.Database)
+		} else {
+			srcDbBinlogEnabled = enable
 		}
 	}
 	if srcDbId, err := j.srcMeta.GetDbId(); err != nil {
@@ -3563,6 +4314,38 @@ func (j *Job) FirstRun() error {
 		} else {
 			j.Src.TableId = srcTableId
 		}
+	} else if j.isMultiTableSync() {
+		tableIds := make([]int64, 0, len(j.Extra.Tables))
+		for _, table := range j.Extra.Tables {
+			if exists, err := j.ISrc.CheckTableExistsByName(table); err != nil {
+				return err
+			} else if !exists {
+				return xerror.Errorf(xerror.Normal, "src table %s.%s not exists", j.Src.Database, table)
+			}
+
+			tableSpec := j.Src
+			tableSpec.Table = table
+			tableSpecer := j.factory.NewSpecer(&tableSpec)
+			if invalidProperty, err := tableSpecer.CheckTablePropertyValid(); err != nil {
+				return err
+			} else if len(invalidProperty) != 0 {
+				return xerror.Errorf(xerror.Normal, "src table %s.%s only support property: %s", j.Src.Database, table, strings.Join(invalidProperty, ", "))
+			}
+			if !srcDbBinlogEnabled {
+				if properties, err := tableSpecer.GetBinlogProperties(); err != nil {
+					return err
+				} else if !properties.Enable {
+					return xerror.Errorf(xerror.Normal, "src table %s.%s not enable binlog", j.Src.Database, table)
+				}
+			}
+
+			if tableId, err := j.srcMeta.GetTableId(table); err != nil {
+				return err
+			} else {
+				tableIds = append(tableIds, tableId)
+			}
+		}
+		j.Extra.TableIds = tableIds
 	}
 
 	// Step 4: check dest database && table exists
@@ -3589,6 +4372,20 @@ func (j *Job) FirstRun() error {
 		if dest_table_exists {
 			return xerror.Errorf(xerror.Normal, "dest table %s.%s already exists", j.Dest.Database, j.Dest.Table)
 		}
+	} else if j.isMultiTableSync() && !j.Extra.allowTableExists {
+		for _, table := range j.Extra.Tables {
+			destTable := j.destTableName(table)
+			if exists, err := j.IDest.CheckTableExistsByName(destTable); err != nil {
+				return err
+			} else if exists {
+				return xerror.Errorf(xerror.Normal, "dest table %s.%s already exists", j.Dest.Database, destTable)
+			}
+		}
+	}
+
+	// Step 5: check the extra destinations
+	if err := j.firstRunFanouts(); err != nil {
+		return err
 	}
 
 	return nil
@@ -3601,6 +4398,18 @@ func (j *Job) getJobState() JobState {
 	return j.State
 }
 
+// The state of the job after it is resumed, the job paused while waiting for approval keeps
+// waiting for the pending binlog.
+func (j *Job) resumeState() JobState {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.Extra.PendingApproval != nil {
+		return JobWaitingApproval
+	}
+	return JobRunning
+}
+
 func (j *Job) changeJobState(state JobState) error {
 	j.lock.Lock()
 	defer j.lock.Unlock()
@@ -3623,13 +4432,44 @@ func (j *Job) changeJobState(state JobState) error {
 func (j *Job) Pause() error {
 	log.Infof("pause job %s", j.Name)
 
-	return j.changeJobState(JobPaused)
+	if err := j.changeJobState(JobPaused); err != nil {
+		return err
+	}
+	for _, fanout := range j.fanouts {
+		if err := fanout.Pause(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (j *Job) Resume() error {
 	log.Infof("resume job %s", j.Name)
 
-	return j.changeJobState(JobRunning)
+	switch j.getJobState() {
+	case JobReachedTarget:
+		return xerror.Errorf(xerror.Normal, "job %s has reached the stop at target, update the target to continue", j.Name)
+	case JobWaitingApproval:
+		// keep waiting, use approve_binlog or reject_binlog to decide the pending binlog
+	case JobFullSyncPendingApproval:
+		// resume without the full sync, use approve_fullsync to start it
+		if err := j.discardPendingFullSync(); err != nil {
+			return err
+		}
+	default:
+		if err := j.changeJobState(j.resumeState()); err != nil {
+			return err
+		}
+	}
+	for _, fanout := range j.fanouts {
+		if fanout.getJobState() == JobReachedTarget {
+			continue
+		}
+		if err := fanout.Resume(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type RawJobStatus struct {
@@ -3648,17 +4488,24 @@ type JobStatus struct {
 	Name          string `json:"name"`
 	State         string `json:"state"`
 	ProgressState string `json:"progress_state"`
+
+	// The status of the fan-out jobs of the extra destinations.
+	Dests []*JobStatus `json:"dests,omitempty"`
 }
 
 func (j *Job) Status() *JobStatus {
 	state := JobState(atomic.LoadInt32(&j.rawStatus.state)).String()
 	progressState := SyncState(atomic.LoadInt32(&j.rawStatus.progressState)).String()
 
-	return &JobStatus{
+	status := &JobStatus{
 		Name:          j.Name,
 		State:         state,
 		ProgressState: progressState,
 	}
+	for _, fanout := range j.fanouts {
+		status.Dests = append(status.Dests, fanout.Status())
+	}
+	return status
 }
 
 func (j *Job) UpdateHostMapping(srcHostMaps, destHostMaps map[string]string) error {
@@ -3716,6 +4563,73 @@ func (j *Job) SkipBinlog(skipCommitSeq int64, skipBy string) error {
 	return nil
 }
 
+func (j *Job) isBeyondStopAt(binlog *festruct.TBinlog) bool {
+	if j.Extra.StopAtCommitSeq > 0 && binlog.GetCommitSeq() > j.Extra.StopAtCommitSeq {
+		return true
+	}
+	return j.Extra.StopAtTimestamp > 0 && binlog.GetTimestamp() > j.Extra.StopAtTimestamp
+}
+
+// Whether the job has reached the stop-at timestamp without any binlog beyond it, since the
+// job has caught up with the src at the src timestamp.
+func (j *Job) isCaughtUpStopAt(srcTimestamp int64) bool {
+	return j.Extra.StopAtTimestamp > 0 && srcTimestamp > j.Extra.StopAtTimestamp
+}
+
+// Stop syncing since the job has reached the stop-at target, the caller must hold the job lock.
+func (j *Job) reachStopAt() error {
+	log.Infof("job %s reached the stop at target, commit seq: %d, stop at commit seq: %d, stop at timestamp: %d",
+		j.Name, j.progress.CommitSeq, j.Extra.StopAtCommitSeq, j.Extra.StopAtTimestamp)
+
+	originState := j.State
+	j.State = JobReachedTarget
+	if err := j.persistJob(); err != nil {
+		j.State = originState
+		return err
+	}
+	j.updateJobStatus()
+	return nil
+}
+
+// SetStopAt updates the stop-at target of the job, zero means no target. The job reached
+// the previous target continues syncing to the new one.
+func (j *Job) SetStopAt(commitSeq, timestamp int64) error {
+	if commitSeq < 0 || timestamp < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid stop at target, commit seq: %d, timestamp: %d",
+			commitSeq, timestamp)
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if commitSeq > 0 && j.progress != nil && j.progress.IsDone() && commitSeq < j.progress.CommitSeq {
+		return xerror.Errorf(xerror.Normal, "job %s has synced to commit seq %d, which is beyond the target %d",
+			j.Name, j.progress.CommitSeq, commitSeq)
+	}
+
+	savedExtra := j.Extra
+	originState := j.State
+	j.Extra.StopAtCommitSeq = commitSeq
+	j.Extra.StopAtTimestamp = timestamp
+	if j.State == JobReachedTarget {
+		j.State = JobRunning
+	}
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		j.State = originState
+		return err
+	}
+	j.updateJobStatus()
+	log.Infof("set stop at target of job %s, commit seq: %d, timestamp: %d", j.Name, commitSeq, timestamp)
+
+	for _, fanout := range j.fanouts {
+		if err := fanout.SetStopAt(commitSeq, timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func isTxnCommitted(status *tstatus.TStatus) bool {
 	return isStatusContainsAny(status, "is already COMMITTED")
 }
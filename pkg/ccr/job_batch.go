@@ -0,0 +1,172 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"flag"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var upsertBatchSize int
+
+func init() {
+	flag.IntVar(&upsertBatchSize, "upsert_batch_size", 0,
+		"the max number of the consecutive upsert binlogs of disjoint partitions merged into one dest txn, "+
+			"the upserts of the same partition are never merged, 0 or 1 means no batching")
+}
+
+type partitionKey struct {
+	tableId     int64
+	partitionId int64
+}
+
+// Collect the consecutive upsert binlogs from the head of binlogs, which could be applied
+// in one dest txn. Returns nil if there are less than two of them.
+//
+// The dest BE ingests one version of a tablet in a txn, so the upserts in a batch must not
+// touch the same partition, each partition is ingested with its only version in the batch.
+// The versions of a partition can not be merged by keeping the last one, since each version
+// is the incremental rowset of a load, the rows of the earlier versions would be lost. So the
+// batch only helps the loads spread over the tables or partitions, and the loads into the same
+// partition are still applied one by one.
+func (j *Job) collectUpsertBatch(binlogs []*festruct.TBinlog) ([]*festruct.TBinlog, []*record.Upsert) {
+	if upsertBatchSize <= 1 || j.Extra.ReuseBinlogLabel || j.Extra.SkipBinlog ||
+		!j.isIncrementalSync() || j.progress.TableCommitSeqMap != nil {
+		return nil, nil
+	}
+
+	batch := make([]*festruct.TBinlog, 0, upsertBatchSize)
+	upserts := make([]*record.Upsert, 0, upsertBatchSize)
+	partitions := make(map[partitionKey]struct{})
+	for _, binlog := range binlogs {
+		if len(batch) >= upsertBatchSize || binlog.GetType() != festruct.TBinlogType_UPSERT {
+			break
+		}
+		if len(batch) > 0 && (j.isBeyondStopAt(binlog) || !j.allowApplyBinlog(binlog, len(batch))) {
+			break
+		}
+
+		upsert, err := record.NewUpsertFromJson(binlog.GetData())
+		if err != nil {
+			// leave it to the handleUpsert
+			break
+		}
+		if len(upsert.Stids) > 0 || (j.Extra.Bidirectional && j.isSyncerOriginatedUpsert(upsert)) {
+			break
+		}
+
+		conflict := false
+		keys := make([]partitionKey, 0)
+		for tableId, tableRecord := range upsert.TableRecords {
+			for _, partitionRecord := range tableRecord.PartitionRecords {
+				key := partitionKey{tableId, partitionRecord.Id}
+				if _, ok := partitions[key]; ok {
+					conflict = true
+				}
+				keys = append(keys, key)
+			}
+		}
+		if conflict {
+			break
+		}
+		for _, key := range keys {
+			partitions[key] = struct{}{}
+		}
+
+		batch = append(batch, binlog)
+		upserts = append(upserts, upsert)
+	}
+
+	if len(batch) < 2 {
+		return nil, nil
+	}
+	return batch, upserts
+}
+
+// Merge the upserts into one upsert binlog, with the commit seq of the last one.
+func mergeUpsertBinlogs(batch []*festruct.TBinlog, upserts []*record.Upsert) (*festruct.TBinlog, error) {
+	last := upserts[len(upserts)-1]
+	merged := &record.Upsert{
+		CommitSeq:    last.CommitSeq,
+		TxnID:        last.TxnID,
+		TimeStamp:    last.TimeStamp,
+		Label:        last.Label,
+		DbID:         last.DbID,
+		TableRecords: make(map[int64]*record.TableRecord),
+	}
+
+	tableIds := make([]int64, 0)
+	for _, upsert := range upserts {
+		for tableId, tableRecord := range upsert.TableRecords {
+			mergedRecord, ok := merged.TableRecords[tableId]
+			if !ok {
+				mergedRecord = &record.TableRecord{Id: tableId}
+				merged.TableRecords[tableId] = mergedRecord
+				tableIds = append(tableIds, tableId)
+			}
+			mergedRecord.PartitionRecords = append(mergedRecord.PartitionRecords, tableRecord.PartitionRecords...)
+			for _, indexId := range tableRecord.IndexIds {
+				found := false
+				for _, id := range mergedRecord.IndexIds {
+					if id == indexId {
+						found = true
+						break
+					}
+				}
+				if !found {
+					mergedRecord.IndexIds = append(mergedRecord.IndexIds, indexId)
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "marshal merged upsert failed")
+	}
+
+	lastBinlog := batch[len(batch)-1]
+	binlogType := festruct.TBinlogType_UPSERT
+	dataStr := string(data)
+	return &festruct.TBinlog{
+		CommitSeq: lastBinlog.CommitSeq,
+		Timestamp: lastBinlog.Timestamp,
+		Type:      &binlogType,
+		DbId:      lastBinlog.DbId,
+		TableIds:  tableIds,
+		Data:      &dataStr,
+	}, nil
+}
+
+// Apply the upsert batch in one dest txn, the progress advances to the last commit seq of the batch.
+func (j *Job) handleUpsertBatch(batch []*festruct.TBinlog, upserts []*record.Upsert) error {
+	binlog, err := mergeUpsertBinlogs(batch, upserts)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("handle %d upserts in one txn, commit seq: [%d, %d]",
+		len(batch), batch[0].GetCommitSeq(), batch[len(batch)-1].GetCommitSeq())
+	return j.handleBinlog(binlog)
+}
@@ -0,0 +1,171 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+)
+
+func TestMergeUpsertBinlogs(t *testing.T) {
+	datas := []string{
+		`{"commitSeq":10,"label":"l1","dbId":1,"tableRecords":{"100":{"partitionRecords":[{"partitionId":101,"version":5}],"indexIds":[102]}}}`,
+		`{"commitSeq":11,"label":"l2","dbId":1,"tableRecords":{"100":{"partitionRecords":[{"partitionId":103,"version":7}],"indexIds":[102]},"200":{"partitionRecords":[{"partitionId":201,"version":3}],"indexIds":[202]}}}`,
+	}
+	batch := make([]*festruct.TBinlog, 0, len(datas))
+	upserts := make([]*record.Upsert, 0, len(datas))
+	for i, data := range datas {
+		data := data
+		commitSeq := int64(10 + i)
+		batch = append(batch, &festruct.TBinlog{CommitSeq: &commitSeq, Data: &data})
+		upsert, err := record.NewUpsertFromJson(data)
+		if err != nil {
+			t.Fatalf("parse upsert %d failed: %v", i, err)
+		}
+		upserts = append(upserts, upsert)
+	}
+
+	binlog, err := mergeUpsertBinlogs(batch, upserts)
+	if err != nil {
+		t.Fatalf("merge upsert binlogs failed: %v", err)
+	}
+	if binlog.GetCommitSeq() != 11 || binlog.GetType() != festruct.TBinlogType_UPSERT {
+		t.Errorf("unexpected merged binlog: %v", binlog)
+	}
+
+	merged, err := record.NewUpsertFromJson(binlog.GetData())
+	if err != nil {
+		t.Fatalf("parse merged upsert failed: %v", err)
+	}
+	if merged.CommitSeq != 11 || merged.Label != "l2" || len(merged.TableRecords) != 2 {
+		t.Errorf("unexpected merged upsert: %v", merged)
+	}
+	table := merged.TableRecords[100]
+	if table == nil || len(table.PartitionRecords) != 2 || len(table.IndexIds) != 1 {
+		t.Fatalf("unexpected merged table record: %v", table)
+	}
+	if table.PartitionRecords[0].Version != 5 || table.PartitionRecords[1].Version != 7 {
+		t.Errorf("unexpected merged partition records: %v", table.PartitionRecords)
+	}
+}
+
+func TestCollectUpsertBatch(t *testing.T) {
+	savedBatchSize := upsertBatchSize
+	upsertBatchSize = 10
+	defer func() { upsertBatchSize = savedBatchSize }()
+
+	job := &Job{Name: "test_job", SyncType: DBSync}
+	job.progress = &JobProgress{SyncState: DBIncrementalSync}
+
+	// the upserts of t100/p101, t100/p103, t200/p201 and t100/p101 again
+	datas := []string{
+		`{"commitSeq":10,"tableRecords":{"100":{"partitionRecords":[{"partitionId":101,"version":5}]}}}`,
+		`{"commitSeq":11,"tableRecords":{"100":{"partitionRecords":[{"partitionId":103,"version":7}]}}}`,
+		`{"commitSeq":12,"tableRecords":{"200":{"partitionRecords":[{"partitionId":201,"version":3}]}}}`,
+		`{"commitSeq":13,"tableRecords":{"100":{"partitionRecords":[{"partitionId":101,"version":6}]}}}`,
+	}
+	binlogs := make([]*festruct.TBinlog, 0, len(datas))
+	for i, data := range datas {
+		data := data
+		commitSeq := int64(10 + i)
+		binlogType := festruct.TBinlogType_UPSERT
+		binlogs = append(binlogs, &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType, Data: &data})
+	}
+
+	// the batch is cut before the second version of the same partition
+	batch, upserts := job.collectUpsertBatch(binlogs)
+	if len(batch) != 3 || len(upserts) != 3 || batch[2].GetCommitSeq() != 12 {
+		t.Fatalf("expect the upserts [10, 12] in the batch, got %d binlogs", len(batch))
+	}
+	if batch, _ = job.collectUpsertBatch(binlogs[3:]); batch != nil {
+		t.Errorf("expect no batch for a single upsert, got %d binlogs", len(batch))
+	}
+}
+
+func TestCollectUpsertBatchSamePartition(t *testing.T) {
+	savedBatchSize := upsertBatchSize
+	upsertBatchSize = 10
+	defer func() { upsertBatchSize = savedBatchSize }()
+
+	job := &Job{Name: "test_job", SyncType: DBSync}
+	job.progress = &JobProgress{SyncState: DBIncrementalSync}
+
+	newBinlogs := func(partitionIds ...int64) []*festruct.TBinlog {
+		binlogs := make([]*festruct.TBinlog, 0, len(partitionIds))
+		for i, partitionId := range partitionIds {
+			data := fmt.Sprintf(`{"commitSeq":%d,"tableRecords":{"100":{"partitionRecords":[{"partitionId":%d,"version":%d}]}}}`,
+				10+i, partitionId, 5+i)
+			commitSeq := int64(10 + i)
+			binlogType := festruct.TBinlogType_UPSERT
+			binlogs = append(binlogs, &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType, Data: &data})
+		}
+		return binlogs
+	}
+
+	// the upserts of the same partition are applied in separate txns
+	binlogs := newBinlogs(101, 101, 101)
+	for i := range binlogs {
+		if batch, _ := job.collectUpsertBatch(binlogs[i:]); batch != nil {
+			t.Errorf("expect no batch for the upserts of the same partition, got %d binlogs", len(batch))
+		}
+	}
+
+	// the interleaved upserts of two partitions are cut into batches of two
+	binlogs = newBinlogs(101, 103, 101, 103)
+	for i := 0; i < len(binlogs); i += 2 {
+		batch, _ := job.collectUpsertBatch(binlogs[i:])
+		if len(batch) != 2 || batch[0].GetCommitSeq() != int64(10+i) {
+			t.Errorf("expect the batch of 2 upserts from %d, got %d binlogs", 10+i, len(batch))
+		}
+	}
+}
+
+func TestCollectUpsertBatchThrottled(t *testing.T) {
+	savedBatchSize := upsertBatchSize
+	upsertBatchSize = 100
+	defer func() { upsertBatchSize = savedBatchSize }()
+
+	job := &Job{Name: "test_job", SyncType: DBSync}
+	job.progress = &JobProgress{SyncState: DBIncrementalSync}
+	job.Extra.Schedule = &SyncSchedule{MaxBinlogsPerMinute: 3}
+	job.initSchedule()
+
+	// the upserts of disjoint partitions
+	binlogs := make([]*festruct.TBinlog, 0, 6)
+	for i := 0; i < 6; i++ {
+		data := fmt.Sprintf(`{"commitSeq":%d,"tableRecords":{"100":{"partitionRecords":[{"partitionId":%d,"version":5}]}}}`,
+			10+i, 101+i)
+		commitSeq := int64(10 + i)
+		binlogType := festruct.TBinlogType_UPSERT
+		binlogs = append(binlogs, &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType, Data: &data})
+	}
+
+	// the batch counts the binlogs collected before into the throttle
+	batch, _ := job.collectUpsertBatch(binlogs)
+	if len(batch) != 3 {
+		t.Fatalf("expect 3 upserts in the batch, got %d", len(batch))
+	}
+
+	job.recordAppliedBinlogs(batch[:1])
+	if batch, _ = job.collectUpsertBatch(binlogs); len(batch) != 2 {
+		t.Errorf("expect 2 upserts in the batch after 1 applied, got %d", len(batch))
+	}
+}
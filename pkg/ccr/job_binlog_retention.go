@@ -0,0 +1,413 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	utils "github.com/selectdb/ccr_syncer/pkg/utils"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+	"github.com/selectdb/ccr_syncer/pkg/xmetrics"
+
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The binlog ttl of doris if the binlog.ttl_seconds is not set.
+const defaultBinlogTTLSeconds = 24 * 60 * 60
+
+var (
+	binlogRetentionCheckInterval time.Duration
+	binlogRetentionWarnRatio     float64
+)
+
+func init() {
+	flag.DurationVar(&binlogRetentionCheckInterval, "binlog_retention_check_interval", 5*time.Minute,
+		"the interval to compare the unsynced binlogs with the binlog retention of src, zero to disable")
+	flag.Float64Var(&binlogRetentionWarnRatio, "binlog_retention_warn_ratio", 0.8,
+		"warn if the unsynced binlogs use more than the ratio of the binlog retention of src")
+}
+
+// BinlogRetention is the result of the last check of the unsynced binlogs against the binlog
+// retention of src, for the slowest one of the job and its fan-out dests.
+type BinlogRetention struct {
+	// The unix time in milliseconds of the check.
+	CheckedAt int64 `json:"checked_at"`
+	// The fan-out job name of the slowest dest, empty for the first dest.
+	Dest      string `json:"dest,omitempty"`
+	CommitSeq int64  `json:"commit_seq"`
+	Lag       int64  `json:"lag"`
+	// The unix time in milliseconds of the oldest unsynced binlog.
+	FirstBinlogTimestamp int64 `json:"first_binlog_timestamp,omitempty"`
+	TTLSeconds           int64 `json:"ttl_seconds"`
+	MaxHistoryNums       int64 `json:"max_history_nums,omitempty"`
+	MaxBytes             int64 `json:"max_bytes,omitempty"`
+	// The used ratio of the retention, the unsynced binlogs are GCed once it reaches 1.
+	Ratio   float64 `json:"ratio"`
+	Warning string  `json:"warning,omitempty"`
+}
+
+// BinlogLease extends the binlog ttl of src while the job is not running, such as paused or
+// waiting for the approval, so the unsynced binlogs are not GCed before the job is resumed.
+type BinlogLease struct {
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+// The binlog ttl of src changed by the lease, it is restored once the job is running again.
+//
+// The binlog ttl is a property of the src database (or table), so the leases of the jobs with
+// the same src are coordinated: the origin ttl is shared by all of them, and the ttl is only
+// restored to the max of the remaining leases.
+type HeldBinlogLease struct {
+	OriginTTLSeconds int64 `json:"origin_ttl_seconds"`
+	TTLSeconds       int64 `json:"ttl_seconds"`
+	TableLevel       bool  `json:"table_level,omitempty"`
+	// The unix time in milliseconds.
+	LeasedAt int64 `json:"leased_at"`
+}
+
+func (l *BinlogLease) Valid() error {
+	if l != nil && l.TTLSeconds <= 0 {
+		return xerror.Errorf(xerror.Normal, "invalid binlog lease ttl seconds: %d", l.TTLSeconds)
+	}
+	return nil
+}
+
+func binlogTTLSeconds(properties *base.BinlogProperties) int64 {
+	if properties.TTLSeconds <= 0 {
+		return defaultBinlogTTLSeconds
+	}
+	return properties.TTLSeconds
+}
+
+// Compute the used ratio of the retention by the age of the oldest unsynced binlog and the
+// number of the unsynced binlogs. The max bytes is not checked, since the size of the
+// unsynced binlogs is unknown.
+func (r *BinlogRetention) evaluate(now time.Time) {
+	r.Ratio = 0
+	r.Warning = ""
+	if r.Lag <= 0 {
+		return
+	}
+
+	if r.FirstBinlogTimestamp > 0 && r.TTLSeconds > 0 {
+		age := now.Sub(time.UnixMilli(r.FirstBinlogTimestamp))
+		ttl := time.Duration(r.TTLSeconds) * time.Second
+		if ratio := float64(age) / float64(ttl); ratio > r.Ratio {
+			r.Ratio = ratio
+			r.Warning = fmt.Sprintf("the oldest unsynced binlog is %s old, the binlog ttl is %s",
+				age.Truncate(time.Second), ttl)
+		}
+	}
+	if r.MaxHistoryNums > 0 && r.MaxHistoryNums != math.MaxInt64 {
+		if ratio := float64(r.Lag) / float64(r.MaxHistoryNums); ratio > r.Ratio {
+			r.Ratio = ratio
+			r.Warning = fmt.Sprintf("%d binlogs are not synced, the binlog max history nums is %d",
+				r.Lag, r.MaxHistoryNums)
+		}
+	}
+	if r.Ratio < binlogRetentionWarnRatio {
+		r.Warning = ""
+	}
+}
+
+// Check the binlog retention periodically, and once the job state is changed, so the lease is
+// held and released in time.
+func (j *Job) maybeCheckBinlogRetention() {
+	if binlogRetentionCheckInterval <= 0 || j.parent != nil {
+		return
+	}
+
+	now := time.Now()
+	state := j.getJobState()
+	if now.Sub(j.lastBinlogRetentionCheckAt) < binlogRetentionCheckInterval &&
+		state == j.lastBinlogRetentionState {
+		return
+	}
+	if !j.binlogRetentionChecking.CompareAndSwap(false, true) {
+		return
+	}
+	j.lastBinlogRetentionCheckAt = now
+	j.lastBinlogRetentionState = state
+
+	go func() {
+		defer j.binlogRetentionChecking.Store(false)
+		j.checkBinlogRetention(state)
+	}()
+}
+
+func (j *Job) checkBinlogRetention(state JobState) {
+	properties, err := j.factory.NewSpecer(&j.Src).GetBinlogProperties()
+	if err != nil {
+		log.Warnf("get binlog properties of job %s failed, err: %+v", j.Name, err)
+		return
+	}
+
+	now := time.Now()
+	var retention *BinlogRetention
+	for _, job := range append([]*Job{j}, j.fanouts...) {
+		jobRetention, err := job.getBinlogRetention(properties, now)
+		if err != nil {
+			log.Warnf("check binlog retention of job %s failed, err: %+v", job.Name, err)
+			return
+		}
+		if jobRetention == nil {
+			continue
+		}
+		if job != j {
+			jobRetention.Dest = job.Name
+		}
+		if retention == nil || jobRetention.Ratio > retention.Ratio {
+			retention = jobRetention
+		}
+	}
+
+	if retention != nil {
+		if retention.Warning != "" {
+			log.Warnf("job %s approaches the binlog gc of src, dest: %s, commit seq: %d, ratio: %.2f, %s",
+				j.Name, retention.Dest, retention.CommitSeq, retention.Ratio, retention.Warning)
+		}
+		xmetrics.BinlogRetention(j.Name, retention.Lag, retention.Ratio)
+	}
+
+	held := j.maintainBinlogLease(properties, state, now)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	savedExtra := j.Extra
+	j.Extra.BinlogRetention = retention
+	j.Extra.HeldBinlogLease = held
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		log.Warnf("save binlog retention of job %s failed, err: %+v", j.Name, err)
+	}
+}
+
+func (j *Job) getBinlogRetention(properties *base.BinlogProperties, now time.Time) (*BinlogRetention, error) {
+	j.lock.Lock()
+	if j.progress == nil {
+		j.lock.Unlock()
+		return nil, nil
+	}
+	commitSeq := j.sinkFetchCommitSeq()
+	j.lock.Unlock()
+
+	srcRpc, err := j.factory.NewFeRpc(&j.Src)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := srcRpc.GetBinlogLag(&j.Src, commitSeq)
+	if err != nil {
+		return nil, err
+	}
+	if status := resp.GetStatus(); status != nil && status.StatusCode != tstatus.TStatusCode_OK {
+		return nil, xerror.Errorf(xerror.Normal, "get binlog lag failed, status: %v, msg: %s",
+			status.StatusCode, utils.FirstOr(status.GetErrorMsgs(), ""))
+	}
+
+	retention := &BinlogRetention{
+		CheckedAt:      now.UnixMilli(),
+		CommitSeq:      commitSeq,
+		Lag:            resp.GetLag(),
+		TTLSeconds:     binlogTTLSeconds(properties),
+		MaxHistoryNums: properties.MaxHistoryNums,
+		MaxBytes:       properties.MaxBytes,
+	}
+	if ts := resp.GetFirstBinlogTimestamp(); ts > 0 {
+		retention.FirstBinlogTimestamp = ts
+	}
+	retention.evaluate(now)
+	return retention, nil
+}
+
+// Serialize the binlog leases of the jobs in this syncer, the leases of the jobs in the other
+// syncers are coordinated by the held leases saved in the meta db.
+var binlogLeaseLock sync.Mutex
+
+// The key of the binlog ttl property of the src, the jobs with the same key share it.
+func binlogLeaseKey(host, port, database, table string, tableLevel bool) string {
+	if !tableLevel {
+		table = ""
+	}
+	return fmt.Sprintf("%s:%s/%s/%s", host, port, database, table)
+}
+
+// The binlog leases held by the other jobs with the same src, read from the meta db.
+func (j *Job) otherHeldBinlogLeases(tableLevel bool) ([]*HeldBinlogLease, error) {
+	jobNames, err := j.db.GetAllJobNames()
+	if err != nil {
+		return nil, err
+	}
+
+	key := binlogLeaseKey(j.Src.Host, j.Src.Port, j.Src.Database, j.Src.Table, tableLevel)
+	leases := make([]*HeldBinlogLease, 0)
+	for _, jobName := range jobNames {
+		if jobName == j.Name {
+			continue
+		}
+		jobInfo, err := j.db.GetJobInfo(jobName)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the src and the held lease are decoded, the passwords are not decrypted.
+		var job struct {
+			Src struct {
+				Host     string `json:"host"`
+				Port     string `json:"port"`
+				Database string `json:"database"`
+				Table    string `json:"table"`
+			} `json:"src"`
+			Extra struct {
+				HeldBinlogLease *HeldBinlogLease `json:"held_binlog_lease"`
+			} `json:"extra"`
+		}
+		if err := json.Unmarshal([]byte(jobInfo), &job); err != nil {
+			log.Warnf("decode the binlog lease of job %s failed, err: %v", jobName, err)
+			continue
+		}
+		held := job.Extra.HeldBinlogLease
+		if held == nil || held.TableLevel != tableLevel ||
+			binlogLeaseKey(job.Src.Host, job.Src.Port, job.Src.Database, job.Src.Table, tableLevel) != key {
+			continue
+		}
+		leases = append(leases, held)
+	}
+	return leases, nil
+}
+
+// Extend the binlog ttl of src while the job is not running, and restore it once the job is
+// running again. Returns the lease held after the change.
+func (j *Job) maintainBinlogLease(properties *base.BinlogProperties, state JobState, now time.Time) *HeldBinlogLease {
+	j.lock.Lock()
+	lease, held := j.Extra.BinlogLease, j.Extra.HeldBinlogLease
+	j.lock.Unlock()
+
+	binlogLeaseLock.Lock()
+	defer binlogLeaseLock.Unlock()
+
+	ttlSeconds := binlogTTLSeconds(properties)
+	switch {
+	case held != nil && state == JobRunning:
+		if err := j.restoreBinlogTTL(held, properties); err != nil {
+			log.Warnf("job %s restore the binlog ttl of src failed, err: %+v", j.Name, err)
+			return held
+		}
+		return nil
+	case held == nil && lease != nil && state != JobRunning:
+		others, err := j.otherHeldBinlogLeases(properties.TableLevel)
+		if err != nil {
+			log.Warnf("job %s get the binlog leases of the other jobs failed, err: %+v", j.Name, err)
+			return nil
+		}
+		if len(others) == 0 && ttlSeconds >= lease.TTLSeconds {
+			return nil
+		}
+
+		// The ttl might have been extended by the other jobs, share the origin ttl with them.
+		originTTLSeconds := ttlSeconds
+		if len(others) > 0 {
+			originTTLSeconds = others[0].OriginTTLSeconds
+		}
+		if ttlSeconds < lease.TTLSeconds {
+			if err := j.factory.NewSpecer(&j.Src).SetBinlogTTL(lease.TTLSeconds, properties.TableLevel); err != nil {
+				log.Warnf("job %s extend the binlog ttl of src failed, err: %+v", j.Name, err)
+				return nil
+			}
+			log.Infof("job %s is %s, extend the binlog ttl of src from %d to %d seconds",
+				j.Name, state, ttlSeconds, lease.TTLSeconds)
+		} else {
+			log.Infof("job %s is %s, the binlog ttl of src %d seconds is held by %d other jobs",
+				j.Name, state, ttlSeconds, len(others))
+		}
+		return &HeldBinlogLease{
+			OriginTTLSeconds: originTTLSeconds,
+			TTLSeconds:       lease.TTLSeconds,
+			TableLevel:       properties.TableLevel,
+			LeasedAt:         now.UnixMilli(),
+		}
+	default:
+		return held
+	}
+}
+
+// Restore the binlog ttl of src to the max of the origin ttl and the leases held by the other
+// jobs, unless it is not extended by this job, or has been changed by others since then.
+// The caller must hold the binlogLeaseLock.
+func (j *Job) restoreBinlogTTL(held *HeldBinlogLease, properties *base.BinlogProperties) error {
+	specer := j.factory.NewSpecer(&j.Src)
+	if properties == nil {
+		var err error
+		if properties, err = specer.GetBinlogProperties(); err != nil {
+			return err
+		}
+	}
+
+	if ttlSeconds := binlogTTLSeconds(properties); ttlSeconds != held.TTLSeconds {
+		log.Infof("the binlog ttl of src is %d seconds, not the %d seconds of job %s, keep it",
+			ttlSeconds, held.TTLSeconds, j.Name)
+		return nil
+	}
+
+	others, err := j.otherHeldBinlogLeases(held.TableLevel)
+	if err != nil {
+		return err
+	}
+	ttlSeconds := held.OriginTTLSeconds
+	for _, other := range others {
+		if other.TTLSeconds > ttlSeconds {
+			ttlSeconds = other.TTLSeconds
+		}
+	}
+	if ttlSeconds >= held.TTLSeconds {
+		log.Infof("the binlog ttl of src is still held by %d other jobs, keep it", len(others))
+		return nil
+	}
+
+	if err := specer.SetBinlogTTL(ttlSeconds, held.TableLevel); err != nil {
+		return err
+	}
+	log.Infof("job %s restores the binlog ttl of src to %d seconds, origin: %d seconds, other leases: %d",
+		j.Name, ttlSeconds, held.OriginTTLSeconds, len(others))
+	return nil
+}
+
+// Restore the binlog ttl of src before the job is removed.
+func (j *Job) releaseBinlogLease() {
+	j.lock.Lock()
+	held := j.Extra.HeldBinlogLease
+	j.lock.Unlock()
+
+	if held == nil || j.parent != nil {
+		return
+	}
+
+	binlogLeaseLock.Lock()
+	defer binlogLeaseLock.Unlock()
+
+	if err := j.restoreBinlogTTL(held, nil); err != nil {
+		log.Warnf("job %s restore the binlog ttl of src failed, err: %+v", j.Name, err)
+	}
+}
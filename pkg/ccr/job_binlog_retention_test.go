@@ -0,0 +1,160 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestBinlogRetentionEvaluate(t *testing.T) {
+	now := time.Now()
+
+	retention := &BinlogRetention{
+		Lag:                  100,
+		FirstBinlogTimestamp: now.Add(-9 * time.Hour).UnixMilli(),
+		TTLSeconds:           10 * 60 * 60,
+		MaxHistoryNums:       math.MaxInt64,
+	}
+	retention.evaluate(now)
+	if math.Abs(retention.Ratio-0.9) > 0.01 || retention.Warning == "" {
+		t.Fatalf("the ttl should be nearly used, ratio: %f, warning: %s", retention.Ratio, retention.Warning)
+	}
+
+	retention.FirstBinlogTimestamp = now.Add(-time.Hour).UnixMilli()
+	retention.evaluate(now)
+	if math.Abs(retention.Ratio-0.1) > 0.01 || retention.Warning != "" {
+		t.Fatalf("the ttl should not be warned, ratio: %f, warning: %s", retention.Ratio, retention.Warning)
+	}
+
+	retention.MaxHistoryNums = 110
+	retention.evaluate(now)
+	if math.Abs(retention.Ratio-100.0/110) > 0.01 || retention.Warning == "" {
+		t.Fatalf("the max history nums should be nearly used, ratio: %f, warning: %s", retention.Ratio, retention.Warning)
+	}
+
+	retention.Lag = 0
+	retention.evaluate(now)
+	if retention.Ratio != 0 || retention.Warning != "" {
+		t.Fatalf("no unsynced binlogs, ratio: %f, warning: %s", retention.Ratio, retention.Warning)
+	}
+}
+
+// binlogTTLSpecer keeps the binlog ttl of the src database, shared by all jobs.
+type binlogTTLSpecer struct {
+	base.Specer
+	ttlSeconds int64
+}
+
+func (s *binlogTTLSpecer) GetBinlogProperties() (*base.BinlogProperties, error) {
+	return &base.BinlogProperties{Enable: true, TTLSeconds: s.ttlSeconds}, nil
+}
+
+func (s *binlogTTLSpecer) SetBinlogTTL(ttlSeconds int64, tableLevel bool) error {
+	s.ttlSeconds = ttlSeconds
+	return nil
+}
+
+func (s *binlogTTLSpecer) NewSpecer(spec *base.Spec) base.Specer {
+	return s
+}
+
+func TestBinlogLeaseCoordination(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	specer := &binlogTTLSpecer{ttlSeconds: 100}
+	db := test_util.NewMockDB(ctrl)
+	factory := NewFactory(nil, nil, specer, nil)
+
+	jobs := make(map[string]*Job)
+	newJob := func(name string, leaseSeconds int64) *Job {
+		job := &Job{
+			Name:    name,
+			Src:     base.Spec{Frontend: base.Frontend{Host: "127.0.0.1", Port: "9030"}, Database: "db"},
+			factory: factory,
+			db:      db,
+		}
+		job.Extra.BinlogLease = &BinlogLease{TTLSeconds: leaseSeconds}
+		jobs[name] = job
+		return job
+	}
+	db.EXPECT().GetAllJobNames().DoAndReturn(func() ([]string, error) {
+		names := make([]string, 0, len(jobs))
+		for name := range jobs {
+			names = append(names, name)
+		}
+		return names, nil
+	}).AnyTimes()
+	db.EXPECT().GetJobInfo(gomock.Any()).DoAndReturn(func(name string) (string, error) {
+		data, err := json.Marshal(jobs[name])
+		return string(data), err
+	}).AnyTimes()
+
+	// transit the job to the state, returns the binlog ttl of src after that
+	transit := func(job *Job, state JobState) int64 {
+		properties, _ := specer.GetBinlogProperties()
+		job.Extra.HeldBinlogLease = job.maintainBinlogLease(properties, state, time.Now())
+		return specer.ttlSeconds
+	}
+
+	long := newJob("long", 1000)
+	short := newJob("short", 500)
+	// the lease of the other src database is ignored
+	other := newJob("other", 5000)
+	other.Src.Database = "other_db"
+	other.Extra.HeldBinlogLease = &HeldBinlogLease{OriginTTLSeconds: 100, TTLSeconds: 5000}
+
+	// the ttl is restored to the remaining lease, then the origin
+	if ttl := transit(long, JobPaused); ttl != 1000 || long.Extra.HeldBinlogLease == nil {
+		t.Fatalf("the ttl should be extended by the long lease, ttl: %d", ttl)
+	}
+	if ttl := transit(short, JobPaused); ttl != 1000 || short.Extra.HeldBinlogLease == nil ||
+		short.Extra.HeldBinlogLease.OriginTTLSeconds != 100 {
+		t.Fatalf("the short lease should be held with the shared origin ttl, ttl: %d, held: %+v",
+			ttl, short.Extra.HeldBinlogLease)
+	}
+	if ttl := transit(long, JobRunning); ttl != 500 || long.Extra.HeldBinlogLease != nil {
+		t.Fatalf("the ttl should be restored to the short lease, ttl: %d", ttl)
+	}
+	if ttl := transit(short, JobRunning); ttl != 100 || short.Extra.HeldBinlogLease != nil {
+		t.Fatalf("the ttl should be restored to the origin, ttl: %d", ttl)
+	}
+
+	// the ttl is kept if the job releasing the lease does not hold the max one
+	transit(long, JobPaused)
+	transit(short, JobPaused)
+	if ttl := transit(short, JobRunning); ttl != 1000 || short.Extra.HeldBinlogLease != nil {
+		t.Fatalf("the ttl should be kept for the long lease, ttl: %d", ttl)
+	}
+	if ttl := transit(long, JobRunning); ttl != 100 {
+		t.Fatalf("the ttl should be restored to the origin, ttl: %d", ttl)
+	}
+
+	// no lease is held if the ttl is long enough
+	specer.ttlSeconds = 2000
+	if ttl := transit(long, JobPaused); ttl != 2000 || long.Extra.HeldBinlogLease != nil {
+		t.Fatalf("the lease should not be held, ttl: %d, held: %+v", ttl, long.Extra.HeldBinlogLease)
+	}
+}
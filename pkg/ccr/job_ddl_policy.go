@@ -0,0 +1,377 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The actions of the DDL policy rules.
+const (
+	DDLActionApply         = "apply"
+	DDLActionSkip          = "skip"
+	DDLActionSkipAndRecord = "skip_and_record"
+	DDLActionPause         = "pause"
+)
+
+// The max number of the binlogs recorded by the skip_and_record rules, the oldest ones are
+// discarded.
+const maxRecordedBinlogs = 100
+
+// DDLRule decides how to handle the binlogs of the type, and of the tables matched by the
+// Table pattern (a glob, or a regular expression with the prefix "regex:"). An empty Table
+// matches all tables.
+type DDLRule struct {
+	BinlogType string `json:"binlog_type"`
+	Table      string `json:"table,omitempty"`
+	Action     string `json:"action"`
+
+	matcher tableMatcher `json:"-"`
+}
+
+// DDLPolicy is the rules of a job for the DDL binlogs, the first matched rule is used, and
+// the binlogs not matched by any rule are applied.
+type DDLPolicy struct {
+	Rules []*DDLRule `json:"rules"`
+}
+
+// The binlog handled by the DDL policy.
+type DDLEvent struct {
+	CommitSeq  int64    `json:"commit_seq"`
+	BinlogType string   `json:"binlog_type"`
+	Tables     []string `json:"tables,omitempty"`
+	Action     string   `json:"action"`
+	// The decoded record of the binlog, or the raw data if it is not a json.
+	Record json.RawMessage `json:"record,omitempty"`
+	Data   string          `json:"data,omitempty"`
+	// The unix time in milliseconds when the binlog was handled.
+	HandledAt int64 `json:"handled_at"`
+}
+
+func (p *DDLPolicy) IsEmpty() bool {
+	return p == nil || len(p.Rules) == 0
+}
+
+func (p *DDLPolicy) compile() error {
+	if p.IsEmpty() {
+		return nil
+	}
+
+	for _, rule := range p.Rules {
+		binlogType, err := festruct.TBinlogTypeFromString(strings.ToUpper(rule.BinlogType))
+		if err != nil {
+			return xerror.Errorf(xerror.Normal, "unknown binlog type %s of ddl policy", rule.BinlogType)
+		}
+		if binlogType == festruct.TBinlogType_UPSERT {
+			return xerror.New(xerror.Normal, "the ddl policy is not supported by upsert")
+		}
+		rule.BinlogType = binlogType.String()
+
+		switch rule.Action {
+		case DDLActionApply, DDLActionSkip, DDLActionSkipAndRecord, DDLActionPause:
+		default:
+			return xerror.Errorf(xerror.Normal, "unknown action %s of ddl policy", rule.Action)
+		}
+
+		rule.matcher = nil
+		if rule.Table != "" {
+			if rule.matcher, err = newTableMatcher(rule.Table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule matched the binlog type and any of the tables.
+func (p *DDLPolicy) Match(binlogType festruct.TBinlogType, tables []string) *DDLRule {
+	if p.IsEmpty() {
+		return nil
+	}
+
+	typeName := binlogType.String()
+	for _, rule := range p.Rules {
+		if rule.BinlogType != typeName {
+			continue
+		}
+		if rule.matcher == nil {
+			return rule
+		}
+		for _, table := range tables {
+			if rule.matcher(table) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+func (p *DDLPolicy) hasRulesOf(binlogType festruct.TBinlogType) bool {
+	if p.IsEmpty() {
+		return false
+	}
+	typeName := binlogType.String()
+	for _, rule := range p.Rules {
+		if rule.BinlogType == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the action of the DDL policy for the binlog.
+func (j *Job) checkDDLPolicy(binlog *festruct.TBinlog) (string, []string, error) {
+	policy := j.Extra.DDLPolicy
+	binlogType := binlog.GetType()
+	if !policy.hasRulesOf(binlogType) {
+		return DDLActionApply, nil, nil
+	}
+
+	commitSeq := binlog.GetCommitSeq()
+	if j.Extra.ApprovedCommitSeq == commitSeq {
+		log.Infof("apply the approved binlog %d, binlog type: %s", commitSeq, binlogType)
+		return DDLActionApply, nil, nil
+	}
+	if j.Extra.SkipBinlog && j.Extra.SkipBy == SkipBySilence && j.Extra.SkipCommitSeq == commitSeq {
+		// skipped by the user
+		return DDLActionApply, nil, nil
+	}
+
+	tables := make([]string, 0, len(binlog.GetTableIds()))
+	for _, tableId := range binlog.GetTableIds() {
+		if name, err := j.srcTableName(tableId); err != nil {
+			return "", nil, err
+		} else if name != "" {
+			tables = append(tables, name)
+		}
+	}
+
+	if j.SyncType == DBSync && j.hasTableSelector() && len(tables) > 0 {
+		selected := false
+		for _, table := range tables {
+			if j.isTableSelected(table) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			// not belong to this job
+			return DDLActionApply, nil, nil
+		}
+	}
+
+	rule := policy.Match(binlogType, tables)
+	if rule == nil {
+		return DDLActionApply, nil, nil
+	}
+	return rule.Action, tables, nil
+}
+
+// The binlog waits for the approval, of a job or a fan-out job of the extra destination.
+type PendingApproval struct {
+	Job string `json:"job"`
+	// The name of the fan-out job, empty for the job itself.
+	Dest string `json:"dest,omitempty"`
+	*DDLEvent
+}
+
+func newDDLEvent(binlog *festruct.TBinlog, action string, tables []string) *DDLEvent {
+	event := &DDLEvent{
+		CommitSeq:  binlog.GetCommitSeq(),
+		BinlogType: binlog.GetType().String(),
+		Tables:     tables,
+		Action:     action,
+		HandledAt:  time.Now().UnixMilli(),
+	}
+	if data := binlog.GetData(); json.Valid([]byte(data)) {
+		event.Record = json.RawMessage(data)
+	} else {
+		event.Data = data
+	}
+	return event
+}
+
+// Skip the binlog by the DDL policy, the caller must hold the job lock.
+func (j *Job) skipBinlogByPolicy(binlog *festruct.TBinlog, action string, tables []string) error {
+	log.Warnf("skip binlog %d by ddl policy, action: %s, binlog type: %s, tables: %v, binlog data: %s",
+		binlog.GetCommitSeq(), action, binlog.GetType(), tables, binlog.GetData())
+
+	j.progress.StartHandle(binlog.GetCommitSeq())
+	if action != DDLActionSkipAndRecord {
+		return nil
+	}
+
+	savedExtra := j.Extra
+	skipped := append(j.Extra.SkippedBinlogs, newDDLEvent(binlog, action, tables))
+	if len(skipped) > maxRecordedBinlogs {
+		skipped = skipped[len(skipped)-maxRecordedBinlogs:]
+	}
+	j.Extra.SkippedBinlogs = skipped
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		return err
+	}
+	return nil
+}
+
+// Hold the job at the binlog until it is approved, the caller must hold the job lock.
+func (j *Job) holdForApproval(binlog *festruct.TBinlog, tables []string) error {
+	log.Warnf("job %s waits for the approval of binlog %d, binlog type: %s, tables: %v",
+		j.Name, binlog.GetCommitSeq(), binlog.GetType(), tables)
+
+	savedExtra := j.Extra
+	originState := j.State
+	j.Extra.PendingApproval = newDDLEvent(binlog, DDLActionPause, tables)
+	j.State = JobWaitingApproval
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		j.State = originState
+		return err
+	}
+	j.updateJobStatus()
+	return nil
+}
+
+// Apply or skip the pending binlog, and the job continues. The decision is saved in one
+// persist, so a failure doesn't leave the binlog skipped but still waiting for approval.
+func (j *Job) resolvePendingBinlog(commitSeq int64, reject bool) (bool, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if !j.hasPendingApproval() || j.Extra.PendingApproval.CommitSeq != commitSeq {
+		return false, nil
+	}
+
+	savedExtra := j.Extra
+	originState := j.State
+	if reject {
+		j.Extra.SkipBinlog = true
+		j.Extra.SkipCommitSeq = commitSeq
+		j.Extra.SkipBy = SkipBySilence
+	} else {
+		j.Extra.ApprovedCommitSeq = commitSeq
+	}
+	j.Extra.PendingApproval = nil
+	if j.State == JobWaitingApproval {
+		// a paused job keeps paused, the decision is taken after it is resumed
+		j.State = JobRunning
+	}
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		j.State = originState
+		return false, err
+	}
+	j.updateJobStatus()
+	if reject {
+		log.Infof("reject binlog %d of job %s", commitSeq, j.Name)
+	} else {
+		log.Infof("approve binlog %d of job %s", commitSeq, j.Name)
+	}
+	return true, nil
+}
+
+// The binlog is waiting for approval, or the job is paused while waiting for approval. The
+// caller must hold the job lock.
+func (j *Job) hasPendingApproval() bool {
+	return j.Extra.PendingApproval != nil && (j.State == JobWaitingApproval || j.State == JobPaused)
+}
+
+func (j *Job) getPendingApproval() *DDLEvent {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if !j.hasPendingApproval() {
+		return nil
+	}
+	return j.Extra.PendingApproval
+}
+
+// PendingApprovals returns the binlogs wait for the approval of the job and its fan-out jobs.
+func (j *Job) PendingApprovals() []*PendingApproval {
+	approvals := make([]*PendingApproval, 0)
+	if event := j.getPendingApproval(); event != nil {
+		approvals = append(approvals, &PendingApproval{Job: j.Name, DDLEvent: event})
+	}
+	for _, fanout := range j.fanouts {
+		if event := fanout.getPendingApproval(); event != nil {
+			approvals = append(approvals, &PendingApproval{Job: j.Name, Dest: fanout.Name, DDLEvent: event})
+		}
+	}
+	return approvals
+}
+
+// ApproveBinlog applies the binlog waiting for the approval and resumes the job, the
+// fan-out jobs waiting at the same binlog are approved too.
+func (j *Job) ApproveBinlog(commitSeq int64) error {
+	return j.decidePendingBinlog(commitSeq, false)
+}
+
+// RejectBinlog skips the binlog waiting for the approval and resumes the job, the fan-out
+// jobs waiting at the same binlog are rejected too.
+func (j *Job) RejectBinlog(commitSeq int64) error {
+	return j.decidePendingBinlog(commitSeq, true)
+}
+
+func (j *Job) decidePendingBinlog(commitSeq int64, reject bool) error {
+	found := false
+	for _, job := range append([]*Job{j}, j.fanouts...) {
+		resolved, err := job.resolvePendingBinlog(commitSeq, reject)
+		if err != nil {
+			return err
+		}
+		found = found || resolved
+	}
+
+	if !found {
+		return xerror.Errorf(xerror.Normal, "job %s has no binlog %d waiting for approval", j.Name, commitSeq)
+	}
+	return nil
+}
+
+func (j *Job) UpdateDDLPolicy(policy *DDLPolicy) error {
+	if policy.IsEmpty() {
+		policy = nil
+	} else if err := policy.compile(); err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	savedPolicy := j.Extra.DDLPolicy
+	j.Extra.DDLPolicy = policy
+	if err := j.persistJob(); err != nil {
+		j.Extra.DDLPolicy = savedPolicy
+		return err
+	}
+	log.Infof("update ddl policy of job %s: %+v", j.Name, policy)
+
+	for _, fanout := range j.fanouts {
+		if err := fanout.UpdateDDLPolicy(policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
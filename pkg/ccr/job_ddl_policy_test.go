@@ -0,0 +1,180 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestDDLPolicyMatch(t *testing.T) {
+	policy := &DDLPolicy{
+		Rules: []*DDLRule{
+			{BinlogType: "drop_table", Table: "tmp_*", Action: DDLActionApply},
+			{BinlogType: "DROP_TABLE", Action: DDLActionPause},
+			{BinlogType: "TRUNCATE_TABLE", Table: "regex:^log_\\d+$", Action: DDLActionSkipAndRecord},
+		},
+	}
+	if err := policy.compile(); err != nil {
+		t.Fatalf("compile ddl policy failed: %v", err)
+	}
+
+	cases := []struct {
+		binlogType festruct.TBinlogType
+		tables     []string
+		action     string
+	}{
+		{festruct.TBinlogType_DROP_TABLE, []string{"tmp_1"}, DDLActionApply},
+		{festruct.TBinlogType_DROP_TABLE, []string{"orders"}, DDLActionPause},
+		{festruct.TBinlogType_TRUNCATE_TABLE, []string{"log_1"}, DDLActionSkipAndRecord},
+		{festruct.TBinlogType_TRUNCATE_TABLE, []string{"orders"}, ""},
+		{festruct.TBinlogType_ALTER_JOB, []string{"orders"}, ""},
+	}
+	for _, c := range cases {
+		action := ""
+		if rule := policy.Match(c.binlogType, c.tables); rule != nil {
+			action = rule.Action
+		}
+		if action != c.action {
+			t.Errorf("match %s %v, expect %q, got %q", c.binlogType, c.tables, c.action, action)
+		}
+	}
+
+	invalids := []*DDLPolicy{
+		{Rules: []*DDLRule{{BinlogType: "UPSERT", Action: DDLActionSkip}}},
+		{Rules: []*DDLRule{{BinlogType: "NOT_A_TYPE", Action: DDLActionSkip}}},
+		{Rules: []*DDLRule{{BinlogType: "DROP_TABLE", Action: "block"}}},
+	}
+	for _, policy := range invalids {
+		if err := policy.compile(); err == nil {
+			t.Errorf("expect invalid ddl policy: %+v", policy.Rules[0])
+		}
+	}
+}
+
+func TestPendingApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobInfo := ""
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().GetJobInfo("test_job").DoAndReturn(func(name string) (string, error) {
+		return jobInfo, nil
+	}).AnyTimes()
+	db.EXPECT().UpdateJob("test_job", gomock.Any()).DoAndReturn(func(name, info string) error {
+		jobInfo = info
+		return nil
+	}).AnyTimes()
+	metaFactory := NewMockMetaerFactory(ctrl)
+	metaFactory.EXPECT().NewMeta(gomock.Any()).Return(nil).AnyTimes()
+	factory := NewFactory(nil, metaFactory, &binlogTTLSpecer{}, nil)
+
+	job := &Job{
+		Name:     "test_job",
+		SyncType: DBSync,
+		Src:      base.Spec{Database: "src_db"},
+		Dest:     base.Spec{Database: "dest_db"},
+		Dests:    []base.Spec{{Database: "dest_db_1"}},
+		State:    JobRunning,
+		factory:  factory,
+		db:       db,
+	}
+	job.initFanouts()
+	if err := job.persistJob(); err != nil {
+		t.Fatalf("persist job failed, err: %v", err)
+	}
+
+	commitSeq := int64(10)
+	binlogType := festruct.TBinlogType_DROP_TABLE
+	binlog := &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType}
+	holdAll := func(job *Job) {
+		for _, job := range append([]*Job{job}, job.fanouts...) {
+			if err := job.holdForApproval(binlog, []string{"orders"}); err != nil {
+				t.Fatalf("hold job %s for approval failed, err: %v", job.Name, err)
+			}
+		}
+	}
+	checkStates := func(job *Job, state JobState) {
+		for _, job := range append([]*Job{job}, job.fanouts...) {
+			if job.State != state {
+				t.Fatalf("job %s should be %s, got %s", job.Name, state, job.State)
+			}
+		}
+	}
+	holdAll(job)
+
+	// the pending binlogs are kept across the pause and resume
+	if err := job.Pause(); err != nil {
+		t.Fatalf("pause job failed, err: %v", err)
+	}
+	checkStates(job, JobPaused)
+	if approvals := job.PendingApprovals(); len(approvals) != 2 {
+		t.Fatalf("the paused job should keep the pending binlogs, got %d", len(approvals))
+	}
+	if err := job.Resume(); err != nil {
+		t.Fatalf("resume job failed, err: %v", err)
+	}
+	checkStates(job, JobWaitingApproval)
+	if err := job.Resume(); err != nil {
+		t.Fatalf("resume job failed, err: %v", err)
+	}
+	checkStates(job, JobWaitingApproval)
+
+	// the pending binlog of the fan-out job is persisted
+	job, err := NewJobFromJson(jobInfo, db, factory)
+	if err != nil {
+		t.Fatalf("new job from json failed, err: %v", err)
+	}
+	checkStates(job, JobWaitingApproval)
+	if approvals := job.PendingApprovals(); len(approvals) != 2 || approvals[1].Dest != job.fanouts[0].Name {
+		t.Fatalf("the pending binlogs should be recovered, got %d", len(approvals))
+	}
+
+	// reject skips the binlog and resumes the job in one step
+	if err := job.RejectBinlog(commitSeq + 1); err == nil {
+		t.Fatalf("reject the binlog not waiting for approval should fail")
+	}
+	if err := job.RejectBinlog(commitSeq); err != nil {
+		t.Fatalf("reject binlog failed, err: %v", err)
+	}
+	checkStates(job, JobRunning)
+	for _, job := range append([]*Job{job}, job.fanouts...) {
+		if job.Extra.PendingApproval != nil || !job.isSkippedBySilence(binlog) || job.Extra.ApprovedCommitSeq != 0 {
+			t.Fatalf("the binlog of job %s should be skipped, extra: %+v", job.Name, job.Extra)
+		}
+	}
+	if err := job.ApproveBinlog(commitSeq); err == nil {
+		t.Fatalf("approve the rejected binlog should fail")
+	}
+
+	// approve applies the binlog
+	job.Extra.SkipBinlog = false
+	holdAll(job)
+	if err := job.ApproveBinlog(commitSeq); err != nil {
+		t.Fatalf("approve binlog failed, err: %v", err)
+	}
+	checkStates(job, JobRunning)
+	if job.Extra.ApprovedCommitSeq != commitSeq || job.Extra.PendingApproval != nil {
+		t.Fatalf("the binlog should be approved, extra: %+v", job.Extra)
+	}
+}
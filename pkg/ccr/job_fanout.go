@@ -0,0 +1,335 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/rpc"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var fanoutBinlogCacheSize int
+
+func init() {
+	flag.IntVar(&fanoutBinlogCacheSize, "fanout_binlog_cache_size", 10000,
+		"the max number of binlogs cached to share between the destinations of a fan-out job")
+}
+
+// The name of the fan-out job which syncs the src to the index-th extra destination, the
+// progress of the fan-out job is saved in the progress of the job, see DestProgresses.
+func FanoutJobName(jobName string, index int) string {
+	return fmt.Sprintf("%s_dest%d", jobName, index+1)
+}
+
+// The state of a fan-out job. The fan-out job has no job info of its own, so the state is
+// saved with the job of the primary destination.
+type FanoutState struct {
+	State JobState `json:"state"`
+	Extra JobExtra `json:"extra"`
+}
+
+// binlogFeed shares the binlogs fetched from the src cluster between the destinations of
+// a fan-out job, so that one GetBinlog poll could drive the ingestion into all of them.
+//
+// The binlogs in (from, to] are cached. A destination falls behind the cache gets binlogs
+// from the src cluster directly, so it will not block the others.
+type binlogFeed struct {
+	lock     sync.Mutex
+	capacity int
+	from     int64
+	to       int64
+	binlogs  []*festruct.TBinlog
+}
+
+func newBinlogFeed(capacity int) *binlogFeed {
+	return &binlogFeed{
+		capacity: capacity,
+	}
+}
+
+func (f *binlogFeed) GetBinlog(srcRpc rpc.IFeRpc, src *base.Spec, commitSeq int64) (*festruct.TGetBinlogResult_, error) {
+	if binlogs := f.getCachedBinlogs(commitSeq); binlogs != nil {
+		log.Tracef("get %d binlogs from the fan-out cache, commit seq: %d", len(binlogs), commitSeq)
+		return &festruct.TGetBinlogResult_{
+			Status:  &tstatus.TStatus{StatusCode: tstatus.TStatusCode_OK},
+			Binlogs: binlogs,
+		}, nil
+	}
+
+	// Don't hold the lock during the rpc, the destinations hit the cache are not blocked by it.
+	resp, err := srcRpc.GetBinlog(src, commitSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	binlogs := resp.GetBinlogs()
+	if resp.GetStatus().GetStatusCode() == tstatus.TStatusCode_OK && len(binlogs) > 0 {
+		f.cacheBinlogs(commitSeq, binlogs)
+	}
+	return resp, nil
+}
+
+// Get the cached binlogs after the commit seq, returns nil if they are not all cached.
+func (f *binlogFeed) getCachedBinlogs(commitSeq int64) []*festruct.TBinlog {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if len(f.binlogs) == 0 || commitSeq < f.from || commitSeq >= f.to {
+		return nil
+	}
+
+	index := sort.Search(len(f.binlogs), func(i int) bool {
+		return f.binlogs[i].GetCommitSeq() > commitSeq
+	})
+	binlogs := make([]*festruct.TBinlog, len(f.binlogs)-index)
+	copy(binlogs, f.binlogs[index:])
+	return binlogs
+}
+
+// Merge the binlogs after the commit seq, which are got from the src, into the cache.
+func (f *binlogFeed) cacheBinlogs(commitSeq int64, binlogs []*festruct.TBinlog) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	lastCommitSeq := binlogs[len(binlogs)-1].GetCommitSeq()
+	if len(f.binlogs) > 0 && commitSeq == f.to {
+		f.binlogs = append(f.binlogs, binlogs...)
+	} else if len(f.binlogs) == 0 || commitSeq > f.to {
+		f.from = commitSeq
+		f.binlogs = append([]*festruct.TBinlog(nil), binlogs...)
+	} else {
+		// The destination is lag behind the cache, or the binlogs are cached by another
+		// destination during the rpc, don't disturb the others.
+		return
+	}
+	f.to = lastCommitSeq
+
+	if overflow := len(f.binlogs) - f.capacity; overflow > 0 {
+		f.from = f.binlogs[overflow-1].GetCommitSeq()
+		f.binlogs = append([]*festruct.TBinlog(nil), f.binlogs[overflow:]...)
+	}
+}
+
+// Build the fan-out jobs for the extra destinations.
+//
+// Each fan-out job runs its own fullsync, the src snapshot is not shared between the
+// destinations, so N destinations take N backups of the src.
+func (j *Job) initFanouts() {
+	j.fanouts = nil
+	j.binlogFeed = nil
+	if len(j.Dests) == 0 {
+		return
+	}
+
+	j.binlogFeed = newBinlogFeed(fanoutBinlogCacheSize)
+	j.fanoutsStopped = make(chan struct{})
+	j.fanoutsStoppedOnce = sync.Once{}
+	for i := range j.Dests {
+		fanout := &Job{
+			Name:     FanoutJobName(j.Name, i),
+			SyncType: j.SyncType,
+			Src:      j.Src,
+			Dest:     j.Dests[i],
+			State:    j.State,
+			Extra:    j.Extra,
+
+			factory:    j.factory,
+			db:         j.db,
+			jobFactory: NewJobFactory(),
+			stop:       make(chan struct{}),
+			parent:     j,
+			destIndex:  i,
+
+			concurrencyManager: rpc.NewConcurrencyManager(),
+		}
+		if i < len(j.DestStates) && j.DestStates[i] != nil {
+			// Recover the state saved by the fan-out job itself.
+			fanout.State = j.DestStates[i].State
+			fanout.Extra = j.DestStates[i].Extra
+		} else {
+			if fanout.State == JobReachedTarget || fanout.State == JobWaitingApproval ||
+				fanout.State == JobFullSyncPendingApproval {
+				// Each destination checks whether it has reached the target or meets the paused
+				// binlog by its own progress.
+				fanout.State = JobRunning
+			}
+			fanout.Extra.PendingApproval = nil
+			fanout.Extra.SkippedBinlogs = nil
+			fanout.Extra.SchemaDrift = nil
+			fanout.Extra.PendingFullSync = nil
+			fanout.Extra.BinlogRetention = nil
+			fanout.Extra.HeldBinlogLease = nil
+		}
+		// The skip binlog is only for the job itself, and the binlogs are exported by the job
+		// of the primary destination only, once for all destinations.
+		fanout.Extra.SkipBinlog = false
+		fanout.Extra.FileSink = nil
+		fanout.Extra.Sinks = nil
+		fanout.ISrc = j.factory.NewSpecer(&fanout.Src)
+		fanout.IDest = j.factory.NewSpecer(&fanout.Dest)
+		fanout.srcMeta = j.factory.NewMeta(&fanout.Src)
+		fanout.destMeta = j.factory.NewMeta(&fanout.Dest)
+		fanout.initSchedule()
+		j.fanouts = append(j.fanouts, fanout)
+	}
+}
+
+// Save the state of the fan-out job into the job info of the primary destination. Only the
+// dest_states of the job info is updated, so it don't touch the fields guarded by j.lock.
+func (j *Job) persistFanout(fanout *Job) error {
+	j.fanoutLock.Lock()
+	defer j.fanoutLock.Unlock()
+
+	states := make([]*FanoutState, len(j.Dests))
+	copy(states, j.DestStates)
+	states[fanout.destIndex] = &FanoutState{State: fanout.State, Extra: fanout.Extra}
+	statesData, err := json.Marshal(states)
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "marshal the state of fan-out job %s failed", fanout.Name)
+	}
+
+	jobInfo, err := j.db.GetJobInfo(j.Name)
+	if err != nil {
+		return err
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(jobInfo), &data); err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "unmarshal the job info of %s failed", j.Name)
+	}
+	data["dest_states"] = statesData
+	newJobInfo, err := json.Marshal(data)
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "marshal the job info of %s failed", j.Name)
+	}
+	if err := j.db.UpdateJob(j.Name, string(newJobInfo)); err != nil {
+		return err
+	}
+
+	j.DestStates = states
+	return nil
+}
+
+func (j *Job) validFanouts() error {
+	for i := range j.Dests {
+		dest := &j.Dests[i]
+		if err := j.factory.NewSpecer(dest).Valid(); err != nil {
+			return xerror.Wrapf(err, xerror.Normal, "dest %d spec is invalid", i+1)
+		}
+		if (j.Src.Table == "") != (dest.Table == "") {
+			return xerror.Errorf(xerror.Normal, "src/dest %d are not both db or table sync", i+1)
+		}
+		if exist, err := j.db.IsJobExist(FanoutJobName(j.Name, i)); err != nil {
+			return xerror.Wrap(err, xerror.Normal, "check job exist failed")
+		} else if exist {
+			return xerror.Errorf(xerror.Normal, "job %s already exist", FanoutJobName(j.Name, i))
+		}
+	}
+	return nil
+}
+
+func (j *Job) firstRunFanouts() error {
+	for i, fanout := range j.fanouts {
+		if err := fanout.FirstRun(); err != nil {
+			return xerror.Wrapf(err, xerror.Normal, "first run dest %d", i+1)
+		}
+		j.Dests[i] = fanout.Dest
+	}
+	return nil
+}
+
+// Mark the fan-out jobs stopped, or never run.
+func (j *Job) markFanoutsStopped() {
+	if j.fanoutsStopped == nil {
+		return
+	}
+	j.fanoutsStoppedOnce.Do(func() { close(j.fanoutsStopped) })
+}
+
+// Wait the fan-out jobs stopped, the job should be stopped or deleted first.
+func (j *Job) waitFanoutsStopped() {
+	if j.fanoutsStopped != nil {
+		<-j.fanoutsStopped
+	}
+}
+
+// Run the fan-out jobs, and wait them stopped.
+func (j *Job) runFanouts() func() {
+	var wg sync.WaitGroup
+	for _, fanout := range j.fanouts {
+		wg.Add(1)
+		go func(fanout *Job) {
+			defer wg.Done()
+			if err := fanout.Run(); err != nil {
+				log.Errorf("fan-out job run failed, job name: %s, error: %+v", fanout.Name, err)
+			}
+		}(fanout)
+	}
+	return wg.Wait
+}
+
+// Recover the progress of the fan-out job from the progress of the parent job, or create it
+// for the new destination.
+func (j *Job) initFanoutProgress() error {
+	progress, err := j.parent.progress.RecoverDestProgress(j.destIndex)
+	if err != nil {
+		log.Errorf("recover fan-out job %s progress failed: %+v", j.Name, err)
+		return err
+	} else if progress != nil {
+		j.progress = progress
+		return nil
+	}
+
+	j.progress = j.parent.progress.NewDestProgress(j.Name, j.destIndex, j.SyncType)
+	info := fmt.Sprintf("new fan-out job, job: %s, sync type: %v", j.Name, j.SyncType)
+	return j.newSnapshot(0, info)
+}
+
+// The dest of the fan-out job is not persisted, so recover the dest table id which is updated
+// in the fullsync.
+func (j *Job) recoverFanoutDestTableId() {
+	if j.SyncType != TableSync || j.Dest.TableId != 0 {
+		return
+	}
+
+	if destTableId, err := j.destMeta.GetTableId(j.Dest.Table); err != nil {
+		log.Warnf("recover the dest table id of fan-out job %s failed: %+v", j.Name, err)
+	} else {
+		j.Dest.TableId = destTableId
+	}
+}
+
+func (j *Job) getBinlog(srcRpc rpc.IFeRpc, commitSeq int64) (*festruct.TGetBinlogResult_, error) {
+	feed := j.binlogFeed
+	if j.parent != nil {
+		feed = j.parent.binlogFeed
+	}
+	if feed == nil {
+		return srcRpc.GetBinlog(&j.Src, commitSeq)
+	}
+	return feed.GetBinlog(srcRpc, &j.Src, commitSeq)
+}
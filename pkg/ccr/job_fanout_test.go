@@ -0,0 +1,284 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	"go.uber.org/mock/gomock"
+)
+
+func newFeedBinlogs(from, to int64) []*festruct.TBinlog {
+	binlogs := make([]*festruct.TBinlog, 0, to-from+1)
+	for commitSeq := from; commitSeq <= to; commitSeq++ {
+		commitSeq := commitSeq
+		binlogs = append(binlogs, &festruct.TBinlog{CommitSeq: &commitSeq})
+	}
+	return binlogs
+}
+
+func newFeedResult(binlogs []*festruct.TBinlog) *festruct.TGetBinlogResult_ {
+	return &festruct.TGetBinlogResult_{
+		Status:  &tstatus.TStatus{StatusCode: tstatus.TStatusCode_OK},
+		Binlogs: binlogs,
+	}
+}
+
+// Get the binlogs after the commit seq from the feed, returns the first and last commit seq
+// and the number of the binlogs.
+func getFeedBinlogs(t *testing.T, feed *binlogFeed, srcRpc *MockIFeRpc, src *base.Spec, commitSeq int64) (int64, int64, int) {
+	resp, err := feed.GetBinlog(srcRpc, src, commitSeq)
+	if err != nil {
+		t.Fatalf("get binlog failed, err: %v", err)
+	}
+	binlogs := resp.GetBinlogs()
+	if len(binlogs) == 0 {
+		return 0, 0, 0
+	}
+	return binlogs[0].GetCommitSeq(), binlogs[len(binlogs)-1].GetCommitSeq(), len(binlogs)
+}
+
+func TestBinlogFeed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &base.Spec{Database: "db"}
+	srcRpc := NewMockIFeRpc(ctrl)
+	feed := newBinlogFeed(5)
+
+	// the first poll fills the cache with (0, 3]
+	srcRpc.EXPECT().GetBinlog(src, int64(0)).Return(newFeedResult(newFeedBinlogs(1, 3)), nil)
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 0); first != 1 || last != 3 || n != 3 {
+		t.Fatalf("unexpected binlogs [%d, %d] of %d", first, last, n)
+	}
+	if feed.from != 0 || feed.to != 3 {
+		t.Fatalf("unexpected cache (%d, %d]", feed.from, feed.to)
+	}
+
+	// the cache hit, without polling the src
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 1); first != 2 || last != 3 || n != 2 {
+		t.Fatalf("unexpected cached binlogs [%d, %d] of %d", first, last, n)
+	}
+
+	// the poll at the end of the cache appends to it
+	srcRpc.EXPECT().GetBinlog(src, int64(3)).Return(newFeedResult(newFeedBinlogs(4, 5)), nil)
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 3); first != 4 || last != 5 || n != 2 {
+		t.Fatalf("unexpected appended binlogs [%d, %d] of %d", first, last, n)
+	}
+	if feed.from != 0 || feed.to != 5 || len(feed.binlogs) != 5 {
+		t.Fatalf("unexpected cache (%d, %d] of %d", feed.from, feed.to, len(feed.binlogs))
+	}
+
+	// the cache is trimmed to the capacity
+	srcRpc.EXPECT().GetBinlog(src, int64(5)).Return(newFeedResult(newFeedBinlogs(6, 7)), nil)
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 5); first != 6 || last != 7 || n != 2 {
+		t.Fatalf("unexpected appended binlogs [%d, %d] of %d", first, last, n)
+	}
+	if feed.from != 2 || feed.to != 7 || len(feed.binlogs) != 5 || feed.binlogs[0].GetCommitSeq() != 3 {
+		t.Fatalf("unexpected trimmed cache (%d, %d] of %d", feed.from, feed.to, len(feed.binlogs))
+	}
+
+	// the lagging reader polls the src directly, and the cache is kept
+	srcRpc.EXPECT().GetBinlog(src, int64(1)).Return(newFeedResult(newFeedBinlogs(2, 4)), nil)
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 1); first != 2 || last != 4 || n != 3 {
+		t.Fatalf("unexpected lagging binlogs [%d, %d] of %d", first, last, n)
+	}
+	if feed.from != 2 || feed.to != 7 || len(feed.binlogs) != 5 {
+		t.Fatalf("the cache should be kept for the lagging reader, cache (%d, %d] of %d", feed.from, feed.to, len(feed.binlogs))
+	}
+
+	// the reader beyond the cache restarts it
+	srcRpc.EXPECT().GetBinlog(src, int64(9)).Return(newFeedResult(newFeedBinlogs(10, 11)), nil)
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 9); first != 10 || last != 11 || n != 2 {
+		t.Fatalf("unexpected binlogs [%d, %d] of %d", first, last, n)
+	}
+	if feed.from != 9 || feed.to != 11 || len(feed.binlogs) != 2 {
+		t.Fatalf("unexpected restarted cache (%d, %d] of %d", feed.from, feed.to, len(feed.binlogs))
+	}
+}
+
+func TestFanoutState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	metaFactory := NewMockMetaerFactory(ctrl)
+	metaFactory.EXPECT().NewMeta(gomock.Any()).Return(nil).AnyTimes()
+	factory := NewFactory(nil, metaFactory, &binlogTTLSpecer{}, nil)
+
+	job := &Job{
+		Name:     "test_job",
+		SyncType: DBSync,
+		Src:      base.Spec{Database: "src_db"},
+		Dest:     base.Spec{Database: "dest_db"},
+		Dests:    []base.Spec{{Database: "dest_db_1"}, {Database: "dest_db_2"}},
+		State:    JobRunning,
+		factory:  factory,
+		db:       db,
+	}
+	job.Extra.FileSink = &FileSink{Dir: t.TempDir()}
+	job.Extra.Sinks = []*SinkConfig{{Name: "webhook", Type: SinkTypeWebhook}}
+	job.initFanouts()
+
+	// the binlogs are exported by the job of the primary destination only
+	for _, fanout := range job.fanouts {
+		if fanout.Extra.FileSink != nil || len(fanout.Extra.Sinks) != 0 || len(fanout.sinks) != 0 {
+			t.Fatalf("the fan-out job %s should not export the binlogs", fanout.Name)
+		}
+	}
+
+	// the state of the fan-out job is saved into the job info of the primary destination
+	jobInfo, err := json.Marshal(job)
+	if err != nil {
+		t.Fatalf("marshal job failed, err: %v", err)
+	}
+	db.EXPECT().GetJobInfo(job.Name).Return(string(jobInfo), nil)
+	db.EXPECT().UpdateJob(job.Name, gomock.Any()).DoAndReturn(func(name, info string) error {
+		jobInfo = []byte(info)
+		return nil
+	})
+	fanout := job.fanouts[1]
+	fanout.State = JobWaitingApproval
+	fanout.Extra.ApprovedCommitSeq = 10
+	if err := fanout.persistJob(); err != nil {
+		t.Fatalf("persist fan-out job failed, err: %v", err)
+	}
+
+	recovered := &Job{}
+	if err := json.Unmarshal(jobInfo, recovered); err != nil {
+		t.Fatalf("unmarshal job failed, err: %v", err)
+	}
+	if recovered.Name != job.Name || len(recovered.Dests) != 2 || recovered.State != JobRunning {
+		t.Fatalf("the job info of the primary destination should be kept, job: %+v", recovered)
+	}
+	recovered.factory = factory
+	recovered.db = db
+	recovered.initFanouts()
+	if state := recovered.fanouts[0].State; state != JobRunning {
+		t.Errorf("the state of dest 1 should be running, got %s", state)
+	}
+	if state := recovered.fanouts[1].State; state != JobWaitingApproval {
+		t.Errorf("the state of dest 2 should be recovered, got %s", state)
+	}
+	if commitSeq := recovered.fanouts[1].Extra.ApprovedCommitSeq; commitSeq != 10 {
+		t.Errorf("the extra of dest 2 should be recovered, got approved commit seq %d", commitSeq)
+	}
+}
+
+func TestBinlogFeedNotBlockedByRpc(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := &base.Spec{Database: "db"}
+	srcRpc := NewMockIFeRpc(ctrl)
+	feed := newBinlogFeed(10)
+
+	srcRpc.EXPECT().GetBinlog(src, int64(0)).Return(newFeedResult(newFeedBinlogs(1, 3)), nil)
+	getFeedBinlogs(t, feed, srcRpc, src, 0)
+
+	// the poll at the end of the cache is blocked in the rpc
+	inRpc := make(chan struct{})
+	release := make(chan struct{})
+	srcRpc.EXPECT().GetBinlog(src, int64(3)).DoAndReturn(func(*base.Spec, int64) (*festruct.TGetBinlogResult_, error) {
+		close(inRpc)
+		<-release
+		return newFeedResult(newFeedBinlogs(4, 5)), nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := feed.GetBinlog(srcRpc, src, 3); err != nil {
+			t.Errorf("get binlog failed: %v", err)
+		}
+	}()
+	<-inRpc
+
+	// the cache hit is not blocked by the rpc
+	if first, last, n := getFeedBinlogs(t, feed, srcRpc, src, 1); first != 2 || last != 3 || n != 2 {
+		t.Fatalf("unexpected cached binlogs [%d, %d] of %d", first, last, n)
+	}
+
+	close(release)
+	<-done
+	if feed.from != 0 || feed.to != 5 || len(feed.binlogs) != 5 {
+		t.Fatalf("unexpected cache (%d, %d] of %d", feed.from, feed.to, len(feed.binlogs))
+	}
+}
+
+func TestDestProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the progress row of the job
+	row := ""
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().IsProgressExist("test_job").DoAndReturn(func(string) (bool, error) {
+		return row != "", nil
+	}).AnyTimes()
+	db.EXPECT().GetProgress("test_job").DoAndReturn(func(string) (string, error) {
+		return row, nil
+	}).AnyTimes()
+	db.EXPECT().UpdateProgress("test_job", gomock.Any()).DoAndReturn(func(name, progress string) error {
+		row = progress
+		return nil
+	}).AnyTimes()
+
+	progress := NewJobProgress("test_job", DBSync, db)
+	progress.CommitSeq = 5
+	progress.Persist()
+
+	// the progress of the fan-out job is saved in the progress row of the job
+	destProgress := progress.NewDestProgress(FanoutJobName("test_job", 1), 1, DBSync)
+	destProgress.CommitSeq = 20
+	destProgress.Persist()
+	progress.CommitSeq = 6
+	progress.Persist()
+
+	recovered, err := NewJobProgressFromJson("test_job", db)
+	if err != nil {
+		t.Fatalf("recover progress failed: %v", err)
+	}
+	if recovered.CommitSeq != 6 || len(recovered.DestProgresses) != 1 {
+		t.Fatalf("unexpected recovered progress, commit seq: %d, dest progresses: %d",
+			recovered.CommitSeq, len(recovered.DestProgresses))
+	}
+	if dest, err := recovered.RecoverDestProgress(0); err != nil || dest != nil {
+		t.Errorf("expect no progress of dest 1, got %v, err: %v", dest, err)
+	}
+	dest, err := recovered.RecoverDestProgress(1)
+	if err != nil || dest == nil {
+		t.Fatalf("recover the progress of dest 2 failed: %v", err)
+	}
+	if dest.JobName != "test_job_dest2" || dest.CommitSeq != 20 {
+		t.Errorf("unexpected progress of dest 2, name: %s, commit seq: %d", dest.JobName, dest.CommitSeq)
+	}
+
+	// the progress row is not created again once the job is removed
+	row = ""
+	dest.CommitSeq = 21
+	dest.Persist()
+	if row != "" {
+		t.Errorf("the progress row should not be created by the fan-out job, got %s", row)
+	}
+}
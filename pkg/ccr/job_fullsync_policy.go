@@ -0,0 +1,281 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFullSyncWindowSeconds = 24 * 60 * 60
+
+	// The data size of src is cached, since it is checked with the job lock held.
+	srcDataSizeCacheDuration = 10 * time.Minute
+)
+
+var (
+	// The error returned when the full sync is held by the policy, the binlog is handled again
+	// after the job is resumed.
+	errFullSyncPendingApproval = xerror.NewWithoutStack(xerror.Normal, "the full sync is pending approval")
+	// The error returned when the data size of src is being fetched, the full sync is checked
+	// again in the next round.
+	errFullSyncWaitDataSize = xerror.NewWithoutStack(xerror.Normal, "the full sync waits for the data size of src")
+)
+
+// Whether the full sync is deferred by the policy, it is not a failure of the job.
+func isFullSyncDeferred(err error) bool {
+	return errors.Is(err, errFullSyncPendingApproval) || errors.Is(err, errFullSyncWaitDataSize)
+}
+
+// The data size of src fetched in background.
+type srcDataSize struct {
+	size      int64
+	err       error
+	fetchedAt time.Time
+}
+
+// FullSyncPolicy limits the full syncs triggered automatically, such as by the meta errors and
+// the binlogs which could not be synced incrementally. The full syncs beyond the limits wait
+// for the approval of the operator.
+type FullSyncPolicy struct {
+	// The max automatic full syncs in the window, zero means no limit.
+	MaxFullSyncs int `json:"max_full_syncs,omitempty"`
+	// The window in seconds, 24 hours by default.
+	WindowSeconds int64 `json:"window_seconds,omitempty"`
+	// Require the approval if the data size in bytes of the src db (or table) is larger than
+	// it, zero means no limit.
+	ApprovalSizeBytes int64 `json:"approval_size_bytes,omitempty"`
+}
+
+// The full sync waits for the approval.
+type PendingFullSync struct {
+	CommitSeq int64 `json:"commit_seq"`
+	// The reason of the full sync, see FullSyncInfo.
+	Info string `json:"info"`
+	// Why the full sync is held by the policy.
+	Reason string `json:"reason"`
+	// The unix time in milliseconds.
+	RequestedAt int64 `json:"requested_at"`
+}
+
+func (p *FullSyncPolicy) Valid() error {
+	if p != nil && (p.MaxFullSyncs < 0 || p.WindowSeconds < 0 || p.ApprovalSizeBytes < 0) {
+		return xerror.Errorf(xerror.Normal, "invalid full sync policy: %+v", *p)
+	}
+	return nil
+}
+
+func (p *FullSyncPolicy) window() time.Duration {
+	if p.WindowSeconds <= 0 {
+		return defaultFullSyncWindowSeconds * time.Second
+	}
+	return time.Duration(p.WindowSeconds) * time.Second
+}
+
+// Check the full syncs triggered in the window, returns the reason to hold the full sync.
+func (p *FullSyncPolicy) checkLimit(fullSyncAt []int64, now time.Time) string {
+	if p.MaxFullSyncs <= 0 {
+		return ""
+	}
+
+	count := 0
+	for _, at := range fullSyncAt {
+		if now.Sub(time.UnixMilli(at)) < p.window() {
+			count += 1
+		}
+	}
+	if count >= p.MaxFullSyncs {
+		return fmt.Sprintf("%d full syncs have been triggered in %s", count, p.window())
+	}
+	return ""
+}
+
+// Whether the new snapshot is a full sync triggered automatically, which should be checked by
+// the full sync policy. The caller must hold the job lock.
+func (j *Job) isAutoFullSync(fullSyncInfo string) bool {
+	switch {
+	case fullSyncInfo == "":
+		// restart the full sync which is done or in progress
+		return false
+	case j.progress.SyncState == DBFullSync || j.progress.SyncState == TableFullSync:
+		return false
+	case j.Extra.SkipBinlog && j.Extra.SkipBy == SkipByFullSync:
+		// required by the user
+		return false
+	default:
+		return true
+	}
+}
+
+// Check the full sync policy before a new snapshot, the job is parked in the
+// JobFullSyncPendingApproval state if the full sync is not allowed.
+func (j *Job) checkFullSyncPolicy(commitSeq int64, fullSyncInfo string) error {
+	if !j.isAutoFullSync(fullSyncInfo) {
+		return nil
+	}
+	now := time.Now()
+	if j.fullSyncApproved {
+		j.fullSyncApproved = false
+		j.recordFullSync(now)
+		return nil
+	}
+
+	policy := j.Extra.FullSyncPolicy
+	reason := ""
+	if policy != nil {
+		reason = policy.checkLimit(j.progress.FullSyncAt, now)
+		if reason == "" && policy.ApprovalSizeBytes > 0 {
+			if size, err := j.getSrcDataSize(now); errors.Is(err, errFullSyncWaitDataSize) {
+				return err
+			} else if err != nil {
+				reason = fmt.Sprintf("get the data size of src failed: %v", err)
+			} else if size > policy.ApprovalSizeBytes {
+				reason = fmt.Sprintf("the data size %d of src exceeds %d", size, policy.ApprovalSizeBytes)
+			}
+		}
+	}
+
+	if reason == "" {
+		j.recordFullSync(now)
+		return nil
+	}
+
+	log.Warnf("job %s holds the full sync for approval, reason: %s, full sync info: %s", j.Name, reason, fullSyncInfo)
+	savedExtra := j.Extra
+	originState := j.State
+	j.Extra.PendingFullSync = &PendingFullSync{
+		CommitSeq:   commitSeq,
+		Info:        fullSyncInfo,
+		Reason:      reason,
+		RequestedAt: now.UnixMilli(),
+	}
+	j.State = JobFullSyncPendingApproval
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		j.State = originState
+		return err
+	}
+	j.updateJobStatus()
+	return errFullSyncPendingApproval
+}
+
+// Get the data size of src fetched in srcDataSizeCacheDuration. Otherwise the data size is
+// fetched in background, rather than with the job lock held, and errFullSyncWaitDataSize
+// is returned.
+func (j *Job) getSrcDataSize(now time.Time) (int64, error) {
+	if cached := j.srcDataSize.Load(); cached != nil && now.Sub(cached.fetchedAt) < srcDataSizeCacheDuration {
+		return cached.size, cached.err
+	}
+
+	if j.srcDataSizeFetching.CompareAndSwap(false, true) {
+		go func() {
+			defer j.srcDataSizeFetching.Store(false)
+			size, err := j.factory.NewSpecer(&j.Src).GetDataSize()
+			if err != nil {
+				log.Warnf("get the data size of src of job %s failed, err: %+v", j.Name, err)
+			}
+			j.srcDataSize.Store(&srcDataSize{size: size, err: err, fetchedAt: time.Now()})
+		}()
+	}
+	return 0, errFullSyncWaitDataSize
+}
+
+// Record the time of the automatic full sync, the records out of the max window are removed.
+func (j *Job) recordFullSync(now time.Time) {
+	window := defaultFullSyncWindowSeconds * time.Second
+	if policy := j.Extra.FullSyncPolicy; policy != nil && policy.window() > window {
+		window = policy.window()
+	}
+
+	fullSyncAt := make([]int64, 0, len(j.progress.FullSyncAt)+1)
+	for _, at := range j.progress.FullSyncAt {
+		if now.Sub(time.UnixMilli(at)) < window {
+			fullSyncAt = append(fullSyncAt, at)
+		}
+	}
+	j.progress.FullSyncAt = append(fullSyncAt, now.UnixMilli())
+}
+
+func (j *Job) approveFullSync() (bool, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.State != JobFullSyncPendingApproval || j.Extra.PendingFullSync == nil || j.progress == nil {
+		return false, nil
+	}
+
+	pending := j.Extra.PendingFullSync
+	savedExtra := j.Extra
+	j.Extra.PendingFullSync = nil
+	j.State = JobRunning
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		j.State = JobFullSyncPendingApproval
+		return false, err
+	}
+	j.updateJobStatus()
+
+	log.Infof("approve the full sync of job %s, commit seq: %d, info: %s", j.Name, pending.CommitSeq, pending.Info)
+	j.fullSyncApproved = true
+	if err := j.newSnapshot(pending.CommitSeq, pending.Info); err != nil {
+		j.fullSyncApproved = false
+		return false, err
+	}
+	return true, nil
+}
+
+// ApproveFullSync starts the full sync which is pending approval, the fan-out jobs waiting for
+// the approval are approved too.
+func (j *Job) ApproveFullSync() error {
+	found := false
+	for _, job := range append([]*Job{j}, j.fanouts...) {
+		approved, err := job.approveFullSync()
+		if err != nil {
+			return err
+		}
+		found = found || approved
+	}
+
+	if !found {
+		return xerror.Errorf(xerror.Normal, "job %s has no full sync pending approval", j.Name)
+	}
+	return nil
+}
+
+// Resume the job without the full sync, the full sync might be required again.
+func (j *Job) discardPendingFullSync() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	savedExtra := j.Extra
+	j.Extra.PendingFullSync = nil
+	j.State = JobRunning
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		j.State = JobFullSyncPendingApproval
+		return err
+	}
+	j.updateJobStatus()
+	log.Infof("resume job %s without the pending full sync", j.Name)
+	return nil
+}
@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestFullSyncPolicyCheckLimit(t *testing.T) {
+	now := time.Now()
+	fullSyncAt := []int64{
+		now.Add(-25 * time.Hour).UnixMilli(),
+		now.Add(-2 * time.Hour).UnixMilli(),
+		now.Add(-time.Hour).UnixMilli(),
+	}
+
+	policy := &FullSyncPolicy{}
+	if reason := policy.checkLimit(fullSyncAt, now); reason != "" {
+		t.Fatalf("no limit should not hold the full sync, reason: %s", reason)
+	}
+
+	// the full sync out of the default window is not counted
+	policy = &FullSyncPolicy{MaxFullSyncs: 3}
+	if reason := policy.checkLimit(fullSyncAt, now); reason != "" {
+		t.Fatalf("the full sync should be allowed, reason: %s", reason)
+	}
+
+	policy = &FullSyncPolicy{MaxFullSyncs: 2}
+	if reason := policy.checkLimit(fullSyncAt, now); reason == "" {
+		t.Fatalf("the full sync should be held")
+	}
+
+	policy = &FullSyncPolicy{MaxFullSyncs: 2, WindowSeconds: 90 * 60}
+	if reason := policy.checkLimit(fullSyncAt, now); reason != "" {
+		t.Fatalf("the full sync should be allowed in the smaller window, reason: %s", reason)
+	}
+
+	if err := (&FullSyncPolicy{MaxFullSyncs: -1}).Valid(); err == nil {
+		t.Fatalf("the negative max full syncs should be invalid")
+	}
+}
+
+// dataSizeSpecer returns the data size of src, and counts the calls.
+type dataSizeSpecer struct {
+	base.Specer
+	size  int64
+	calls int
+}
+
+func (s *dataSizeSpecer) GetDataSize() (int64, error) {
+	s.calls += 1
+	return s.size, nil
+}
+
+func (s *dataSizeSpecer) NewSpecer(spec *base.Spec) base.Specer {
+	return s
+}
+
+func TestFullSyncPolicyDataSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateJob(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	specer := &dataSizeSpecer{size: 200}
+	job := &Job{Name: "test_job", SyncType: DBSync, State: JobRunning, db: db, factory: NewFactory(nil, nil, specer, nil)}
+	job.Extra.FullSyncPolicy = &FullSyncPolicy{ApprovalSizeBytes: 100}
+	job.progress = NewJobProgress(job.Name, DBSync, db)
+	job.progress.SyncState = DBIncrementalSync
+
+	// the data size is fetched in background, the full sync is checked again later
+	err := job.checkFullSyncPolicy(10, "meta error")
+	if !errors.Is(err, errFullSyncWaitDataSize) || !isFullSyncDeferred(err) {
+		t.Fatalf("the full sync should wait for the data size, err: %v", err)
+	}
+	for i := 0; job.srcDataSize.Load() == nil; i++ {
+		if i > 100 {
+			t.Fatalf("the data size is not fetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = job.checkFullSyncPolicy(10, "meta error")
+	if !errors.Is(err, errFullSyncPendingApproval) || !isFullSyncDeferred(err) {
+		t.Fatalf("the full sync should be held, err: %v", err)
+	}
+	if job.State != JobFullSyncPendingApproval || job.Extra.PendingFullSync == nil {
+		t.Fatalf("the job should wait for the approval, state: %s", job.State)
+	}
+	if specer.calls != 1 {
+		t.Fatalf("the data size should be cached, calls: %d", specer.calls)
+	}
+}
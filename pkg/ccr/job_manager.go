@@ -19,6 +19,7 @@ package ccr
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/selectdb/ccr_syncer/pkg/storage"
@@ -125,24 +126,38 @@ func (jm *JobManager) RemoveJob(name string) error {
 	log.Infof("remove job: %s", name)
 
 	jm.lock.Lock()
-	defer jm.lock.Unlock()
-
 	job := jm.jobs[name]
 	// check job exist
 	if job == nil {
+		jm.lock.Unlock()
 		return xerror.Errorf(xerror.Normal, "job not exist: %s", name)
 	}
 
 	// stop job
 	job.Delete()
-	if err := jm.db.RemoveJob(name); err == nil {
-		delete(jm.jobs, name)
-		log.Infof("job [%s] has been successfully deleted, but it needs to wait until an isochronous point before it will completely STOP", name)
-		return nil
-	} else {
+	if err := jm.db.RemoveJob(name); err != nil {
+		jm.lock.Unlock()
 		log.Errorf("remove job [%s] in db failed: %+v, but job is stopped", name, err)
 		return fmt.Errorf("remove job [%s] in db failed, but job is stopped, if can resume/delete, please do it manually", name)
 	}
+	delete(jm.jobs, name)
+	jm.lock.Unlock()
+
+	if len(job.Dests) > 0 {
+		// The fan-out jobs save their progress into the progress of the job until they are
+		// stopped, the progress might be written again if it is removed in the middle of it.
+		log.Infof("wait the fan-out jobs of job %s stopped", name)
+		job.waitFanoutsStopped()
+		jm.lock.Lock()
+		if _, ok := jm.jobs[name]; !ok {
+			if err := jm.db.RemoveJob(name); err != nil {
+				log.Warnf("remove the progress of job %s in db failed: %+v", name, err)
+			}
+		}
+		jm.lock.Unlock()
+	}
+	log.Infof("job [%s] has been successfully deleted, but it needs to wait until an isochronous point before it will completely STOP", name)
+	return nil
 }
 
 // go run all jobs and wait for stop chan
@@ -264,3 +279,64 @@ func (jm *JobManager) SkipBinlog(jobName string, skipCommitSeq int64, skipBy str
 		return xerror.Errorf(xerror.Normal, "job not exist: %s", jobName)
 	}
 }
+
+func (jm *JobManager) SetStopAt(jobName string, commitSeq, timestamp int64) error {
+	return jm.dealJob(jobName, func(job *Job) error {
+		return job.SetStopAt(commitSeq, timestamp)
+	})
+}
+
+func (jm *JobManager) UpdateSchedule(jobName string, schedule *SyncSchedule) error {
+	return jm.dealJob(jobName, func(job *Job) error {
+		return job.UpdateSchedule(schedule)
+	})
+}
+
+func (jm *JobManager) UpdateDDLPolicy(jobName string, policy *DDLPolicy) error {
+	return jm.dealJob(jobName, func(job *Job) error {
+		return job.UpdateDDLPolicy(policy)
+	})
+}
+
+// ListPendingApprovals returns the binlogs waiting for the approval of all jobs.
+func (jm *JobManager) ListPendingApprovals() []*PendingApproval {
+	jm.lock.RLock()
+	defer jm.lock.RUnlock()
+
+	approvals := make([]*PendingApproval, 0)
+	for _, job := range jm.jobs {
+		approvals = append(approvals, job.PendingApprovals()...)
+	}
+	sort.Slice(approvals, func(i, k int) bool {
+		return approvals[i].HandledAt < approvals[k].HandledAt
+	})
+	return approvals
+}
+
+func (jm *JobManager) ApproveBinlog(jobName string, commitSeq int64) error {
+	return jm.dealJob(jobName, func(job *Job) error {
+		return job.ApproveBinlog(commitSeq)
+	})
+}
+
+func (jm *JobManager) RejectBinlog(jobName string, commitSeq int64) error {
+	return jm.dealJob(jobName, func(job *Job) error {
+		return job.RejectBinlog(commitSeq)
+	})
+}
+
+func (jm *JobManager) Verify(jobName string, options *VerifyOptions) (*VerifyReport, error) {
+	var report *VerifyReport
+	err := jm.dealJob(jobName, func(job *Job) error {
+		var err error
+		report, err = job.Verify(options)
+		return err
+	})
+	return report, err
+}
+
+func (jm *JobManager) ApproveFullSync(jobName string) error {
+	return jm.dealJob(jobName, func(job *Job) error {
+		return job.ApproveFullSync()
+	})
+}
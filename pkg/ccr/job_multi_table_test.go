@@ -0,0 +1,167 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+// multiTableSpecer keeps the binlog properties of the src database and tables, all tables
+// exist in src and the dest database is empty.
+type multiTableSpecer struct {
+	base.Specer
+	dest        bool
+	dbBinlog    bool
+	tableBinlog map[string]bool
+	table       string
+}
+
+func (s *multiTableSpecer) IsDatabaseEnableBinlog() (bool, error) {
+	return s.dbBinlog, nil
+}
+
+func (s *multiTableSpecer) CheckDatabaseExists() (bool, error) {
+	return true, nil
+}
+
+func (s *multiTableSpecer) CheckTableExistsByName(tableName string) (bool, error) {
+	return !s.dest, nil
+}
+
+func (s *multiTableSpecer) CheckTablePropertyValid() ([]string, error) {
+	return nil, nil
+}
+
+func (s *multiTableSpecer) GetBinlogProperties() (*base.BinlogProperties, error) {
+	if s.dbBinlog {
+		return &base.BinlogProperties{Enable: true}, nil
+	}
+	return &base.BinlogProperties{Enable: s.tableBinlog[s.table], TableLevel: true}, nil
+}
+
+func (s *multiTableSpecer) NewSpecer(spec *base.Spec) base.Specer {
+	return &multiTableSpecer{dbBinlog: s.dbBinlog, tableBinlog: s.tableBinlog, table: spec.Table}
+}
+
+func TestMultiTableFirstRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	meta := NewMockMetaer(ctrl)
+	meta.EXPECT().GetFrontends().Return(nil, nil).AnyTimes()
+	meta.EXPECT().CheckBinlogFeature().Return(nil).AnyTimes()
+	meta.EXPECT().GetDbId().Return(int64(1), nil).AnyTimes()
+	meta.EXPECT().GetTableId("orders").Return(int64(100), nil).AnyTimes()
+	meta.EXPECT().GetTableId("users").Return(int64(101), nil).AnyTimes()
+
+	newJob := func(specer *multiTableSpecer) *Job {
+		job := &Job{
+			Name:     "test_job",
+			SyncType: DBSync,
+			Src:      base.Spec{Database: "src_db"},
+			Dest:     base.Spec{Database: "dest_db"},
+			ISrc:     specer,
+			IDest:    &multiTableSpecer{dest: true},
+			srcMeta:  meta,
+			destMeta: meta,
+			factory:  NewFactory(nil, nil, specer, nil),
+		}
+		job.Extra.Tables = []string{"orders", "users"}
+		return job
+	}
+
+	// the database doesn't enable binlog, each listed table must enable it
+	job := newJob(&multiTableSpecer{tableBinlog: map[string]bool{"orders": true}})
+	if err := job.FirstRun(); err == nil || !strings.Contains(err.Error(), "src table src_db.users not enable binlog") {
+		t.Fatalf("the table without binlog should be rejected, err: %v", err)
+	}
+
+	job = newJob(&multiTableSpecer{tableBinlog: map[string]bool{"orders": true, "users": true}})
+	if err := job.FirstRun(); err != nil {
+		t.Fatalf("first run failed, err: %v", err)
+	}
+	if !reflect.DeepEqual(job.Extra.TableIds, []int64{100, 101}) {
+		t.Fatalf("the ids of the listed tables should be saved, got %v", job.Extra.TableIds)
+	}
+
+	job = newJob(&multiTableSpecer{dbBinlog: true})
+	if err := job.FirstRun(); err != nil {
+		t.Fatalf("first run failed, err: %v", err)
+	}
+}
+
+func TestListedTableIds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateJob("test_job", gomock.Any()).Return(nil).AnyTimes()
+
+	job := &Job{Name: "test_job", SyncType: DBSync, db: db}
+	job.Extra.Tables = []string{"orders", "users"}
+	job.Extra.TableIds = []int64{100, 101}
+	job.progress = NewJobProgress(job.Name, DBSync, db)
+	job.progress.TableNameMapping = map[int64]string{100: "orders", 101: "users", 102: "logs"}
+
+	checkFilteredOut := func(tableId int64, expect bool) {
+		t.Helper()
+		if skip, err := job.isTableFilteredOut(tableId); err != nil || skip != expect {
+			t.Fatalf("table %d should be filtered out: %v, got %v, err: %v", tableId, expect, skip, err)
+		}
+	}
+	checkFilteredOut(100, false)
+	checkFilteredOut(102, true)
+
+	// the renamed table is still listed
+	job.progress.TableNameMapping[100] = "orders_v2"
+	checkFilteredOut(100, false)
+	if err := job.updateListedTable(job.listedTableIndex(100), 100, "orders_v2"); err != nil {
+		t.Fatalf("update listed table failed, err: %v", err)
+	}
+	if !job.isTableSelected("orders_v2") || job.isTableSelected("orders") {
+		t.Fatalf("the listed table should be renamed, tables: %v", job.Extra.Tables)
+	}
+
+	// the table created again is tracked by the new id
+	if err := job.updateListedTableId("users", 103); err != nil {
+		t.Fatalf("update listed table id failed, err: %v", err)
+	}
+	checkFilteredOut(101, true)
+	checkFilteredOut(103, false)
+
+	// the table filter is still checked by the name
+	filter, err := NewTableFilter(nil, []string{"orders_*"})
+	if err != nil {
+		t.Fatalf("new table filter failed, err: %v", err)
+	}
+	job.Extra.TableFilter = filter
+	checkFilteredOut(100, true)
+	checkFilteredOut(103, false)
+
+	// the job created before the ids are saved is tracked by the names
+	job.Extra.TableFilter = nil
+	job.Extra.TableIds = nil
+	checkFilteredOut(100, false)
+	checkFilteredOut(102, true)
+}
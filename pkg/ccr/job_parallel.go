@@ -0,0 +1,267 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"flag"
+	"sync"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	"github.com/modern-go/gls"
+	log "github.com/sirupsen/logrus"
+)
+
+var parallelApplyConcurrency int
+
+func init() {
+	flag.IntVar(&parallelApplyConcurrency, "parallel_apply_concurrency", 0,
+		"the max number of the upserts of disjoint tables applied concurrently in db sync, 0 or 1 means no parallel")
+}
+
+// parallelUpsert is an upsert to apply in the parallel mode.
+type parallelUpsert struct {
+	commitSeq    int64
+	label        string
+	tableRecords []*record.TableRecord
+	destTableIds []int64
+}
+
+// Collect the consecutive upsert binlogs from the head of binlogs, they will be applied
+// concurrently if they touch disjoint tables. Any other binlog, such as DDL or txn insert,
+// is a barrier.
+func (j *Job) collectParallelUpserts(binlogs []*festruct.TBinlog) ([]*festruct.TBinlog, []*record.Upsert) {
+	if parallelApplyConcurrency <= 1 || j.SyncType != DBSync || j.Extra.SkipBinlog ||
+		j.progress.SyncState != DBIncrementalSync || j.progress.TableCommitSeqMap != nil {
+		return nil, nil
+	}
+
+	batch := make([]*festruct.TBinlog, 0)
+	upserts := make([]*record.Upsert, 0)
+	for _, binlog := range binlogs {
+		if binlog.GetType() != festruct.TBinlogType_UPSERT {
+			break
+		}
+		if len(batch) > 0 && (j.isBeyondStopAt(binlog) || !j.allowApplyBinlog(binlog, len(batch))) {
+			break
+		}
+
+		upsert, err := record.NewUpsertFromJson(binlog.GetData())
+		if err != nil || len(upsert.Stids) > 0 {
+			// leave it to the handleUpsert
+			break
+		}
+		batch = append(batch, binlog)
+		upserts = append(upserts, upsert)
+	}
+	if len(batch) < 2 {
+		return nil, nil
+	}
+	return batch, upserts
+}
+
+// Group the upserts by the tables they touch, the upserts in a group are applied in order,
+// and the groups are independent to each other.
+func groupUpsertsByTables(upserts []*parallelUpsert) [][]*parallelUpsert {
+	// union find over the indexes of upserts
+	parents := make([]int, len(upserts))
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	tableOwners := make(map[int64]int)
+	for i, upsert := range upserts {
+		parents[i] = i
+		for _, tableRecord := range upsert.tableRecords {
+			if owner, ok := tableOwners[tableRecord.Id]; ok {
+				parents[find(i)] = find(owner)
+			} else {
+				tableOwners[tableRecord.Id] = i
+			}
+		}
+	}
+
+	groups := make([][]*parallelUpsert, 0)
+	groupIndexes := make(map[int]int)
+	for i, upsert := range upserts {
+		root := find(i)
+		index, ok := groupIndexes[root]
+		if !ok {
+			index = len(groups)
+			groupIndexes[root] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], upsert)
+	}
+	return groups
+}
+
+// Apply the upserts concurrently, the upserts touching the same tables are still applied in order.
+//
+// The progress switches to DBTablesIncrementalSync during applying, and the commit seq of each
+// table is saved in TableCommitSeqMap once its upsert committed. So if the job is interrupted,
+// the binlogs are handled again from the first one of the batch, and the committed ones of each
+// table are skipped. The progress advances to the last commit seq once all upserts committed.
+func (j *Job) handleUpsertsInParallel(batch []*festruct.TBinlog, records []*record.Upsert) error {
+	upserts := make([]*parallelUpsert, 0, len(records))
+	for _, upsert := range records {
+		if j.Extra.Bidirectional && j.isSyncerOriginatedUpsert(upsert) {
+			log.Debugf("skip the upsert written by syncer, commit seq: %d, label: %s",
+				upsert.CommitSeq, upsert.Label)
+			continue
+		}
+
+		tableRecords, destTableIds, err := j.getUpsertTableRecords(upsert)
+		if err != nil {
+			return err
+		}
+		if len(tableRecords) == 0 {
+			continue
+		}
+
+		label := upsert.Label
+		if !j.Extra.ReuseBinlogLabel {
+			label = j.newLabel(upsert.CommitSeq)
+		}
+		upserts = append(upserts, &parallelUpsert{
+			commitSeq:    upsert.CommitSeq,
+			label:        label,
+			tableRecords: tableRecords,
+			destTableIds: destTableIds,
+		})
+	}
+
+	groups := groupUpsertsByTables(upserts)
+	log.Infof("handle %d upserts in %d parallel groups, commit seq: [%d, %d]",
+		len(upserts), len(groups), batch[0].GetCommitSeq(), batch[len(batch)-1].GetCommitSeq())
+
+	j.progress.SyncState = DBTablesIncrementalSync
+	j.progress.TableCommitSeqMap = make(map[int64]int64)
+	j.progress.Persist()
+
+	var (
+		wg           sync.WaitGroup
+		progressMu   sync.Mutex
+		firstErr     error
+		failedUpsert *parallelUpsert
+		concurrency  = make(chan struct{}, parallelApplyConcurrency)
+	)
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group []*parallelUpsert) {
+			defer wg.Done()
+
+			gls.ResetGls(gls.GoID(), map[interface{}]interface{}{})
+			gls.Set("job", j.Name)
+			defer gls.ResetGls(gls.GoID(), map[interface{}]interface{}{})
+
+			concurrency <- struct{}{}
+			defer func() { <-concurrency }()
+
+			for _, upsert := range group {
+				err := j.applyUpsertTxn(upsert)
+
+				progressMu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						failedUpsert = upsert
+					}
+					progressMu.Unlock()
+					return
+				}
+				for _, tableRecord := range upsert.tableRecords {
+					j.progress.TableCommitSeqMap[tableRecord.Id] = upsert.commitSeq
+				}
+				j.progress.Persist()
+				progressMu.Unlock()
+			}
+		}(group)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		// Keep the DBTablesIncrementalSync, the committed upserts are skipped in the next round.
+		// The tables to repair are resolved here, since the meta caches are not thread safe.
+		return j.newRepairableError(firstErr, failedUpsert.tableRecords)
+	}
+
+	// Advance the progress and leave the DBTablesIncrementalSync in one persist.
+	lastCommitSeq := batch[len(batch)-1].GetCommitSeq()
+	j.progress.SyncState = DBIncrementalSync
+	j.progress.TableCommitSeqMap = nil
+	j.progress.CommitSeq = lastCommitSeq
+	j.progress.LastCommitSeq = lastCommitSeq
+	j.progress.Done()
+	return nil
+}
+
+// Begin, ingest and commit a dest txn for the upsert, without the checkpoints of the progress.
+func (j *Job) applyUpsertTxn(upsert *parallelUpsert) error {
+	dest := &j.Dest
+	destRpc, err := j.factory.NewFeRpc(dest)
+	if err != nil {
+		return err
+	}
+
+	beginTxnResp, err := destRpc.BeginTransaction(dest, upsert.label, upsert.destTableIds)
+	if err != nil {
+		return err
+	}
+	if beginTxnResp.GetStatus().GetStatusCode() != tstatus.TStatusCode_OK {
+		return xerror.Errorf(xerror.Normal, "begin txn failed, commit seq: %d, status: %v",
+			upsert.commitSeq, beginTxnResp.GetStatus())
+	}
+	txnId := beginTxnResp.GetTxnId()
+	log.Infof("begin txn %d, label: %s, commit seq: %d", txnId, upsert.label, upsert.commitSeq)
+
+	rollback := func(err error) error {
+		log.Errorf("txn %d need rollback, commit seq: %d, err: %+v", txnId, upsert.commitSeq, err)
+		if resp, rollbackErr := destRpc.RollbackTransaction(dest, txnId); rollbackErr != nil {
+			log.Warnf("rollback txn %d failed, err: %+v", txnId, rollbackErr)
+		} else if resp.GetStatus().GetStatusCode() != tstatus.TStatusCode_OK {
+			log.Warnf("rollback txn %d failed, status: %v", txnId, resp.GetStatus())
+		}
+		return err
+	}
+
+	commitInfos, err := j.doIngestBinlog(txnId, upsert.tableRecords)
+	if err != nil {
+		return rollback(err)
+	}
+
+	resp, err := destRpc.CommitTransaction(dest, txnId, commitInfos)
+	if err != nil {
+		return rollback(err)
+	}
+	if statusCode := resp.GetStatus().GetStatusCode(); statusCode == tstatus.TStatusCode_PUBLISH_TIMEOUT {
+		dest.WaitTransactionDone(txnId)
+	} else if statusCode != tstatus.TStatusCode_OK {
+		return rollback(xerror.Errorf(xerror.Normal, "commit txn failed, status: %v", resp.GetStatus()))
+	}
+
+	log.Infof("commit txn %d success, commit seq: %d", txnId, upsert.commitSeq)
+	return nil
+}
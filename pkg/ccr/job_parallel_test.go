@@ -0,0 +1,217 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/rpc"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestGroupUpsertsByTables(t *testing.T) {
+	newUpsert := func(commitSeq int64, tableIds ...int64) *parallelUpsert {
+		upsert := &parallelUpsert{commitSeq: commitSeq}
+		for _, tableId := range tableIds {
+			upsert.tableRecords = append(upsert.tableRecords, &record.TableRecord{Id: tableId})
+		}
+		return upsert
+	}
+
+	// t1 and t3 are linked by the upsert 4, t2 is independent.
+	upserts := []*parallelUpsert{
+		newUpsert(1, 1),
+		newUpsert(2, 2),
+		newUpsert(3, 3),
+		newUpsert(4, 1, 3),
+		newUpsert(5, 2),
+	}
+	groups := groupUpsertsByTables(upserts)
+	if len(groups) != 2 {
+		t.Fatalf("expect 2 groups, got %d", len(groups))
+	}
+
+	commitSeqs := func(group []*parallelUpsert) []int64 {
+		seqs := make([]int64, 0, len(group))
+		for _, upsert := range group {
+			seqs = append(seqs, upsert.commitSeq)
+		}
+		return seqs
+	}
+	expects := [][]int64{{1, 3, 4}, {2, 5}}
+	for i, group := range groups {
+		seqs := commitSeqs(group)
+		if len(seqs) != len(expects[i]) {
+			t.Fatalf("group %d, expect %v, got %v", i, expects[i], seqs)
+		}
+		for k := range seqs {
+			if seqs[k] != expects[i][k] {
+				t.Errorf("group %d, expect %v, got %v", i, expects[i], seqs)
+				break
+			}
+		}
+	}
+}
+
+// droppedTablesThriftMetaFactory builds the thrift meta without any rpc, the tables in dropped
+// are skipped by the ingest binlog job.
+type droppedTablesThriftMetaFactory struct {
+	dropped map[int64]struct{}
+}
+
+func (f *droppedTablesThriftMetaFactory) NewThriftMeta(spec *base.Spec, rpcFactory rpc.IRpcFactory, tableIds []int64) (*ThriftMeta, error) {
+	return &ThriftMeta{
+		meta:              &Meta{Spec: spec, Backends: map[int64]*base.Backend{}},
+		droppedPartitions: map[int64]struct{}{},
+		droppedTables:     f.dropped,
+		droppedIndexes:    map[int64]struct{}{},
+	}, nil
+}
+
+// Apply the upserts of two tables concurrently and fail one of them, the next round must skip the
+// committed upserts. Run it with `make test-race` to check the parallel path.
+func TestCollectParallelUpsertsThrottled(t *testing.T) {
+	savedConcurrency := parallelApplyConcurrency
+	parallelApplyConcurrency = 4
+	defer func() { parallelApplyConcurrency = savedConcurrency }()
+
+	job := &Job{Name: "test_job", SyncType: DBSync}
+	job.progress = &JobProgress{SyncState: DBIncrementalSync}
+	job.Extra.Schedule = &SyncSchedule{MaxBinlogsPerMinute: 3}
+	job.initSchedule()
+
+	binlogs := make([]*festruct.TBinlog, 0, 6)
+	for i := 0; i < 6; i++ {
+		data := fmt.Sprintf(`{"commitSeq":%d,"tableRecords":{"%d":{"partitionRecords":[{"partitionId":1,"version":5}]}}}`,
+			10+i, 100+i)
+		commitSeq := int64(10 + i)
+		binlogType := festruct.TBinlogType_UPSERT
+		binlogs = append(binlogs, &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType, Data: &data})
+	}
+
+	// the upserts collected before are counted into the throttle
+	batch, _ := job.collectParallelUpserts(binlogs)
+	if len(batch) != 3 {
+		t.Fatalf("expect 3 upserts collected, got %d", len(batch))
+	}
+
+	job.recordAppliedBinlogs(batch[:1])
+	if batch, _ = job.collectParallelUpserts(binlogs); len(batch) != 2 {
+		t.Errorf("expect 2 upserts collected after 1 applied, got %d", len(batch))
+	}
+}
+
+func TestHandleUpsertsInParallelRetry(t *testing.T) {
+	savedConcurrency := parallelApplyConcurrency
+	parallelApplyConcurrency = 4
+	defer func() { parallelApplyConcurrency = savedConcurrency }()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateProgress(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	okStatus := &tstatus.TStatus{StatusCode: tstatus.TStatusCode_OK}
+	txnId := int64(1000)
+	feRpc := NewMockIFeRpc(ctrl)
+	feRpc.EXPECT().BeginTransaction(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&festruct.TBeginTxnResult_{Status: okStatus, TxnId: &txnId}, nil).AnyTimes()
+	feRpc.EXPECT().CommitTransaction(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&festruct.TCommitTxnResult_{Status: okStatus}, nil).Times(2)
+	feRpc.EXPECT().RollbackTransaction(gomock.Any(), gomock.Any()).
+		Return(&festruct.TRollbackTxnResult_{Status: okStatus}, nil).Times(1)
+	rpcFactory := NewMockIRpcFactory(ctrl)
+	rpcFactory.EXPECT().NewFeRpc(gomock.Any()).Return(feRpc, nil).AnyTimes()
+
+	// The upserts of t1 are ingested as the table is dropped, and the upsert of t2 fails
+	// with a meta error, since it has no partition records.
+	thriftMetaFactory := &droppedTablesThriftMetaFactory{dropped: map[int64]struct{}{1: {}}}
+	job := &Job{
+		Name:       "test_parallel_retry",
+		SyncType:   DBSync,
+		factory:    NewFactory(rpcFactory, nil, nil, thriftMetaFactory),
+		jobFactory: NewJobFactory(),
+	}
+	job.progress = NewJobProgress(job.Name, DBSync, db)
+	job.progress.SyncState = DBIncrementalSync
+	job.progress.CommitSeq = 9
+	job.progress.TableMapping = map[int64]int64{1: 101, 2: 102}
+	job.progress.TableNameMapping = map[int64]string{1: "t1", 2: "t2"}
+
+	newUpsert := func(commitSeq, tableId int64) (*festruct.TBinlog, *record.Upsert) {
+		binlogType := festruct.TBinlogType_UPSERT
+		binlog := &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType}
+		tableRecord := &record.TableRecord{Id: tableId}
+		if tableId == 1 {
+			tableRecord.PartitionRecords = []record.PartitionRecord{{Id: 11, Range: "p1"}}
+		}
+		upsert := &record.Upsert{
+			CommitSeq:    commitSeq,
+			Label:        "label",
+			TableRecords: map[int64]*record.TableRecord{tableId: tableRecord},
+		}
+		return binlog, upsert
+	}
+	binlogs := make([]*festruct.TBinlog, 0)
+	upserts := make([]*record.Upsert, 0)
+	for _, item := range [][2]int64{{10, 1}, {11, 2}, {12, 1}} {
+		binlog, upsert := newUpsert(item[0], item[1])
+		binlogs = append(binlogs, binlog)
+		upserts = append(upserts, upsert)
+	}
+
+	err := job.handleUpsertsInParallel(binlogs, upserts)
+	var repairErr *repairableError
+	if !errors.As(err, &repairErr) {
+		t.Fatalf("expect a repairable error, got %v", err)
+	}
+	if len(repairErr.targets) != 1 || repairErr.targets[0].Table != "t2" {
+		t.Errorf("expect to repair t2, got %+v", repairErr.targets)
+	}
+
+	// The progress keeps the committed tables, and the commit seq is not advanced.
+	progress := job.progress
+	if progress.SyncState != DBTablesIncrementalSync {
+		t.Errorf("expect sync state %s, got %s", DBTablesIncrementalSync, progress.SyncState)
+	}
+	if len(progress.TableCommitSeqMap) != 1 || progress.TableCommitSeqMap[1] != 12 {
+		t.Errorf("expect table commit seq map {1: 12}, got %v", progress.TableCommitSeqMap)
+	}
+	if progress.CommitSeq != 9 {
+		t.Errorf("expect commit seq 9, got %d", progress.CommitSeq)
+	}
+
+	// The next round is applied serially, and the committed upserts of t1 are skipped.
+	if batch, _ := job.collectParallelUpserts(binlogs); batch != nil {
+		t.Errorf("expect no parallel batch in the retry, got %d binlogs", len(batch))
+	}
+	for i, expect := range []int{0, 1, 0} {
+		if records := job.getDbSyncTableRecords(upserts[i]); len(records) != expect {
+			t.Errorf("commit seq %d, expect %d table records, got %d", upserts[i].CommitSeq, expect, len(records))
+		}
+	}
+}
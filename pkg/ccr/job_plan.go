@@ -0,0 +1,296 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The actions of the dest tables in the fullsync of a new job.
+const (
+	// The dest table doesn't exist, it is created by the restore.
+	PlanActionRestore = "restore"
+	// The dest table exists with the same schema, its data is overwritten by the restore.
+	PlanActionReplace = "replace"
+	// The dest table exists but its schema is not matched, the signature check of the restore
+	// fails, so it is restored under an alias and replaced, or dropped and restored again.
+	PlanActionRebuild = "rebuild"
+	// The dest table exists, the job can't be created without allow_table_exists.
+	PlanActionConflict = "conflict"
+	// The dest table is not in the src database, it is dropped by the restore.
+	PlanActionDrop = "drop"
+	// The dest table is not in the src database, it is archived before the restore, see SoftDelete.
+	PlanActionArchive = "archive"
+)
+
+type TablePlan struct {
+	SrcTable  string `json:"src_table,omitempty"`
+	DestTable string `json:"dest_table"`
+	Action    string `json:"action"`
+	// The schema differences of the rebuilt table.
+	SchemaDiffs []string `json:"schema_diffs,omitempty"`
+}
+
+// JobPlan is what a job would do if it were created, without creating anything.
+type JobPlan struct {
+	Name     string `json:"name"`
+	SyncType string `json:"sync_type"`
+
+	// The checks failed, the job could not be created or run.
+	Errors []string `json:"errors,omitempty"`
+
+	CreateDestDatabase       bool         `json:"create_dest_database"`
+	AllowTableExistsRequired bool         `json:"allow_table_exists_required"`
+	Tables                   []*TablePlan `json:"tables"`
+
+	// The plans of the extra destinations.
+	Dests []*JobPlan `json:"dests,omitempty"`
+}
+
+func (p *JobPlan) addError(format string, args ...interface{}) {
+	p.Errors = append(p.Errors, fmt.Sprintf(format, args...))
+}
+
+// The job could be created if there is no errors and no conflicts.
+func (p *JobPlan) IsCreatable() bool {
+	if len(p.Errors) > 0 || p.AllowTableExistsRequired {
+		return false
+	}
+	for _, dest := range p.Dests {
+		if !dest.IsCreatable() {
+			return false
+		}
+	}
+	return true
+}
+
+// Plan does the checks of FirstRun and the fullsync, without changing anything of the clusters.
+func (j *Job) Plan() (*JobPlan, error) {
+	log.Infof("plan job, name: %s, src: %s, dest: %s", j.Name, &j.Src, &j.Dest)
+
+	plan := &JobPlan{
+		Name:     j.Name,
+		SyncType: j.SyncType.String(),
+		Tables:   make([]*TablePlan, 0),
+	}
+
+	if err := j.updateFrontends(); err != nil {
+		return nil, err
+	}
+
+	// check fe and be binlog feature is enabled
+	if err := j.srcMeta.CheckBinlogFeature(); err != nil {
+		plan.addError("src binlog feature: %s", err.Error())
+	}
+	if err := j.destMeta.CheckBinlogFeature(); err != nil {
+		plan.addError("dest binlog feature: %s", err.Error())
+	}
+
+	srcTables, err := j.planSrcTables(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := j.planDestTables(plan, srcTables); err != nil {
+		return nil, err
+	}
+
+	for _, fanout := range j.fanouts {
+		destPlan, err := fanout.Plan()
+		if err != nil {
+			return nil, err
+		}
+		plan.Dests = append(plan.Dests, destPlan)
+	}
+	return plan, nil
+}
+
+// Check the src database and tables, returns the src tables to sync.
+func (j *Job) planSrcTables(plan *JobPlan) ([]string, error) {
+	if exists, err := j.ISrc.CheckDatabaseExists(); err != nil {
+		return nil, err
+	} else if !exists {
+		plan.addError("src database %s not exists", j.Src.Database)
+		return nil, nil
+	}
+
+	srcDbBinlogEnabled := false
+	if j.SyncType == DBSync {
+		if enable, err := j.ISrc.IsDatabaseEnableBinlog(); err != nil {
+			return nil, err
+		} else if !enable && !j.isMultiTableSync() {
+			plan.addError("src database %s not enable binlog", j.Src.Database)
+		} else {
+			srcDbBinlogEnabled = enable
+		}
+	}
+
+	var tables []string
+	switch {
+	case j.SyncType == TableSync:
+		tables = []string{j.Src.Table}
+	case j.isMultiTableSync():
+		tables = j.Extra.Tables
+	default:
+		tableMetas, err := j.srcMeta.GetTables()
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tableMetas {
+			if table.Type != record.TableTypeOlap && table.Type != record.TableTypeView {
+				continue
+			}
+			if j.isTableSelected(table.Name) {
+				tables = append(tables, table.Name)
+			}
+		}
+		sort.Strings(tables)
+		return tables, nil
+	}
+
+	// Only check the explicit tables, as FirstRun does.
+	existTables := make([]string, 0, len(tables))
+	for _, table := range tables {
+		if exists, err := j.ISrc.CheckTableExistsByName(table); err != nil {
+			return nil, err
+		} else if !exists {
+			plan.addError("src table %s.%s not exists", j.Src.Database, table)
+			continue
+		}
+
+		tableSpec := j.Src
+		tableSpec.Table = table
+		tableSpecer := j.factory.NewSpecer(&tableSpec)
+		if invalidProperty, err := tableSpecer.CheckTablePropertyValid(); err != nil {
+			return nil, err
+		} else if len(invalidProperty) != 0 {
+			plan.addError("src table %s.%s only support property: %s", j.Src.Database, table, strings.Join(invalidProperty, ", "))
+		}
+		if j.isMultiTableSync() && !srcDbBinlogEnabled {
+			if properties, err := tableSpecer.GetBinlogProperties(); err != nil {
+				return nil, err
+			} else if !properties.Enable {
+				plan.addError("src table %s.%s not enable binlog", j.Src.Database, table)
+			}
+		}
+		existTables = append(existTables, table)
+	}
+	return existTables, nil
+}
+
+// Compare the src tables with the dest tables.
+func (j *Job) planDestTables(plan *JobPlan, srcTables []string) error {
+	destDbExists, err := j.IDest.CheckDatabaseExists()
+	if err != nil {
+		return err
+	}
+	if !destDbExists {
+		plan.CreateDestDatabase = true
+		for _, table := range srcTables {
+			plan.Tables = append(plan.Tables, &TablePlan{
+				SrcTable:  table,
+				DestTable: j.planDestTableName(table),
+				Action:    PlanActionRestore,
+			})
+		}
+		return nil
+	}
+
+	// FirstRun rejects the exists dest tables of the table sync and multi-table sync.
+	checkConflict := (j.SyncType == TableSync || j.isMultiTableSync()) && !j.Extra.allowTableExists
+	destTables := make(map[string]struct{})
+	for _, table := range srcTables {
+		destTable := j.planDestTableName(table)
+		destTables[destTable] = struct{}{}
+
+		tablePlan := &TablePlan{
+			SrcTable:  table,
+			DestTable: destTable,
+			Action:    PlanActionRestore,
+		}
+		if exists, err := j.IDest.CheckTableExistsByName(destTable); err != nil {
+			return err
+		} else if exists && checkConflict {
+			tablePlan.Action = PlanActionConflict
+			plan.AllowTableExistsRequired = true
+		} else if exists {
+			diffs, err := j.planSchemaDiffs(table, destTable)
+			if err != nil {
+				return err
+			}
+			tablePlan.Action = PlanActionReplace
+			if len(diffs) > 0 {
+				tablePlan.Action = PlanActionRebuild
+				tablePlan.SchemaDiffs = diffs
+			}
+		}
+		plan.Tables = append(plan.Tables, tablePlan)
+	}
+
+	// The same condition as the CleanTables of the fullsync restore.
+	if featureCleanTableAndPartitions && j.SyncType == DBSync && !j.hasTableSelector() && !j.isDBSyncWithRename() {
+		allDestTables, err := j.IDest.GetAllTables()
+		if err != nil {
+			return err
+		}
+		sort.Strings(allDestTables)
+		for _, table := range allDestTables {
+			if _, ok := destTables[table]; ok {
+				continue
+			}
+			action := PlanActionDrop
+			if j.isSoftDelete() {
+				if strings.HasPrefix(table, ArchiveTablePrefix) {
+					continue
+				}
+				action = PlanActionArchive
+			}
+			plan.Tables = append(plan.Tables, &TablePlan{
+				DestTable: table,
+				Action:    action,
+			})
+		}
+	}
+	return nil
+}
+
+// Compare the schemas of the src table and the exists dest table, as the signature check of
+// the restore does.
+func (j *Job) planSchemaDiffs(srcTable, destTable string) ([]string, error) {
+	srcSql, err := j.ISrc.ShowCreateTable(srcTable)
+	if err != nil {
+		return nil, err
+	}
+	destSql, err := j.IDest.ShowCreateTable(destTable)
+	if err != nil {
+		return nil, err
+	}
+	return diffTableSchema(parseTableSchema(srcSql), parseTableSchema(destSql)), nil
+}
+
+func (j *Job) planDestTableName(srcTable string) string {
+	if j.SyncType == TableSync {
+		return j.Dest.Table
+	}
+	return j.destTableName(srcTable)
+}
@@ -0,0 +1,141 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+// planSpecer keeps the create table sqls of the databases.
+type planSpecer struct {
+	base.Specer
+	databases map[string]map[string]string
+	database  string
+}
+
+func (s *planSpecer) Valid() error {
+	return nil
+}
+
+func (s *planSpecer) CheckDatabaseExists() (bool, error) {
+	_, ok := s.databases[s.database]
+	return ok, nil
+}
+
+func (s *planSpecer) CheckTableExistsByName(tableName string) (bool, error) {
+	_, ok := s.databases[s.database][tableName]
+	return ok, nil
+}
+
+func (s *planSpecer) ShowCreateTable(tableName string) (string, error) {
+	return s.databases[s.database][tableName], nil
+}
+
+func (s *planSpecer) NewSpecer(spec *base.Spec) base.Specer {
+	return &planSpecer{databases: s.databases, database: spec.Database}
+}
+
+func TestPlanDestTables(t *testing.T) {
+	createTable := func(columns string) string {
+		return "CREATE TABLE `t` (\n" + columns + "\n) ENGINE=OLAP\nDUPLICATE KEY(`id`)\nDISTRIBUTED BY HASH(`id`) BUCKETS 1"
+	}
+	specer := &planSpecer{databases: map[string]map[string]string{
+		"src_db": {
+			"orders": createTable("`id` int NULL"),
+			"users":  createTable("`id` int NULL,\n`name` varchar(64) NULL"),
+			"items":  createTable("`id` int NULL"),
+		},
+		"dest_db": {
+			"orders": createTable("`id` int NULL"),
+			"users":  createTable("`id` int NULL"),
+		},
+	}}
+	job := &Job{
+		Name:     "test_job",
+		SyncType: DBSync,
+		Src:      base.Spec{Database: "src_db"},
+		Dest:     base.Spec{Database: "dest_db"},
+	}
+	job.ISrc = specer.NewSpecer(&job.Src)
+	job.IDest = specer.NewSpecer(&job.Dest)
+
+	plan := &JobPlan{}
+	if err := job.planDestTables(plan, []string{"items", "orders", "users"}); err != nil {
+		t.Fatalf("plan dest tables failed, err: %v", err)
+	}
+	expect := []*TablePlan{
+		{SrcTable: "items", DestTable: "items", Action: PlanActionRestore},
+		{SrcTable: "orders", DestTable: "orders", Action: PlanActionReplace},
+		{SrcTable: "users", DestTable: "users", Action: PlanActionRebuild,
+			SchemaDiffs: []string{"column name not found in dest"}},
+	}
+	if !reflect.DeepEqual(plan.Tables, expect) {
+		for _, table := range plan.Tables {
+			t.Logf("table plan: %+v", table)
+		}
+		t.Fatalf("unexpected table plans")
+	}
+}
+
+func TestPlanJobDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().IsJobExist(gomock.Any()).Return(false, nil).AnyTimes()
+	metaFactory := NewMockMetaerFactory(ctrl)
+	metaFactory.EXPECT().NewMeta(gomock.Any()).Return(nil).AnyTimes()
+
+	newJob := func(dryRun bool) *Job {
+		job, err := NewJobFromService("test_job", &JobContext{
+			Context:  context.Background(),
+			Src:      base.Spec{Database: "src_db"},
+			Dest:     base.Spec{Database: "dest_db"},
+			Dests:    []base.Spec{{Database: "dest_db_1"}},
+			FileSink: &FileSink{Dir: t.TempDir()},
+			Db:       db,
+			Factory:  NewFactory(nil, metaFactory, &planSpecer{}, nil),
+			DryRun:   dryRun,
+		})
+		if err != nil {
+			t.Fatalf("new job failed, err: %v", err)
+		}
+		return job
+	}
+
+	job := newJob(false)
+	defer job.closeSinks()
+	if len(job.sinks) != 1 {
+		t.Fatalf("the job should open the file sink, sinks: %d", len(job.sinks))
+	}
+
+	// the plan job doesn't open the sinks, and its fan-outs are planned too
+	job = newJob(true)
+	if len(job.sinks) != 0 || job.throttle != nil {
+		t.Fatalf("the plan job should not open the sinks or init the schedule")
+	}
+	if len(job.fanouts) != 1 || len(job.fanouts[0].sinks) != 0 {
+		t.Fatalf("the plan job should build the fan-outs without sinks, fan-outs: %d", len(job.fanouts))
+	}
+}
@@ -19,6 +19,7 @@ package ccr
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/selectdb/ccr_syncer/pkg/storage"
@@ -162,6 +163,12 @@ type JobProgress struct {
 	JobName string     `json:"job_name"`
 	db      storage.DB `json:"-"`
 
+	// The progress of the job which owns the progress row, only for the fan-out jobs, see DestProgresses.
+	parent    *JobProgress `json:"-"`
+	destIndex int          `json:"-"`
+	// Guard the DestProgresses and the writing of the progress row.
+	destLock *sync.Mutex `json:"-"`
+
 	// Table/DB big sync state machine states
 	SyncState SyncState `json:"sync_state"`
 	// Sub sync state machine states
@@ -188,6 +195,19 @@ type JobProgress struct {
 	// The shadow indexes of the pending schema changes
 	ShadowIndexes map[int64]int64 `json:"shadow_index_map,omitempty"`
 
+	// The dropped tables and partitions archived in the dest database, see SoftDelete.
+	ArchivedTables []*ArchivedTable `json:"archived_tables,omitempty"`
+
+	// The last commit seq delivered to each sink of the job.
+	SinkOffsets map[string]int64 `json:"sink_offsets,omitempty"`
+
+	// The progress of the fan-out jobs, keyed by the index of the extra destination. They are saved
+	// in the progress row of the job, so the progress of all destinations are removed together.
+	DestProgresses map[int]json.RawMessage `json:"dest_progresses,omitempty"`
+
+	// The unix time in milliseconds of the recent automatic full syncs, see FullSyncPolicy.
+	FullSyncAt []int64 `json:"full_sync_at,omitempty"`
+
 	// Some fields to save the unix epoch time of the key timepoint.
 	CreatedAt              int64        `json:"created_at,omitempty"`
 	FullSyncStartAt        int64        `json:"full_sync_start_at,omitempty"`
@@ -217,8 +237,9 @@ func NewJobProgress(jobName string, syncType SyncType, db storage.DB) *JobProgre
 		syncState = TableFullSync
 	}
 	return &JobProgress{
-		JobName: jobName,
-		db:      db,
+		JobName:  jobName,
+		db:       db,
+		destLock: &sync.Mutex{},
 
 		SyncId:       time.Now().Unix(),
 		SyncState:    syncState,
@@ -263,10 +284,40 @@ func NewJobProgressFromJson(jobName string, db storage.DB) (*JobProgress, error)
 	} else {
 		jobProgress.InMemoryData = nil
 		jobProgress.db = db
+		jobProgress.destLock = &sync.Mutex{}
 		return &jobProgress, nil
 	}
 }
 
+// Create the progress of the fan-out job syncing to the index-th extra destination.
+func (j *JobProgress) NewDestProgress(jobName string, index int, syncType SyncType) *JobProgress {
+	progress := NewJobProgress(jobName, syncType, j.db)
+	progress.parent = j
+	progress.destIndex = index
+	return progress
+}
+
+// Recover the progress of the fan-out job syncing to the index-th extra destination, returns
+// nil if the fan-out job has not saved its progress.
+func (j *JobProgress) RecoverDestProgress(index int) (*JobProgress, error) {
+	unlock := j.lockDests()
+	data, ok := j.DestProgresses[index]
+	unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	var progress JobProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "unmarshal the progress of dest %d failed", index+1)
+	}
+	progress.db = j.db
+	progress.destLock = &sync.Mutex{}
+	progress.parent = j
+	progress.destIndex = index
+	return &progress, nil
+}
+
 // GetTableId get table id by table name from TableNameMapping
 func (j *JobProgress) GetTableId(tableName string) (int64, bool) {
 	for tableId, table := range j.TableNameMapping {
@@ -396,18 +447,7 @@ func (j *JobProgress) Persist() {
 		j.SyncState, j.SubSyncState, j.CommitSeq, j.PrevCommitSeq)
 
 	for {
-		// Step 1: to json
-		// TODO: fix to json error
-		jsonBytes, err := json.Marshal(j)
-		if err != nil {
-			log.Errorf("parse job progress failed, error: %+v", err)
-			time.Sleep(UPDATE_JOB_PROGRESS_DURATION)
-			continue
-		}
-
-		// Step 2: write to db
-		err = j.db.UpdateProgress(j.JobName, string(jsonBytes))
-		if err != nil {
+		if err := j.persist(); err != nil {
 			log.Errorf("update job progress failed, error: %+v", err)
 			time.Sleep(UPDATE_JOB_PROGRESS_DURATION)
 			continue
@@ -420,6 +460,79 @@ func (j *JobProgress) Persist() {
 		j.SyncState, j.SubSyncState, j.CommitSeq, j.PrevCommitSeq)
 }
 
+// Lock the DestProgresses, the progress created without the constructors has no fan-out jobs.
+func (j *JobProgress) lockDests() func() {
+	if j.destLock == nil {
+		return func() {}
+	}
+	j.destLock.Lock()
+	return j.destLock.Unlock
+}
+
+func (j *JobProgress) persist() error {
+	if j.parent != nil {
+		return j.parent.persistDestProgress(j)
+	}
+
+	defer j.lockDests()()
+
+	// Step 1: to json
+	jsonBytes, err := json.Marshal(j)
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "marshal job progress failed")
+	}
+
+	// Step 2: write to db
+	return j.db.UpdateProgress(j.JobName, string(jsonBytes))
+}
+
+// Save the progress of the fan-out job into the progress row. Only the dest_progresses of the
+// row is updated, so it don't touch the fields owned by the job itself.
+func (j *JobProgress) persistDestProgress(progress *JobProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "marshal the progress of dest %d failed", progress.destIndex+1)
+	}
+
+	defer j.lockDests()()
+
+	if exist, err := j.db.IsProgressExist(j.JobName); err != nil {
+		return err
+	} else if !exist {
+		// The job is removed, don't create the progress row again.
+		log.Warnf("the progress of job %s is removed, skip saving the progress of dest %d",
+			j.JobName, progress.destIndex+1)
+		return nil
+	}
+	rowData, err := j.db.GetProgress(j.JobName)
+	if err != nil {
+		return err
+	}
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(rowData), &row); err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "unmarshal the progress of job %s failed", j.JobName)
+	}
+
+	destProgresses := make(map[int]json.RawMessage, len(j.DestProgresses)+1)
+	for index, data := range j.DestProgresses {
+		destProgresses[index] = data
+	}
+	destProgresses[progress.destIndex] = data
+	if row["dest_progresses"], err = json.Marshal(destProgresses); err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "marshal the progress of the dests failed")
+	}
+	newRowData, err := json.Marshal(row)
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "marshal the progress of job %s failed", j.JobName)
+	}
+	if err := j.db.UpdateProgress(j.JobName, string(newRowData)); err != nil {
+		return err
+	}
+
+	j.DestProgresses = destProgresses
+	return nil
+}
+
 func (j *JobProgress) SetFullSyncInfo(info string) {
 	j.FullSyncInfo.Info = info
 	j.FullSyncInfo.CommitSeq = j.CommitSeq
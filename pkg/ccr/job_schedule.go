@@ -0,0 +1,243 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/rpc"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const syncThrottlePeriod = time.Minute
+
+// SyncWindow is a daily time range in the local time of the syncer, in the format "HH:MM".
+// The window crosses midnight if the End is before the Start, eg. 22:00 - 06:00.
+type SyncWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SyncSchedule limits when and how fast a job applies the binlogs to the dest cluster.
+type SyncSchedule struct {
+	// Only apply binlogs in these windows, empty means all the time.
+	Windows []SyncWindow `json:"windows,omitempty"`
+
+	// The max number of the binlogs applied per minute, zero means no limit.
+	MaxBinlogsPerMinute int64 `json:"max_binlogs_per_minute,omitempty"`
+	// The max bytes of the data ingested per minute, zero means no limit. The bytes are the
+	// size of the src rowsets of the upserts, counted after they are ingested, so a minute
+	// might exceed the limit by the last txn.
+	MaxBytesPerMinute int64 `json:"max_bytes_per_minute,omitempty"`
+
+	// The max concurrency of the binlog ingesting of the job, across all backends.
+	// Zero means no limit except the max_ingest_concurrency_per_backend.
+	MaxIngestConcurrency int64 `json:"max_ingest_concurrency,omitempty"`
+}
+
+// parse "HH:MM" to the minutes since midnight.
+func parseClock(clock string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute); err != nil {
+		return 0, xerror.Wrapf(err, xerror.Normal, "invalid time %s, the format is HH:MM", clock)
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, xerror.Errorf(xerror.Normal, "invalid time %s, the format is HH:MM", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w *SyncWindow) Contains(now time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	current := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return start <= current && current < end
+	}
+	// cross midnight
+	return current >= start || current < end
+}
+
+func (s *SyncSchedule) IsEmpty() bool {
+	return s == nil || (len(s.Windows) == 0 && s.MaxBinlogsPerMinute == 0 &&
+		s.MaxBytesPerMinute == 0 && s.MaxIngestConcurrency == 0)
+}
+
+func (s *SyncSchedule) Valid() error {
+	if s.IsEmpty() {
+		return nil
+	}
+
+	for _, window := range s.Windows {
+		if _, err := parseClock(window.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(window.End); err != nil {
+			return err
+		}
+		if window.Start == window.End {
+			return xerror.Errorf(xerror.Normal, "the sync window %s - %s is empty", window.Start, window.End)
+		}
+	}
+
+	if s.MaxBinlogsPerMinute < 0 || s.MaxBytesPerMinute < 0 || s.MaxIngestConcurrency < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid sync schedule limits, binlogs: %d, bytes: %d, ingest concurrency: %d",
+			s.MaxBinlogsPerMinute, s.MaxBytesPerMinute, s.MaxIngestConcurrency)
+	}
+	return nil
+}
+
+// InWindow returns whether the binlogs could be applied at the time.
+func (s *SyncSchedule) InWindow(now time.Time) bool {
+	if s == nil || len(s.Windows) == 0 {
+		return true
+	}
+
+	for i := range s.Windows {
+		if s.Windows[i].Contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the data size of the ingested upserts is counted.
+func (s *SyncSchedule) hasBytesLimit() bool {
+	return s != nil && s.MaxBytesPerMinute > 0
+}
+
+// syncThrottle counts the binlogs applied and the bytes ingested in the current period.
+type syncThrottle struct {
+	periodStart time.Time
+	binlogs     int64
+	bytes       int64
+}
+
+// Allow returns whether one more binlog could be applied in the current period, the pending
+// binlogs are collected to apply together but not recorded yet.
+func (t *syncThrottle) Allow(schedule *SyncSchedule, now time.Time, pending int64) bool {
+	if schedule == nil || (schedule.MaxBinlogsPerMinute == 0 && schedule.MaxBytesPerMinute == 0) {
+		return true
+	}
+
+	if now.Sub(t.periodStart) >= syncThrottlePeriod {
+		t.periodStart = now
+		t.binlogs = 0
+		t.bytes = 0
+	}
+
+	if schedule.MaxBinlogsPerMinute > 0 && t.binlogs+pending >= schedule.MaxBinlogsPerMinute {
+		return false
+	}
+	if schedule.MaxBytesPerMinute > 0 && t.bytes >= schedule.MaxBytesPerMinute {
+		return false
+	}
+	return true
+}
+
+func (t *syncThrottle) Record(binlogs, bytes int64) {
+	t.binlogs += binlogs
+	t.bytes += bytes
+}
+
+func ingestConcurrencyLimit(schedule *SyncSchedule) int64 {
+	if schedule == nil || schedule.MaxIngestConcurrency <= 0 {
+		return math.MaxInt64
+	}
+	return schedule.MaxIngestConcurrency
+}
+
+func (j *Job) initSchedule() {
+	j.throttle = &syncThrottle{}
+	j.ingestWindow = rpc.NewConcurrencyWindow(0, ingestConcurrencyLimit(j.Extra.Schedule))
+}
+
+// Whether the binlog could be applied now, according to the sync windows and the throttle.
+// The pending is the number of the binlogs collected before it to apply in the same round.
+func (j *Job) allowApplyBinlog(binlog *festruct.TBinlog, pending int) bool {
+	schedule := j.Extra.Schedule
+	if schedule.IsEmpty() {
+		return true
+	}
+
+	now := time.Now()
+	if !schedule.InWindow(now) {
+		log.Tracef("job %s is out of the sync windows, commit seq: %d", j.Name, binlog.GetCommitSeq())
+		return false
+	}
+	if !j.throttle.Allow(schedule, now, int64(pending)) {
+		log.Debugf("job %s is throttled, applied %d binlogs and %d bytes since %s",
+			j.Name, j.throttle.binlogs, j.throttle.bytes, j.throttle.periodStart.Format(time.TimeOnly))
+		return false
+	}
+	return true
+}
+
+// Charge the applied binlogs and the bytes ingested by them to the throttle.
+func (j *Job) recordAppliedBinlogs(binlogs []*festruct.TBinlog) {
+	bytes := j.ingestedBytes.Swap(0)
+	if !j.Extra.Schedule.IsEmpty() {
+		j.throttle.Record(int64(len(binlogs)), bytes)
+	}
+}
+
+// Acquire a slot of the ingesting concurrency of the job, and returns the release function.
+func (j *Job) acquireIngestSlot() func() {
+	j.ingestWindow.Acquire()
+	return j.ingestWindow.Release
+}
+
+func (j *Job) UpdateSchedule(schedule *SyncSchedule) error {
+	if err := schedule.Valid(); err != nil {
+		return err
+	}
+	if schedule.IsEmpty() {
+		schedule = nil
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	savedSchedule := j.Extra.Schedule
+	j.Extra.Schedule = schedule
+	if err := j.persistJob(); err != nil {
+		j.Extra.Schedule = savedSchedule
+		return err
+	}
+	j.ingestWindow.SetLimit(ingestConcurrencyLimit(schedule))
+	log.Infof("update sync schedule of job %s: %+v", j.Name, schedule)
+
+	for _, fanout := range j.fanouts {
+		if err := fanout.UpdateSchedule(schedule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
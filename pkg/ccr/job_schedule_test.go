@@ -0,0 +1,172 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+)
+
+func TestSyncThrottle(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	type step struct {
+		after   time.Duration
+		binlogs int64
+		bytes   int64
+		allow   bool
+	}
+	tests := []struct {
+		name     string
+		schedule *SyncSchedule
+		steps    []step
+	}{
+		{
+			name:     "no limit",
+			schedule: &SyncSchedule{},
+			steps: []step{
+				{after: 0, binlogs: 1000, bytes: 1 << 30, allow: true},
+				{after: time.Second, allow: true},
+			},
+		},
+		{
+			name:     "binlogs",
+			schedule: &SyncSchedule{MaxBinlogsPerMinute: 10},
+			steps: []step{
+				{after: 0, binlogs: 9, allow: true},
+				{after: time.Second, binlogs: 1, allow: true},
+				{after: 2 * time.Second, allow: false},
+				{after: 59 * time.Second, allow: false},
+				// a new period starts
+				{after: time.Minute, binlogs: 10, allow: true},
+				{after: time.Minute + time.Second, allow: false},
+				{after: 2 * time.Minute, allow: true},
+			},
+		},
+		{
+			name:     "bytes",
+			schedule: &SyncSchedule{MaxBytesPerMinute: 1024},
+			steps: []step{
+				{after: 0, binlogs: 1, bytes: 512, allow: true},
+				{after: time.Second, binlogs: 1, bytes: 2048, allow: true},
+				{after: 2 * time.Second, allow: false},
+				{after: time.Minute, binlogs: 100, allow: true},
+			},
+		},
+		{
+			name:     "binlogs and bytes",
+			schedule: &SyncSchedule{MaxBinlogsPerMinute: 10, MaxBytesPerMinute: 1024},
+			steps: []step{
+				{after: 0, binlogs: 5, bytes: 1024, allow: true},
+				{after: time.Second, allow: false},
+				{after: time.Minute, binlogs: 10, allow: true},
+				{after: time.Minute + time.Second, allow: false},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			throttle := &syncThrottle{}
+			for i, step := range test.steps {
+				if allow := throttle.Allow(test.schedule, start.Add(step.after), 0); allow != step.allow {
+					t.Fatalf("step %d: expect allow %v, got %v", i, step.allow, allow)
+				}
+				throttle.Record(step.binlogs, step.bytes)
+			}
+		})
+	}
+}
+
+func TestAllowApplyBinlogOutOfWindow(t *testing.T) {
+	// a window of one hour which starts one hour later
+	now := time.Now()
+	window := SyncWindow{
+		Start: now.Add(time.Hour).Format("15:04"),
+		End:   now.Add(2 * time.Hour).Format("15:04"),
+	}
+	job := &Job{Name: "test_job"}
+	job.Extra.Schedule = &SyncSchedule{Windows: []SyncWindow{window}}
+	job.initSchedule()
+
+	commitSeq := int64(10)
+	binlog := &festruct.TBinlog{CommitSeq: &commitSeq}
+	if job.allowApplyBinlog(binlog, 0) {
+		t.Errorf("expect the binlog is not applied out of the window %v", window)
+	}
+
+	job.Extra.Schedule.Windows = nil
+	if !job.allowApplyBinlog(binlog, 0) {
+		t.Errorf("expect the binlog is applied without windows")
+	}
+}
+
+func TestGetBinlogDataSize(t *testing.T) {
+	segmentSizes := []int{100, 200, 300}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("tablet_id") != "1001" || query.Get("binlog_version") != "5" {
+			http.Error(w, "unknown binlog", http.StatusNotFound)
+			return
+		}
+		switch query.Get("type") {
+		case "get_binlog_info":
+			fmt.Fprintf(w, "rowset_1:%d", len(segmentSizes))
+		case "get_segment_file":
+			index, err := strconv.Atoi(query.Get("segment_index"))
+			if err != nil || index >= len(segmentSizes) || query.Get("rowset_id") != "rowset_1" {
+				http.Error(w, "unknown segment", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(segmentSizes[index]))
+		default:
+			http.Error(w, "unknown type", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	serverUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse server url failed: %v", err)
+	}
+	port, err := strconv.ParseUint(serverUrl.Port(), 10, 16)
+	if err != nil {
+		t.Fatalf("parse server port failed: %v", err)
+	}
+	backend := &base.Backend{Host: serverUrl.Hostname(), HttpPort: uint16(port)}
+
+	size, err := getBinlogDataSize(backend, 1001, 5)
+	if err != nil {
+		t.Fatalf("get binlog data size failed: %v", err)
+	}
+	if size != 600 {
+		t.Errorf("expect the data size 600, got %d", size)
+	}
+
+	if _, err := getBinlogDataSize(backend, 1002, 5); err == nil {
+		t.Errorf("expect error for the unknown binlog")
+	}
+}
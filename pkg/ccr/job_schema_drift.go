@@ -0,0 +1,341 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/xmetrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var schemaDriftCheckInterval time.Duration
+
+func init() {
+	flag.DurationVar(&schemaDriftCheckInterval, "schema_drift_check_interval", 30*time.Minute,
+		"the interval to compare the schemas of the src and dest tables, zero to disable")
+}
+
+// SchemaDrift is the result of the last schema drift check of a job and its fan-out dests.
+type SchemaDrift struct {
+	// The unix time in milliseconds of the check.
+	CheckedAt int64               `json:"checked_at"`
+	Tables    []*TableSchemaDrift `json:"tables,omitempty"`
+}
+
+// The differences between the schemas of a src table and its dest table.
+type TableSchemaDrift struct {
+	// The fan-out job name of the dest, empty for the first dest.
+	Dest      string   `json:"dest,omitempty"`
+	SrcTable  string   `json:"src_table"`
+	DestTable string   `json:"dest_table"`
+	Diffs     []string `json:"diffs"`
+}
+
+// The parsed result of SHOW CREATE TABLE, the table name and comment are not included.
+type tableSchema struct {
+	Columns      map[string]string
+	ColumnOrder  []string
+	Indexes      map[string]string
+	Keys         string
+	PartitionBy  string
+	Partitions   map[string]string
+	Distribution string
+	Properties   map[string]string
+}
+
+var (
+	schemaIndexRegex     = regexp.MustCompile("^INDEX\\s+`?([^`\\s]+)`?\\s*(.*)$")
+	schemaPartitionRegex = regexp.MustCompile("^\\(?PARTITION\\s+`?([^`\\s]+)`?\\s+(VALUES.*)$")
+	schemaPropertyRegex  = regexp.MustCompile(`^"([^"]+)"\s*=\s*"(.*)"$`)
+)
+
+// Whether the property is rewritten by the syncer, so it is different between src and dest.
+func isRewrittenProperty(prop string) bool {
+	if base.IsUnsupportedProperty(prop) || strings.HasPrefix(prop, "binlog.") {
+		return true
+	}
+	// see FilterStorageMediumFromCreateTableSql
+	return featureFilterStorageMedium && (prop == "storage_medium" || prop == "storage_cooldown_time")
+}
+
+// Remove the closing parenthesis of the partition list, the range [a, b) is closed by ")".
+func trimPartitionDefinition(definition string) string {
+	definition = strings.TrimSuffix(strings.TrimSpace(definition), ",")
+	for strings.HasSuffix(definition, ")") &&
+		strings.Count(definition, ")")+strings.Count(definition, "]") >
+			strings.Count(definition, "(")+strings.Count(definition, "[") {
+		definition = strings.TrimSuffix(definition, ")")
+	}
+	return definition
+}
+
+func parseTableSchema(createSql string) *tableSchema {
+	schema := &tableSchema{
+		Columns:    make(map[string]string),
+		Indexes:    make(map[string]string),
+		Partitions: make(map[string]string),
+		Properties: make(map[string]string),
+	}
+
+	inColumns := false
+	inProperties := false
+	for _, line := range strings.Split(createSql, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "CREATE TABLE"):
+			inColumns = true
+		case inColumns && strings.HasPrefix(line, ")"):
+			inColumns = false
+		case inColumns && strings.HasPrefix(line, "`"):
+			end := strings.Index(line[1:], "`")
+			if end < 0 {
+				continue
+			}
+			name := line[1 : end+1]
+			schema.Columns[name] = strings.TrimSuffix(strings.TrimSpace(line[end+2:]), ",")
+			schema.ColumnOrder = append(schema.ColumnOrder, name)
+		case inColumns:
+			if match := schemaIndexRegex.FindStringSubmatch(line); match != nil {
+				schema.Indexes[match[1]] = strings.TrimSuffix(strings.TrimSpace(match[2]), ",")
+			}
+		case strings.HasPrefix(line, "PROPERTIES"):
+			inProperties = true
+		case inProperties:
+			if match := schemaPropertyRegex.FindStringSubmatch(strings.TrimSuffix(line, ",")); match != nil {
+				if !isRewrittenProperty(match[1]) {
+					schema.Properties[match[1]] = match[2]
+				}
+			}
+		case strings.HasPrefix(line, "DUPLICATE KEY"), strings.HasPrefix(line, "UNIQUE KEY"),
+			strings.HasPrefix(line, "AGGREGATE KEY"), strings.HasPrefix(line, "PRIMARY KEY"):
+			schema.Keys = line
+		case strings.HasPrefix(line, "PARTITION BY") || strings.HasPrefix(line, "AUTO PARTITION BY"):
+			schema.PartitionBy = line
+		case strings.HasPrefix(line, "DISTRIBUTED BY"):
+			schema.Distribution = line
+		default:
+			if match := schemaPartitionRegex.FindStringSubmatch(line); match != nil {
+				schema.Partitions[match[1]] = trimPartitionDefinition(match[2])
+			}
+		}
+	}
+	return schema
+}
+
+func diffSchemaMap[V comparable](kind string, src, dest map[string]V) []string {
+	var diffs []string
+	for _, name := range sortedKeys(src) {
+		if destValue, ok := dest[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s %s not found in dest", kind, name))
+		} else if destValue != src[name] {
+			diffs = append(diffs, fmt.Sprintf("%s %s mismatch, src: %v, dest: %v", kind, name, src[name], destValue))
+		}
+	}
+	for _, name := range sortedKeys(dest) {
+		if _, ok := src[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s %s not found in src", kind, name))
+		}
+	}
+	return diffs
+}
+
+func diffTableSchema(src, dest *tableSchema) []string {
+	diffs := diffSchemaMap("column", src.Columns, dest.Columns)
+	if len(diffs) == 0 && strings.Join(src.ColumnOrder, ",") != strings.Join(dest.ColumnOrder, ",") {
+		diffs = append(diffs, fmt.Sprintf("column order mismatch, src: %v, dest: %v", src.ColumnOrder, dest.ColumnOrder))
+	}
+	diffs = append(diffs, diffSchemaMap("index", src.Indexes, dest.Indexes)...)
+
+	clauses := []struct {
+		name      string
+		src, dest string
+	}{
+		{"keys", src.Keys, dest.Keys},
+		{"partition by", src.PartitionBy, dest.PartitionBy},
+		{"distribution", src.Distribution, dest.Distribution},
+	}
+	for _, clause := range clauses {
+		if clause.src != clause.dest {
+			diffs = append(diffs, fmt.Sprintf("%s mismatch, src: %s, dest: %s", clause.name, clause.src, clause.dest))
+		}
+	}
+
+	diffs = append(diffs, diffSchemaMap("partition", src.Partitions, dest.Partitions)...)
+	diffs = append(diffs, diffSchemaMap("property", src.Properties, dest.Properties)...)
+	return diffs
+}
+
+func diffRollups(srcPartitions, destPartitions map[string]*PartitionMeta) []string {
+	srcRollups := make(map[string]struct{})
+	destRollups := make(map[string]struct{})
+	for _, partition := range srcPartitions {
+		collectRollups(srcRollups, partition)
+	}
+	for _, partition := range destPartitions {
+		collectRollups(destRollups, partition)
+	}
+	return diffSchemaMap("rollup", srcRollups, destRollups)
+}
+
+// Check the schema drift of the job and its fan-out dests once per schemaDriftCheckInterval,
+// the check runs in background and the result is saved in the job extra.
+func (j *Job) maybeCheckSchemaDrift() {
+	if schemaDriftCheckInterval <= 0 || j.parent != nil {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(j.lastSchemaDriftCheckAt) < schemaDriftCheckInterval {
+		return
+	}
+	if !j.schemaDriftChecking.CompareAndSwap(false, true) {
+		return
+	}
+	j.lastSchemaDriftCheckAt = now
+
+	go func() {
+		defer j.schemaDriftChecking.Store(false)
+		j.checkSchemaDrift()
+	}()
+}
+
+func (j *Job) checkSchemaDrift() {
+	drift := &SchemaDrift{CheckedAt: time.Now().UnixMilli()}
+	for _, job := range append([]*Job{j}, j.fanouts...) {
+		tables, err := job.detectSchemaDrift()
+		if err != nil {
+			log.Warnf("check schema drift of job %s failed, err: %+v", job.Name, err)
+			return
+		}
+		if job != j {
+			for _, table := range tables {
+				table.Dest = job.Name
+			}
+		}
+		drift.Tables = append(drift.Tables, tables...)
+	}
+
+	for _, table := range drift.Tables {
+		log.Warnf("job %s detects schema drift, src table: %s, dest table: %s, dest: %s, diffs: %v",
+			j.Name, table.SrcTable, table.DestTable, table.Dest, table.Diffs)
+	}
+	xmetrics.SchemaDrift(j.Name, len(drift.Tables))
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	savedExtra := j.Extra
+	j.Extra.SchemaDrift = drift
+	if err := j.persistJob(); err != nil {
+		j.Extra = savedExtra
+		log.Warnf("save schema drift of job %s failed, err: %+v", j.Name, err)
+	}
+}
+
+// Compare the schemas of the synced tables which exist in both src and dest, the missing
+// tables are reported by the verify.
+func (j *Job) detectSchemaDrift() ([]*TableSchemaDrift, error) {
+	j.lock.Lock()
+	incremental := j.progress != nil && j.isIncrementalSync()
+	j.lock.Unlock()
+	if !incremental {
+		log.Debugf("skip checking schema drift of job %s, it is not in incremental sync", j.Name)
+		return nil, nil
+	}
+
+	// Use the new metas, since the job is running in another goroutine.
+	srcTables, err := verifyTableIds(j.factory.NewMeta(&j.Src))
+	if err != nil {
+		return nil, err
+	}
+	destTables, err := verifyTableIds(j.factory.NewMeta(&j.Dest))
+	if err != nil {
+		return nil, err
+	}
+	tables, err := j.verifySrcTables(&VerifyOptions{}, srcTables)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := make([]*TableSchemaDrift, 0, len(tables))
+	srcTableIds := make([]int64, 0, len(tables))
+	destTableIds := make([]int64, 0, len(tables))
+	for _, table := range tables {
+		drift := &TableSchemaDrift{SrcTable: table, DestTable: j.planDestTableName(table)}
+		srcTableId, srcOk := srcTables[drift.SrcTable]
+		destTableId, destOk := destTables[drift.DestTable]
+		if !srcOk || !destOk {
+			continue
+		}
+		drifts = append(drifts, drift)
+		srcTableIds = append(srcTableIds, srcTableId)
+		destTableIds = append(destTableIds, destTableId)
+	}
+	if len(drifts) == 0 {
+		return nil, nil
+	}
+
+	srcThriftMeta, err := j.factory.NewThriftMeta(&j.Src, j.factory, srcTableIds)
+	if err != nil {
+		return nil, err
+	}
+	destThriftMeta, err := j.factory.NewThriftMeta(&j.Dest, j.factory, destTableIds)
+	if err != nil {
+		return nil, err
+	}
+
+	srcSpecer := j.factory.NewSpecer(&j.Src)
+	destSpecer := j.factory.NewSpecer(&j.Dest)
+	result := make([]*TableSchemaDrift, 0)
+	for i, drift := range drifts {
+		srcSql, err := srcSpecer.ShowCreateTable(drift.SrcTable)
+		if err != nil {
+			return nil, err
+		}
+		destSql, err := destSpecer.ShowCreateTable(drift.DestTable)
+		if err != nil {
+			return nil, err
+		}
+		drift.Diffs = diffTableSchema(parseTableSchema(srcSql), parseTableSchema(destSql))
+
+		srcPartitions, err := srcThriftMeta.GetPartitionRangeMap(srcTableIds[i])
+		if err != nil {
+			return nil, err
+		}
+		destPartitions, err := destThriftMeta.GetPartitionRangeMap(destTableIds[i])
+		if err != nil {
+			return nil, err
+		}
+		drift.Diffs = append(drift.Diffs, diffRollups(srcPartitions, destPartitions)...)
+
+		if len(drift.Diffs) > 0 {
+			result = append(result, drift)
+		}
+	}
+	return result, nil
+}
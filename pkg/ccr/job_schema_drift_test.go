@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"strings"
+	"testing"
+)
+
+const srcCreateTableSql = "CREATE TABLE `orders` (\n" +
+	"  `id` bigint NOT NULL,\n" +
+	"  `user` varchar(64) NULL COMMENT \"\",\n" +
+	"  `amount` decimal(10,2) NULL,\n" +
+	"  INDEX idx_user (`user`) USING INVERTED COMMENT ''\n" +
+	") ENGINE=OLAP\n" +
+	"DUPLICATE KEY(`id`)\n" +
+	"COMMENT 'OLAP'\n" +
+	"PARTITION BY RANGE(`id`)\n" +
+	"(PARTITION p1 VALUES [(\"-9223372036854775808\"), (\"100\")),\n" +
+	"PARTITION p2 VALUES [(\"100\"), (\"200\")))\n" +
+	"DISTRIBUTED BY HASH(`id`) BUCKETS 3\n" +
+	"PROPERTIES (\n" +
+	"\"replication_allocation\" = \"tag.location.default: 3\",\n" +
+	"\"storage_medium\" = \"ssd\",\n" +
+	"\"binlog.enable\" = \"true\",\n" +
+	"\"binlog.ttl_seconds\" = \"86400\",\n" +
+	"\"compression\" = \"ZSTD\"\n" +
+	");"
+
+func TestParseTableSchema(t *testing.T) {
+	schema := parseTableSchema(srcCreateTableSql)
+	if strings.Join(schema.ColumnOrder, ",") != "id,user,amount" {
+		t.Errorf("unexpected columns: %v", schema.ColumnOrder)
+	}
+	if schema.Columns["amount"] != "decimal(10,2) NULL" {
+		t.Errorf("unexpected column definition: %q", schema.Columns["amount"])
+	}
+	if schema.Indexes["idx_user"] != "(`user`) USING INVERTED COMMENT ''" {
+		t.Errorf("unexpected index definition: %q", schema.Indexes["idx_user"])
+	}
+	if schema.Partitions["p2"] != `VALUES [("100"), ("200"))` {
+		t.Errorf("unexpected partition definition: %q", schema.Partitions["p2"])
+	}
+	if schema.Keys != "DUPLICATE KEY(`id`)" || schema.Distribution != "DISTRIBUTED BY HASH(`id`) BUCKETS 3" {
+		t.Errorf("unexpected keys %q or distribution %q", schema.Keys, schema.Distribution)
+	}
+	if len(schema.Properties) != 1 || schema.Properties["compression"] != "ZSTD" {
+		t.Errorf("the rewritten properties should be removed: %v", schema.Properties)
+	}
+}
+
+func TestDiffTableSchema(t *testing.T) {
+	// the dest is renamed, with different replicas and storage medium, which are expected.
+	destSql := strings.NewReplacer(
+		"`orders`", "`orders_bak`",
+		"tag.location.default: 3", "tag.location.default: 1",
+		`"storage_medium" = "ssd"`, `"storage_medium" = "hdd"`,
+	).Replace(srcCreateTableSql)
+	if diffs := diffTableSchema(parseTableSchema(srcCreateTableSql), parseTableSchema(destSql)); len(diffs) != 0 {
+		t.Fatalf("expect no diffs, got %v", diffs)
+	}
+
+	destSql = strings.NewReplacer(
+		"  `amount` decimal(10,2) NULL,\n", "",
+		"  INDEX idx_user (`user`) USING INVERTED COMMENT ''\n", "",
+		"BUCKETS 3", "BUCKETS 5",
+	).Replace(srcCreateTableSql)
+	expected := []string{
+		"column amount not found in dest",
+		"index idx_user not found in dest",
+		"distribution mismatch, src: DISTRIBUTED BY HASH(`id`) BUCKETS 3, dest: DISTRIBUTED BY HASH(`id`) BUCKETS 5",
+	}
+	diffs := diffTableSchema(parseTableSchema(srcCreateTableSql), parseTableSchema(destSql))
+	if strings.Join(diffs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected diffs: %v", diffs)
+	}
+}
@@ -0,0 +1,93 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+
+	"github.com/selectdb/ccr_syncer/pkg/storage"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Whether any password of the specs, or any header of the webhook sinks, is stored in plaintext
+// or encrypted by a stale key.
+func (j *Job) hasStaleSecrets() bool {
+	if j.Src.IsSecretStale() || j.Dest.IsSecretStale() {
+		return true
+	}
+	for i := range j.Dests {
+		if j.Dests[i].IsSecretStale() {
+			return true
+		}
+	}
+	for _, sink := range j.Extra.Sinks {
+		if sink.Webhook != nil && sink.Webhook.IsSecretStale() {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactSecrets hides the passwords of the specs and the headers of the webhook sinks, the job
+// should not be run after that.
+func (j *Job) RedactSecrets() {
+	j.Src.Redact()
+	j.Dest.Redact()
+	for i := range j.Dests {
+		j.Dests[i].Redact()
+	}
+	for _, sink := range j.Extra.Sinks {
+		if sink.Webhook != nil {
+			sink.Webhook.Redact()
+		}
+	}
+}
+
+// ReencryptJobSecrets encrypts the passwords of all jobs in the meta db by the primary secret
+// key, so the old keys could be removed after that. The syncers should be stopped, since the
+// job info is rewritten.
+func ReencryptJobSecrets(db storage.DB) (int, error) {
+	jobNames, err := db.GetAllJobNames()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, jobName := range jobNames {
+		jobInfo, err := db.GetJobInfo(jobName)
+		if err != nil {
+			return count, err
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(jobInfo), &job); err != nil {
+			return count, xerror.Wrapf(err, xerror.Normal, "unmarshal job %s failed", jobName)
+		}
+		data, err := json.Marshal(&job)
+		if err != nil {
+			return count, xerror.Wrapf(err, xerror.Normal, "marshal job %s failed", jobName)
+		}
+		if err := db.UpdateJob(jobName, string(data)); err != nil {
+			return count, err
+		}
+		log.Infof("reencrypt the secrets of job %s", jobName)
+		count += 1
+	}
+	return count, nil
+}
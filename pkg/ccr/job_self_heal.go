@@ -0,0 +1,183 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	featureSelfHeal     bool
+	selfHealMaxAttempts int
+	selfHealWindow      time.Duration
+	selfHealBackoff     time.Duration
+)
+
+func init() {
+	flag.BoolVar(&featureSelfHeal, "feature_self_heal", false,
+		"repair the table by a partial snapshot instead of a full snapshot, when ingesting binlog meets a meta error")
+	flag.IntVar(&selfHealMaxAttempts, "self_heal_max_attempts", 3,
+		"the max partial snapshots to repair the tables in a self_heal_window, then fallback to the full snapshot")
+	flag.DurationVar(&selfHealWindow, "self_heal_window", time.Hour,
+		"the window to count the self heal attempts")
+	flag.DurationVar(&selfHealBackoff, "self_heal_backoff", 30*time.Second,
+		"the backoff before the next self heal attempt, doubled for each attempt in the window")
+}
+
+// The table, or the partitions of the table, to repair by a partial snapshot.
+type repairTarget struct {
+	TableId int64
+	Table   string
+	// Empty means the whole table.
+	Partitions []string
+}
+
+// repairableError is a meta error of ingesting binlogs, with the src tables it touched.
+type repairableError struct {
+	err     error
+	targets []*repairTarget
+}
+
+func (e *repairableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *repairableError) Unwrap() error {
+	return e.err
+}
+
+// Keep the stack of the xerror for %+v.
+func (e *repairableError) Format(s fmt.State, verb rune) {
+	fmt.Fprintf(s, fmt.FormatString(s, verb), e.err)
+}
+
+// Annotate the meta error of ingesting the table records with the tables to repair.
+func (j *Job) newRepairableError(err error, tableRecords []*record.TableRecord) error {
+	if !xerror.IsCategory(err, xerror.Meta) || len(tableRecords) == 0 {
+		return err
+	}
+
+	targets := make([]*repairTarget, 0, len(tableRecords))
+	for _, tableRecord := range tableRecords {
+		table, nameErr := j.srcTableName(tableRecord.Id)
+		if nameErr != nil || table == "" {
+			log.Warnf("get the name of src table %d failed, err: %v", tableRecord.Id, nameErr)
+			return err
+		}
+
+		target := &repairTarget{TableId: tableRecord.Id, Table: table}
+		for _, partitionRecord := range tableRecord.PartitionRecords {
+			if partitionRecord.IsTemp {
+				target.Partitions = nil
+				break
+			}
+			partition, nameErr := j.srcMeta.GetPartitionName(tableRecord.Id, partitionRecord.Id)
+			if nameErr != nil {
+				// the partition might be dropped, repair the whole table.
+				log.Warnf("get the name of partition %d of table %s failed, err: %v", partitionRecord.Id, table, nameErr)
+				target.Partitions = nil
+				break
+			}
+			target.Partitions = append(target.Partitions, partition)
+		}
+		targets = append(targets, target)
+	}
+	return &repairableError{err: err, targets: targets}
+}
+
+// repairBudget limits the self heal attempts, with backoff and a cap in the window.
+type repairBudget struct {
+	attempts []time.Time
+}
+
+// Acquire an attempt, returns the time to wait if it is in backoff, or false if the attempts
+// in the window are exhausted.
+func (b *repairBudget) acquire(now time.Time) (time.Duration, bool) {
+	kept := b.attempts[:0]
+	for _, attempt := range b.attempts {
+		if now.Sub(attempt) < selfHealWindow {
+			kept = append(kept, attempt)
+		}
+	}
+	b.attempts = kept
+
+	if len(b.attempts) >= selfHealMaxAttempts {
+		b.attempts = nil
+		return 0, false
+	}
+	if n := len(b.attempts); n > 0 {
+		backoff := selfHealBackoff << (n - 1)
+		if wait := b.attempts[n-1].Add(backoff).Sub(now); wait > 0 {
+			return wait, true
+		}
+	}
+	b.attempts = append(b.attempts, now)
+	return 0, true
+}
+
+// errSelfHealBackoff means the self heal is deferred by the backoff, the binlog is retried
+// in the next round instead of a full snapshot.
+var errSelfHealBackoff = errors.New("self heal is in backoff")
+
+// Try to repair the table of the meta error by a partial snapshot, returns true if the partial
+// snapshot is made. It returns false if the error should be handled by a full snapshot, or false
+// with errSelfHealBackoff if the repair is deferred.
+func (j *Job) selfHeal(err error) (bool, error) {
+	var repairErr *repairableError
+	if !featureSelfHeal || !errors.As(err, &repairErr) {
+		return false, nil
+	}
+	if len(repairErr.targets) != 1 {
+		// The partial snapshot of one table will skip the binlog of the others.
+		log.Warnf("the meta error touches %d tables, could not be repaired by a partial snapshot, job: %s",
+			len(repairErr.targets), j.Name)
+		return false, nil
+	}
+	target := repairErr.targets[0]
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if !j.isIncrementalSync() {
+		return false, nil
+	}
+
+	wait, ok := j.repairBudget.acquire(time.Now())
+	if !ok {
+		log.Warnf("the self heal attempts of job %s exceed %d in %s, fallback to the full snapshot",
+			j.Name, selfHealMaxAttempts, selfHealWindow)
+		return false, nil
+	} else if wait > 0 {
+		return false, fmt.Errorf("%w, wait %s before repairing table %s", errSelfHealBackoff, wait, target.Table)
+	}
+
+	log.Warnf("self heal job %s by a partial snapshot, table: %s, partitions: %v, err: %v",
+		j.Name, target.Table, target.Partitions, err)
+	if err := j.newPartialSnapshot(target.TableId, target.Table, target.Partitions, false); err != nil {
+		log.Warnf("self heal job %s failed, err: %+v", j.Name, err)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestRepairBudget(t *testing.T) {
+	savedMaxAttempts, savedWindow, savedBackoff := selfHealMaxAttempts, selfHealWindow, selfHealBackoff
+	defer func() {
+		selfHealMaxAttempts, selfHealWindow, selfHealBackoff = savedMaxAttempts, savedWindow, savedBackoff
+	}()
+	selfHealMaxAttempts, selfHealWindow, selfHealBackoff = 3, time.Hour, time.Minute
+
+	budget := &repairBudget{}
+	now := time.Now()
+	if wait, ok := budget.acquire(now); !ok || wait != 0 {
+		t.Fatalf("the first attempt should not wait, wait: %s, ok: %v", wait, ok)
+	}
+	if wait, ok := budget.acquire(now.Add(30 * time.Second)); !ok || wait != 30*time.Second {
+		t.Fatalf("the second attempt should wait the backoff, wait: %s, ok: %v", wait, ok)
+	}
+	if wait, ok := budget.acquire(now.Add(time.Minute)); !ok || wait != 0 {
+		t.Fatalf("the second attempt should not wait, wait: %s, ok: %v", wait, ok)
+	}
+	// the backoff is doubled
+	if wait, ok := budget.acquire(now.Add(2 * time.Minute)); !ok || wait != time.Minute {
+		t.Fatalf("the third attempt should wait the doubled backoff, wait: %s, ok: %v", wait, ok)
+	}
+	if wait, ok := budget.acquire(now.Add(3 * time.Minute)); !ok || wait != 0 {
+		t.Fatalf("the third attempt should not wait, wait: %s, ok: %v", wait, ok)
+	}
+	if _, ok := budget.acquire(now.Add(10 * time.Minute)); ok {
+		t.Fatalf("the attempts should be exhausted")
+	}
+
+	// the attempts out of the window are not counted
+	budget = &repairBudget{attempts: []time.Time{now, now.Add(time.Minute), now.Add(2 * time.Minute)}}
+	if wait, ok := budget.acquire(now.Add(2*time.Hour + time.Second)); !ok || wait != 0 {
+		t.Fatalf("the attempts out of the window should be ignored, wait: %s, ok: %v", wait, ok)
+	}
+}
+
+func TestSelfHeal(t *testing.T) {
+	savedFeature, savedMaxAttempts, savedWindow, savedBackoff := featureSelfHeal, selfHealMaxAttempts, selfHealWindow, selfHealBackoff
+	defer func() {
+		featureSelfHeal, selfHealMaxAttempts, selfHealWindow, selfHealBackoff = savedFeature, savedMaxAttempts, savedWindow, savedBackoff
+	}()
+	selfHealMaxAttempts, selfHealWindow, selfHealBackoff = 2, time.Hour, time.Hour
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateProgress(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	newJob := func() *Job {
+		job := &Job{Name: "test_job", SyncType: DBSync}
+		job.progress = NewJobProgress(job.Name, DBSync, db)
+		job.progress.SyncState = DBIncrementalSync
+		job.progress.CommitSeq = 10
+		return job
+	}
+	metaErr := xerror.Errorf(xerror.Meta, "no src replica version > 10")
+	err := &repairableError{err: metaErr, targets: []*repairTarget{{TableId: 1, Table: "tbl", Partitions: []string{"p1"}}}}
+
+	// disabled
+	featureSelfHeal = false
+	job := newJob()
+	if healed, healErr := job.selfHeal(err); healed || healErr != nil {
+		t.Fatalf("the disabled self heal should fallback, healed: %v, err: %v", healed, healErr)
+	}
+
+	featureSelfHeal = true
+	// the errors without the targets, or with more than one table
+	if healed, healErr := job.selfHeal(metaErr); healed || healErr != nil {
+		t.Fatalf("the error without targets should fallback, healed: %v, err: %v", healed, healErr)
+	}
+	multiErr := &repairableError{err: metaErr, targets: []*repairTarget{{TableId: 1, Table: "t1"}, {TableId: 2, Table: "t2"}}}
+	if healed, healErr := job.selfHeal(multiErr); healed || healErr != nil {
+		t.Fatalf("the error of multiple tables should fallback, healed: %v, err: %v", healed, healErr)
+	}
+
+	// the first attempt makes a partial snapshot
+	if healed, healErr := job.selfHeal(err); !healed || healErr != nil {
+		t.Fatalf("the self heal should make a partial snapshot, healed: %v, err: %v", healed, healErr)
+	}
+	if job.progress.SyncState != DBPartialSync || job.progress.PartialSyncData == nil ||
+		job.progress.PartialSyncData.Table != "tbl" || job.progress.PartialSyncData.Partitions[0] != "p1" {
+		t.Fatalf("unexpected partial sync, state: %s, data: %+v", job.progress.SyncState, job.progress.PartialSyncData)
+	}
+
+	// the next attempt in the backoff is deferred, without any snapshot
+	job.progress.SyncState = DBIncrementalSync
+	job.progress.PartialSyncData = nil
+	if healed, healErr := job.selfHeal(err); healed || !errors.Is(healErr, errSelfHealBackoff) {
+		t.Fatalf("the self heal in backoff should be deferred, healed: %v, err: %v", healed, healErr)
+	}
+	if job.progress.SyncState != DBIncrementalSync || job.progress.PartialSyncData != nil {
+		t.Fatalf("the self heal in backoff should not make a snapshot, state: %s", job.progress.SyncState)
+	}
+
+	// the exhausted attempts fallback to the full snapshot
+	now := time.Now()
+	job.repairBudget.attempts = []time.Time{now.Add(-3 * time.Hour / 4), now.Add(-time.Hour / 2)}
+	if healed, healErr := job.selfHeal(err); healed || healErr != nil {
+		t.Fatalf("the exhausted self heal should fallback, healed: %v, err: %v", healed, healErr)
+	}
+
+	// not in the incremental sync
+	job = newJob()
+	job.progress.SyncState = DBFullSync
+	if healed, healErr := job.selfHeal(err); healed || healErr != nil {
+		t.Fatalf("the self heal out of the incremental sync should fallback, healed: %v, err: %v", healed, healErr)
+	}
+}
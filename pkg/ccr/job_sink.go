@@ -0,0 +1,281 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BinlogEvent is a binlog handled by the job, exported to the sinks.
+type BinlogEvent struct {
+	Job        string `json:"job"`
+	CommitSeq  int64  `json:"commit_seq"`
+	BinlogType string `json:"binlog_type"`
+	// The src table names of the binlog.
+	Tables []string `json:"tables,omitempty"`
+	// The decoded record.* struct of the binlog, or the raw data if it could not be decoded.
+	Record any `json:"record"`
+	// Skipped by the ddl policy or job_skip_binlog, it is not applied to the dest cluster.
+	Skipped bool `json:"skipped,omitempty"`
+	// The unix time in milliseconds of the binlog in the src cluster.
+	Timestamp int64 `json:"timestamp"`
+	// The unix time in milliseconds when the binlog was committed in the dest cluster.
+	AppliedAt int64 `json:"applied_at"`
+}
+
+// Decode the data of the binlog into the record.* struct.
+func decodeBinlogRecord(binlog *festruct.TBinlog) (any, error) {
+	data := binlog.GetData()
+	switch binlog.GetType() {
+	case festruct.TBinlogType_UPSERT:
+		return record.NewUpsertFromJson(data)
+	case festruct.TBinlogType_ADD_PARTITION:
+		return record.NewAddPartitionFromJson(data)
+	case festruct.TBinlogType_CREATE_TABLE:
+		return record.NewCreateTableFromJson(data)
+	case festruct.TBinlogType_DROP_PARTITION:
+		return record.NewDropPartitionFromJson(data)
+	case festruct.TBinlogType_DROP_TABLE:
+		return record.NewDropTableFromJson(data)
+	case festruct.TBinlogType_ALTER_JOB:
+		return record.NewAlterJobV2FromJson(data)
+	case festruct.TBinlogType_MODIFY_TABLE_ADD_OR_DROP_COLUMNS:
+		return record.NewModifyTableAddOrDropColumnsFromJson(data)
+	case festruct.TBinlogType_RENAME_COLUMN:
+		return record.NewRenameColumnFromJson(data)
+	case festruct.TBinlogType_MODIFY_COMMENT:
+		return record.NewModifyCommentFromJson(data)
+	case festruct.TBinlogType_MODIFY_TABLE_PROPERTY:
+		return record.NewModifyTablePropertyFromJson(data)
+	case festruct.TBinlogType_BARRIER:
+		return record.NewBarrierLogFromJson(data)
+	case festruct.TBinlogType_TRUNCATE_TABLE:
+		return record.NewTruncateTableFromJson(data)
+	case festruct.TBinlogType_RENAME_TABLE:
+		return record.NewRenameTableFromJson(data)
+	case festruct.TBinlogType_REPLACE_PARTITIONS:
+		return record.NewReplacePartitionFromJson(data)
+	case festruct.TBinlogType_REPLACE_TABLE:
+		return record.NewReplaceTableRecordFromJson(data)
+	case festruct.TBinlogType_MODIFY_VIEW_DEF:
+		return record.NewAlterViewFromJson(data)
+	case festruct.TBinlogType_MODIFY_TABLE_ADD_OR_DROP_INVERTED_INDICES:
+		return record.NewModifyTableAddOrDropInvertedIndicesFromJson(data)
+	case festruct.TBinlogType_INDEX_CHANGE_JOB:
+		return record.NewIndexChangeJobFromJson(data)
+	case festruct.TBinlogType_RENAME_PARTITION:
+		return record.NewRenamePartitionFromJson(data)
+	case festruct.TBinlogType_RENAME_ROLLUP:
+		return record.NewRenameRollupFromJson(data)
+	case festruct.TBinlogType_DROP_ROLLUP:
+		return record.NewDropRollupFromJson(data)
+	case festruct.TBinlogType_RECOVER_INFO:
+		return record.NewRecoverInfoFromJson(data)
+	default:
+		return nil, xerror.Errorf(xerror.Normal, "no record of binlog type %s", binlog.GetType())
+	}
+}
+
+func (j *Job) newBinlogEvent(binlog *festruct.TBinlog, skipped bool) *BinlogEvent {
+	event := &BinlogEvent{
+		Job:        j.Name,
+		CommitSeq:  binlog.GetCommitSeq(),
+		BinlogType: binlog.GetType().String(),
+		Skipped:    skipped || j.isSkippedBySilence(binlog),
+		Timestamp:  binlog.GetTimestamp(),
+		AppliedAt:  time.Now().UnixMilli(),
+	}
+
+	if decoded, err := decodeBinlogRecord(binlog); err != nil {
+		log.Debugf("decode binlog %d failed, use the raw data, err: %v", binlog.GetCommitSeq(), err)
+		if data := binlog.GetData(); json.Valid([]byte(data)) {
+			event.Record = json.RawMessage(data)
+		} else {
+			event.Record = data
+		}
+	} else {
+		event.Record = decoded
+	}
+
+	for _, tableId := range binlog.GetTableIds() {
+		if name, err := j.srcTableName(tableId); err == nil && name != "" {
+			event.Tables = append(event.Tables, name)
+		}
+	}
+	if dropTable, ok := event.Record.(*record.DropTable); ok && len(event.Tables) == 0 && dropTable.TableName != "" {
+		// the table name mapping is removed after the table is dropped
+		event.Tables = append(event.Tables, dropTable.TableName)
+	}
+	return event
+}
+
+func (j *Job) isSkippedBySilence(binlog *festruct.TBinlog) bool {
+	return j.Extra.SkipBinlog && j.Extra.SkipBy == SkipBySilence && j.Extra.SkipCommitSeq == binlog.GetCommitSeq()
+}
+
+// The sink of a job, with the name of its offset in the progress.
+type jobSink struct {
+	name   string
+	sink   Sink
+	config *SinkConfig
+
+	// The time the sink began to fail, zero if it is healthy.
+	failingSince time.Time
+}
+
+// Whether to drop the events the sink failed to send, so a failing sink does not block the
+// replication forever.
+func (s *jobSink) shouldDrop(now time.Time) bool {
+	if s.config.OnFailure == SinkOnFailureDrop {
+		return true
+	}
+	if s.failingSince.IsZero() {
+		s.failingSince = now
+	}
+	maxBlock := time.Duration(s.config.MaxBlockSeconds) * time.Second
+	return maxBlock > 0 && now.Sub(s.failingSince) >= maxBlock
+}
+
+// The name of the sink configured by the file_sink of the job.
+const fileSinkName = "file"
+
+func (j *Job) sinkConfigs() []*SinkConfig {
+	configs := make([]*SinkConfig, 0, len(j.Extra.Sinks)+1)
+	if j.Extra.FileSink != nil {
+		configs = append(configs, &SinkConfig{Name: fileSinkName, Type: SinkTypeFile, File: j.Extra.FileSink})
+	}
+	return append(configs, j.Extra.Sinks...)
+}
+
+func (j *Job) validSinks() error {
+	if err := j.Extra.FileSink.Valid(); err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{})
+	for _, config := range j.sinkConfigs() {
+		if err := config.Valid(); err != nil {
+			return err
+		}
+		if _, ok := names[config.Name]; ok {
+			return xerror.Errorf(xerror.Normal, "sink %s is duplicated", config.Name)
+		}
+		names[config.Name] = struct{}{}
+	}
+	return nil
+}
+
+func (j *Job) initSinks() {
+	j.sinks = nil
+	for _, config := range j.sinkConfigs() {
+		sink, err := config.newSink(j.Name)
+		if err != nil {
+			log.Errorf("init sink %s of job %s failed, err: %+v", config.Name, j.Name, err)
+			continue
+		}
+		j.sinks = append(j.sinks, &jobSink{name: config.Name, sink: sink, config: config})
+	}
+}
+
+func (j *Job) closeSinks() {
+	for _, sink := range j.sinks {
+		if err := sink.sink.Close(); err != nil {
+			log.Warnf("close sink %s of job %s failed, err: %+v", sink.name, j.Name, err)
+		}
+	}
+}
+
+// The commit seq to get the binlogs from, it is before the progress if any sink lags behind.
+func (j *Job) sinkFetchCommitSeq() int64 {
+	commitSeq := j.progress.CommitSeq
+	for _, sink := range j.sinks {
+		if offset, ok := j.progress.SinkOffsets[sink.name]; ok && offset < commitSeq {
+			commitSeq = offset
+		}
+	}
+	return commitSeq
+}
+
+// Export the binlogs which have been handled to the sinks, and advance the offsets of the
+// sinks. The caller must hold the job lock.
+func (j *Job) exportBinlogs(binlogs []*festruct.TBinlog, skipped bool) error {
+	if len(j.sinks) == 0 || len(binlogs) == 0 {
+		return nil
+	}
+
+	var events []*BinlogEvent
+	lastCommitSeq := binlogs[len(binlogs)-1].GetCommitSeq()
+	updated := false
+	for _, sink := range j.sinks {
+		if j.progress.SinkOffsets == nil {
+			j.progress.SinkOffsets = make(map[string]int64)
+		}
+		offset, ok := j.progress.SinkOffsets[sink.name]
+		if !ok {
+			// a new sink, starts from these binlogs
+			offset = binlogs[0].GetCommitSeq() - 1
+			j.progress.SinkOffsets[sink.name] = offset
+			updated = true
+		}
+		if offset >= lastCommitSeq {
+			continue
+		}
+
+		if events == nil {
+			events = make([]*BinlogEvent, 0, len(binlogs))
+			for _, binlog := range binlogs {
+				events = append(events, j.newBinlogEvent(binlog, skipped))
+			}
+		}
+		pending := make([]*BinlogEvent, 0, len(events))
+		for _, event := range events {
+			if event.CommitSeq > offset {
+				pending = append(pending, event)
+			}
+		}
+
+		if err := sink.sink.Send(pending); err != nil {
+			err = xerror.Wrapf(err, xerror.Normal, "send binlog events to sink %s, commit seq: [%d, %d]",
+				sink.name, pending[0].CommitSeq, lastCommitSeq)
+			if !sink.shouldDrop(time.Now()) {
+				if updated {
+					j.progress.Persist()
+				}
+				return err
+			}
+			log.Errorf("drop %d binlog events of sink %s, on failure: %s, err: %+v",
+				len(pending), sink.name, sink.config.OnFailure, err)
+		} else {
+			sink.failingSince = time.Time{}
+		}
+		j.progress.SinkOffsets[sink.name] = lastCommitSeq
+		updated = true
+	}
+
+	if updated {
+		j.progress.Persist()
+	}
+	return nil
+}
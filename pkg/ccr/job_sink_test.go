@@ -0,0 +1,223 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+
+	"go.uber.org/mock/gomock"
+)
+
+type bufferWriteCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferWriteCloser) Close() error { return nil }
+
+func TestFileSink(t *testing.T) {
+	buffer := &bufferWriteCloser{}
+	job := &Job{
+		Name:     "test_job",
+		SyncType: TableSync,
+		Src:      base.Spec{Table: "orders"},
+	}
+	sink := &fileSink{writer: buffer}
+
+	commitSeq := int64(10)
+	timestamp := int64(1700000000000)
+	binlogType := festruct.TBinlogType_TRUNCATE_TABLE
+	data := `{"dbId":1,"db":"db","tblId":100,"table":"orders","rawSql":""}`
+	binlog := &festruct.TBinlog{
+		CommitSeq: &commitSeq,
+		Timestamp: &timestamp,
+		Type:      &binlogType,
+		TableIds:  []int64{100},
+		Data:      &data,
+	}
+	if err := sink.Send([]*BinlogEvent{job.newBinlogEvent(binlog, false)}); err != nil {
+		t.Fatalf("send binlog event failed: %v", err)
+	}
+
+	var event struct {
+		Job        string          `json:"job"`
+		CommitSeq  int64           `json:"commit_seq"`
+		BinlogType string          `json:"binlog_type"`
+		Tables     []string        `json:"tables"`
+		Record     json.RawMessage `json:"record"`
+		Timestamp  int64           `json:"timestamp"`
+	}
+	line := buffer.Bytes()
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		t.Fatalf("expect one json line, got %q", line)
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		t.Fatalf("unmarshal binlog event failed: %v", err)
+	}
+	if event.Job != "test_job" || event.CommitSeq != 10 || event.BinlogType != "TRUNCATE_TABLE" ||
+		event.Timestamp != timestamp || len(event.Tables) != 1 || event.Tables[0] != "orders" {
+		t.Errorf("unexpected binlog event: %s", line)
+	}
+	if len(event.Record) == 0 || event.Record[0] != '{' {
+		t.Errorf("unexpected record of binlog event: %s", event.Record)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan []*BinlogEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var events []*BinlogEvent
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- events
+	}))
+	defer server.Close()
+
+	events := []*BinlogEvent{
+		{Job: "test_job", CommitSeq: 10, BinlogType: "UPSERT"},
+		{Job: "test_job", CommitSeq: 11, BinlogType: "DROP_TABLE"},
+	}
+
+	unauthorized := (&WebhookSink{URL: server.URL}).newSink()
+	if err := unauthorized.Send(events); err == nil {
+		t.Errorf("expect the webhook sink failed without the authorization header")
+	}
+
+	sink := (&WebhookSink{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}).newSink()
+	defer sink.Close()
+	if err := sink.Send(events); err != nil {
+		t.Fatalf("send binlog events failed: %v", err)
+	}
+	if got := <-received; len(got) != 2 || got[0].CommitSeq != 10 || got[1].BinlogType != "DROP_TABLE" {
+		t.Errorf("unexpected events received by webhook: %v", got)
+	}
+}
+
+func TestWebhookSinkHeadersSecret(t *testing.T) {
+	keyring, err := base.NewSecretKeyring([][]byte{bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("new keyring failed: %v", err)
+	}
+	base.SetSecretKeyring(keyring)
+	defer base.SetSecretKeyring(nil)
+
+	job := Job{Name: "test_job"}
+	job.Extra.Sinks = []*SinkConfig{{
+		Name:    "hook",
+		Type:    SinkTypeWebhook,
+		Webhook: &WebhookSink{URL: "http://hook", Headers: map[string]string{"Authorization": "Bearer token"}},
+	}}
+	data, err := json.Marshal(&job)
+	if err != nil {
+		t.Fatalf("marshal job failed: %v", err)
+	}
+	if strings.Contains(string(data), "Bearer token") || !strings.Contains(string(data), "enc:v1:") {
+		t.Fatalf("the headers should be encrypted: %s", data)
+	}
+
+	var decoded Job
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal job failed: %v", err)
+	}
+	if got := decoded.Extra.Sinks[0].Webhook.Headers["Authorization"]; got != "Bearer token" || decoded.hasStaleSecrets() {
+		t.Fatalf("the headers should be decrypted, got: %s", got)
+	}
+
+	decoded.RedactSecrets()
+	if data, err = json.Marshal(&decoded); err != nil {
+		t.Fatalf("marshal job failed: %v", err)
+	}
+	if strings.Contains(string(data), "Bearer token") || strings.Contains(string(data), "enc:v1:") ||
+		!strings.Contains(string(data), base.RedactedSecret) {
+		t.Fatalf("the headers should be redacted: %s", data)
+	}
+}
+
+type failingSink struct {
+	sent int
+}
+
+func (s *failingSink) Send(events []*BinlogEvent) error {
+	s.sent += len(events)
+	return errors.New("sink is down")
+}
+
+func (s *failingSink) Close() error { return nil }
+
+func TestSinkOnFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateProgress(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	newBinlog := func(commitSeq int64) *festruct.TBinlog {
+		binlogType := festruct.TBinlogType_BARRIER
+		data := "{}"
+		return &festruct.TBinlog{CommitSeq: &commitSeq, Type: &binlogType, Data: &data}
+	}
+
+	dropped := &jobSink{name: "dropped", sink: &failingSink{}, config: &SinkConfig{OnFailure: SinkOnFailureDrop}}
+	blocked := &jobSink{name: "blocked", sink: &failingSink{}, config: &SinkConfig{MaxBlockSeconds: 60}}
+	job := &Job{Name: "test_job", SyncType: TableSync}
+	job.progress = NewJobProgress(job.Name, TableSync, db)
+	job.progress.CommitSeq = 10
+	job.progress.SinkOffsets = map[string]int64{"dropped": 9, "blocked": 9}
+
+	// the dropped sink goes on, and the blocked sink blocks the replication
+	job.sinks = []*jobSink{dropped}
+	if err := job.exportBinlogs([]*festruct.TBinlog{newBinlog(10)}, false); err != nil {
+		t.Fatalf("the dropped sink should not block, err: %v", err)
+	}
+	if offset := job.progress.SinkOffsets["dropped"]; offset != 10 {
+		t.Errorf("the offset of the dropped sink should advance, got %d", offset)
+	}
+
+	job.sinks = []*jobSink{blocked}
+	if err := job.exportBinlogs([]*festruct.TBinlog{newBinlog(10)}, false); err == nil {
+		t.Fatalf("the blocked sink should fail")
+	}
+	if offset := job.progress.SinkOffsets["blocked"]; offset != 9 || job.sinkFetchCommitSeq() != 9 {
+		t.Errorf("the offset of the blocked sink should not advance, got %d", offset)
+	}
+
+	// the blocked sink is dropped after the max block seconds
+	blocked.failingSince = time.Now().Add(-time.Minute)
+	if err := job.exportBinlogs([]*festruct.TBinlog{newBinlog(10)}, false); err != nil {
+		t.Fatalf("the sink blocked too long should be dropped, err: %v", err)
+	}
+	if offset := job.progress.SinkOffsets["blocked"]; offset != 10 {
+		t.Errorf("the offset of the blocked sink should advance, got %d", offset)
+	}
+}
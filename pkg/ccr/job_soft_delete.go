@@ -0,0 +1,235 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// The prefix of the archived tables in the dest database.
+	ArchiveTablePrefix = "__ccr_dropped_"
+
+	// The default max length of the table name of doris, see `table_name_length_limit`.
+	maxTableNameLength = 64
+
+	archivePurgeInterval = time.Minute
+)
+
+// SoftDelete archives the dropped tables and partitions in the dest database instead of
+// dropping them, the archives are purged after the TTL.
+type SoftDelete struct {
+	Enable bool `json:"enable"`
+	// The seconds to keep the archives, zero means keep them forever.
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+	// Archive the dropped partitions too. The data of the partition is copied by a synchronous
+	// CREATE TABLE AS SELECT, which blocks the job until all rows of the partition are copied.
+	ArchivePartitions bool `json:"archive_partitions,omitempty"`
+}
+
+// The table or partition archived by the soft delete.
+type ArchivedTable struct {
+	// The name of the archive table in the dest database.
+	Name      string `json:"name"`
+	Table     string `json:"table"`
+	Partition string `json:"partition,omitempty"`
+	CommitSeq int64  `json:"commit_seq"`
+	// The unix time in milliseconds when it was archived.
+	ArchivedAt int64 `json:"archived_at"`
+}
+
+func (s *SoftDelete) IsEnabled() bool {
+	return s != nil && s.Enable
+}
+
+func (s *SoftDelete) Valid() error {
+	if s != nil && s.TTLSeconds < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid soft delete ttl seconds: %d", s.TTLSeconds)
+	}
+	return nil
+}
+
+// Build the archive table name: __ccr_dropped_<name>_<seq>, the name is truncated if the
+// archive table name is too long.
+func archiveTableName(name string, commitSeq int64) string {
+	suffix := fmt.Sprintf("_%d", commitSeq)
+	limit := maxTableNameLength - len(ArchiveTablePrefix) - len(suffix)
+	if len(name) > limit {
+		runes := []rune(name)
+		for len(string(runes)) > limit {
+			runes = runes[:len(runes)-1]
+		}
+		name = string(runes)
+	}
+	return ArchiveTablePrefix + name + suffix
+}
+
+func (j *Job) isSoftDelete() bool {
+	return j.Extra.SoftDelete.IsEnabled()
+}
+
+func (j *Job) isArchivePartitions() bool {
+	return j.isSoftDelete() && j.Extra.SoftDelete.ArchivePartitions
+}
+
+func (j *Job) addArchivedTable(archived *ArchivedTable) {
+	for _, table := range j.progress.ArchivedTables {
+		if table.Name == archived.Name {
+			return
+		}
+	}
+	j.progress.ArchivedTables = append(j.progress.ArchivedTables, archived)
+}
+
+// Rename the dest table to the archive name instead of dropping it. The archive is saved
+// in the progress, which is persisted once the binlog is done.
+func (j *Job) archiveTable(destTableName string, commitSeq int64) error {
+	archiveName := archiveTableName(destTableName, commitSeq)
+	if exists, err := j.IDest.CheckTableExistsByName(archiveName); err != nil {
+		return err
+	} else if exists {
+		log.Infof("the table %s has been archived as %s", destTableName, archiveName)
+	} else if err := j.IDest.RenameTableWithName(destTableName, archiveName); err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "archive table %s", destTableName)
+	}
+
+	log.Infof("archive the dropped table %s as %s, commit seq: %d", destTableName, archiveName, commitSeq)
+	j.addArchivedTable(&ArchivedTable{
+		Name:       archiveName,
+		Table:      destTableName,
+		CommitSeq:  commitSeq,
+		ArchivedAt: time.Now().UnixMilli(),
+	})
+	return nil
+}
+
+// Copy the data of the partition into an archive table, then drop the partition.
+func (j *Job) archivePartition(destTableName string, dropPartition *record.DropPartition, commitSeq int64) error {
+	partitionName := dropPartition.GetPartitionName()
+	if partitionName == "" {
+		return xerror.Errorf(xerror.Normal, "partition name not found, drop partition sql: %s", dropPartition.Sql)
+	}
+
+	archiveName := archiveTableName(fmt.Sprintf("%s_%s", destTableName, partitionName), commitSeq)
+	if exists, err := j.IDest.CheckTableExistsByName(archiveName); err != nil {
+		return err
+	} else if !exists {
+		if err := j.IDest.CopyPartitionToTable(destTableName, partitionName, archiveName); err != nil {
+			return xerror.Wrapf(err, xerror.Normal, "archive partition %s of table %s", partitionName, destTableName)
+		}
+	}
+
+	log.Infof("archive the dropped partition %s of table %s as %s, commit seq: %d",
+		partitionName, destTableName, archiveName, commitSeq)
+	j.addArchivedTable(&ArchivedTable{
+		Name:       archiveName,
+		Table:      destTableName,
+		Partition:  partitionName,
+		CommitSeq:  commitSeq,
+		ArchivedAt: time.Now().UnixMilli(),
+	})
+	return j.IDest.DropPartition(destTableName, dropPartition)
+}
+
+// The fullsync of the soft delete job don't clean the dest tables, so archive the dest tables
+// which are not in the snapshot before the restore, instead of keeping them as orphans.
+func (j *Job) archiveOrphanTables(snapshotTables map[string]struct{}, commitSeq int64) error {
+	tables, err := j.destMeta.GetTables()
+	if err != nil {
+		return err
+	}
+
+	archived := false
+	for _, table := range tables {
+		if _, ok := snapshotTables[table.Name]; ok || strings.HasPrefix(table.Name, ArchiveTablePrefix) {
+			continue
+		}
+
+		switch table.Type {
+		case record.TableTypeOlap:
+			if err := j.archiveTable(table.Name, commitSeq); err != nil {
+				return err
+			}
+			archived = true
+		case record.TableTypeView:
+			log.Infof("drop the orphan view %s before the fullsync", table.Name)
+			if err := j.IDest.DropView(table.Name); err != nil {
+				return err
+			}
+		default:
+			log.Warnf("keep the orphan table %s, type: %s", table.Name, table.Type)
+		}
+	}
+
+	if archived {
+		j.destMeta.ClearTablesCache()
+		j.progress.Persist()
+	}
+	return nil
+}
+
+// Drop the archives which are expired, it runs at most once per archivePurgeInterval.
+func (j *Job) purgeArchivedTables() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	softDelete := j.Extra.SoftDelete
+	if j.progress == nil || len(j.progress.ArchivedTables) == 0 || softDelete == nil || softDelete.TTLSeconds <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(j.lastArchivePurgeAt) < archivePurgeInterval {
+		return
+	}
+	j.lastArchivePurgeAt = now
+
+	ttl := time.Duration(softDelete.TTLSeconds) * time.Second
+	kept := make([]*ArchivedTable, 0, len(j.progress.ArchivedTables))
+	for _, archived := range j.progress.ArchivedTables {
+		if now.Sub(time.UnixMilli(archived.ArchivedAt)) < ttl {
+			kept = append(kept, archived)
+			continue
+		}
+
+		if exists, err := j.IDest.CheckTableExistsByName(archived.Name); err != nil {
+			log.Warnf("check archive table %s failed, err: %+v", archived.Name, err)
+			kept = append(kept, archived)
+			continue
+		} else if exists {
+			if err := j.IDest.DropTable(archived.Name, true); err != nil {
+				log.Warnf("purge archive table %s failed, err: %+v", archived.Name, err)
+				kept = append(kept, archived)
+				continue
+			}
+		}
+		log.Infof("purge the expired archive table %s of job %s, archived at: %s",
+			archived.Name, j.Name, time.UnixMilli(archived.ArchivedAt).Format(time.DateTime))
+	}
+
+	if len(kept) != len(j.progress.ArchivedTables) {
+		j.progress.ArchivedTables = kept
+		j.progress.Persist()
+	}
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestArchiveTableName(t *testing.T) {
+	if name := archiveTableName("orders", 1001); name != "__ccr_dropped_orders_1001" {
+		t.Errorf("unexpected archive table name: %s", name)
+	}
+
+	name := archiveTableName(strings.Repeat("t", 100), 1001)
+	if len(name) > maxTableNameLength || !strings.HasSuffix(name, "t_1001") {
+		t.Errorf("unexpected archive table name of long table: %s", name)
+	}
+}
+
+func TestDropPartitionName(t *testing.T) {
+	sqls := []string{
+		"DROP PARTITION p1",
+		"DROP PARTITION `p1`",
+		"DROP PARTITION IF EXISTS `p1` FORCE",
+		"drop partition p1",
+	}
+	for _, sql := range sqls {
+		dropPartition := &record.DropPartition{Sql: sql}
+		if name := dropPartition.GetPartitionName(); name != "p1" {
+			t.Errorf("parse partition name of %q, got %q", sql, name)
+		}
+	}
+}
+
+// archiveSpecer records the renamed tables and the dropped views of the dest.
+type archiveSpecer struct {
+	base.Specer
+	renamed map[string]string
+	dropped []string
+}
+
+func (s *archiveSpecer) CheckTableExistsByName(tableName string) (bool, error) {
+	return false, nil
+}
+
+func (s *archiveSpecer) RenameTableWithName(destTableName, newName string) error {
+	s.renamed[destTableName] = newName
+	return nil
+}
+
+func (s *archiveSpecer) DropView(viewName string) error {
+	s.dropped = append(s.dropped, viewName)
+	return nil
+}
+
+func TestArchiveOrphanTables(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateProgress(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	destMeta := NewMockMetaer(ctrl)
+	destMeta.EXPECT().GetTables().Return(map[int64]*TableMeta{
+		1: {Id: 1, Name: "t1", Type: record.TableTypeOlap},
+		2: {Id: 2, Name: "orphan", Type: record.TableTypeOlap},
+		3: {Id: 3, Name: "orphan_view", Type: record.TableTypeView},
+		4: {Id: 4, Name: "v1", Type: record.TableTypeView},
+		5: {Id: 5, Name: "__ccr_dropped_old_10", Type: record.TableTypeOlap},
+	}, nil)
+	destMeta.EXPECT().ClearTablesCache()
+
+	specer := &archiveSpecer{renamed: make(map[string]string)}
+	job := &Job{Name: "test_job", SyncType: DBSync, IDest: specer, destMeta: destMeta}
+	job.Extra.SoftDelete = &SoftDelete{Enable: true}
+	job.progress = NewJobProgress(job.Name, DBSync, db)
+
+	snapshotTables := map[string]struct{}{"t1": {}, "v1": {}}
+	if err := job.archiveOrphanTables(snapshotTables, 100); err != nil {
+		t.Fatalf("archive orphan tables failed, err: %v", err)
+	}
+	if len(specer.renamed) != 1 || specer.renamed["orphan"] != "__ccr_dropped_orphan_100" {
+		t.Errorf("only the orphan table should be archived, renamed: %v", specer.renamed)
+	}
+	if len(specer.dropped) != 1 || specer.dropped[0] != "orphan_view" {
+		t.Errorf("only the orphan view should be dropped, dropped: %v", specer.dropped)
+	}
+	if len(job.progress.ArchivedTables) != 1 || job.progress.ArchivedTables[0].Table != "orphan" {
+		t.Errorf("the archived table should be recorded, got %+v", job.progress.ArchivedTables)
+	}
+}
@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	festruct "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/frontendservice"
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	"go.uber.org/mock/gomock"
+)
+
+// srcTimeSpecer returns the current timestamp of src.
+type srcTimeSpecer struct {
+	base.Specer
+	timestamp int64
+}
+
+func (s *srcTimeSpecer) GetCurrentTimestamp() (int64, error) {
+	return s.timestamp, nil
+}
+
+func TestIsBeyondStopAt(t *testing.T) {
+	newBinlog := func(commitSeq, timestamp int64) *festruct.TBinlog {
+		return &festruct.TBinlog{CommitSeq: &commitSeq, Timestamp: &timestamp}
+	}
+
+	job := &Job{}
+	if job.isBeyondStopAt(newBinlog(100, 100000)) {
+		t.Errorf("the job without target should not stop")
+	}
+
+	job.Extra.StopAtCommitSeq = 10
+	if job.isBeyondStopAt(newBinlog(10, 1000)) || !job.isBeyondStopAt(newBinlog(11, 1000)) {
+		t.Errorf("the job should stop after the binlog of the stop at commit seq")
+	}
+
+	job.Extra.StopAtCommitSeq = 0
+	job.Extra.StopAtTimestamp = 1000
+	if job.isBeyondStopAt(newBinlog(11, 1000)) || !job.isBeyondStopAt(newBinlog(11, 1001)) {
+		t.Errorf("the job should stop before the binlog after the stop at timestamp")
+	}
+}
+
+func TestStopAtCaughtUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateJob(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	// the job has caught up with src
+	tooNew := &festruct.TGetBinlogResult_{Status: &tstatus.TStatus{StatusCode: tstatus.TStatusCode_BINLOG_TOO_NEW_COMMIT_SEQ}}
+	feRpc := NewMockIFeRpc(ctrl)
+	feRpc.EXPECT().GetBinlog(gomock.Any(), int64(10)).Return(tooNew, nil).AnyTimes()
+	rpcFactory := NewMockIRpcFactory(ctrl)
+	rpcFactory.EXPECT().NewFeRpc(gomock.Any()).Return(feRpc, nil).AnyTimes()
+
+	specer := &srcTimeSpecer{}
+	newJob := func(stopAtCommitSeq, stopAtTimestamp int64) *Job {
+		job := &Job{
+			Name:     "test_job",
+			SyncType: DBSync,
+			State:    JobRunning,
+			ISrc:     specer,
+			db:       db,
+			factory:  NewFactory(rpcFactory, nil, nil, nil),
+		}
+		job.Extra.StopAtCommitSeq = stopAtCommitSeq
+		job.Extra.StopAtTimestamp = stopAtTimestamp
+		job.progress = NewJobProgress(job.Name, DBSync, db)
+		job.progress.SyncState = DBIncrementalSync
+		job.progress.SubSyncState = Done
+		job.progress.PrevCommitSeq = 10
+		job.progress.CommitSeq = 10
+		return job
+	}
+
+	// the stop at commit seq has been synced
+	job := newJob(10, 0)
+	if err := job.incrementalSync(); err != nil || job.State != JobReachedTarget {
+		t.Fatalf("the job should reach the stop at commit seq, state: %s, err: %v", job.State, err)
+	}
+	job = newJob(11, 0)
+	if err := job.incrementalSync(); err != nil || job.State != JobRunning {
+		t.Fatalf("the job should wait for the stop at commit seq, state: %s, err: %v", job.State, err)
+	}
+
+	// no binlog after the stop at timestamp, the src time is compared
+	specer.timestamp = 1000
+	job = newJob(0, 1000)
+	if err := job.incrementalSync(); err != nil || job.State != JobRunning {
+		t.Fatalf("the job should wait until the src time is beyond the stop at timestamp, state: %s, err: %v", job.State, err)
+	}
+	specer.timestamp = 2000
+	if err := job.incrementalSync(); err != nil || job.State != JobReachedTarget {
+		t.Fatalf("the job should reach the stop at timestamp, state: %s, err: %v", job.State, err)
+	}
+}
@@ -18,6 +18,7 @@ package ccr_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/selectdb/ccr_syncer/pkg/ccr"
 )
@@ -56,3 +57,82 @@ func TestFilterStorageMediumFromCreateTableSql(t *testing.T) {
 		}
 	}
 }
+
+func TestTableRename(t *testing.T) {
+	rename := &ccr.TableRename{
+		Mapping: map[string]string{"orders": "orders_replica"},
+		Prefix:  "db1_",
+	}
+	if name := rename.DestName("orders"); name != "orders_replica" {
+		t.Errorf("expect orders_replica, but got %s", name)
+	}
+	if name := rename.DestName("users"); name != "db1_users" {
+		t.Errorf("expect db1_users, but got %s", name)
+	}
+	if name, ok := rename.SrcName("orders_replica"); !ok || name != "orders" {
+		t.Errorf("expect orders, but got %s", name)
+	}
+	if name, ok := rename.SrcName("db1_users"); !ok || name != "users" {
+		t.Errorf("expect users, but got %s", name)
+	}
+	if _, ok := rename.SrcName("db1_orders"); ok {
+		t.Errorf("db1_orders should not be renamed from any src table")
+	}
+	if _, ok := rename.SrcName("db2_users"); ok {
+		t.Errorf("db2_users should not be renamed from any src table")
+	}
+}
+
+func TestParseSyncerLabelDestDbId(t *testing.T) {
+	tests := []struct {
+		label    string
+		destDbId int64
+		ok       bool
+	}{
+		{"ccrj-1a2b:db_sync:10001:20001:35", 20001, true},
+		{"ccrj-ff:table_sync:10001_10010:20001_20010:35", 20001, true},
+		{"insert_7f8d1b2a", 0, false},
+		{"ccrj-ff:db_sync:10001:35", 0, false},
+		{"ccrj-ff:db_sync:10001:abc:35", 0, false},
+	}
+	for i, test := range tests {
+		destDbId, ok := ccr.ParseSyncerLabelDestDbId(test.label)
+		if ok != test.ok || destDbId != test.destDbId {
+			t.Errorf("test %d failed, label: %s, got: %d %v", i, test.label, destDbId, ok)
+		}
+	}
+}
+
+func TestSyncWindowContains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		window   ccr.SyncWindow
+		now      time.Time
+		contains bool
+	}{
+		{ccr.SyncWindow{Start: "01:00", End: "05:30"}, at(1, 0), true},
+		{ccr.SyncWindow{Start: "01:00", End: "05:30"}, at(5, 29), true},
+		{ccr.SyncWindow{Start: "01:00", End: "05:30"}, at(5, 30), false},
+		{ccr.SyncWindow{Start: "01:00", End: "05:30"}, at(0, 59), false},
+		{ccr.SyncWindow{Start: "22:00", End: "06:00"}, at(23, 0), true},
+		{ccr.SyncWindow{Start: "22:00", End: "06:00"}, at(3, 0), true},
+		{ccr.SyncWindow{Start: "22:00", End: "06:00"}, at(12, 0), false},
+		{ccr.SyncWindow{Start: "25:00", End: "06:00"}, at(3, 0), false},
+	}
+	for i, test := range tests {
+		if contains := test.window.Contains(test.now); contains != test.contains {
+			t.Errorf("test %d failed, window: %v, now: %s, got: %v", i, test.window, test.now, contains)
+		}
+	}
+
+	schedule := &ccr.SyncSchedule{Windows: []ccr.SyncWindow{{Start: "12:00", End: "06:00"}}}
+	if err := schedule.Valid(); err != nil {
+		t.Errorf("valid schedule failed: %v", err)
+	}
+	schedule.Windows = append(schedule.Windows, ccr.SyncWindow{Start: "6:00", End: "6:60"})
+	if err := schedule.Valid(); err == nil {
+		t.Errorf("invalid schedule is accepted: %v", schedule)
+	}
+}
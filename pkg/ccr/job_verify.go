@@ -0,0 +1,478 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/ccr/record"
+	"github.com/selectdb/ccr_syncer/pkg/utils"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	tstatus "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/status"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The repair states of a divergent table.
+const (
+	VerifyRepairScheduled = "scheduled"
+	VerifyRepairSkipped   = "skipped"
+)
+
+// VerifyOptions controls what the verifier compares.
+type VerifyOptions struct {
+	// The src tables to verify, all synced tables by default.
+	Tables []string `json:"tables,omitempty"`
+	// Compare the `SELECT COUNT(*)` of the tables.
+	RowCount bool `json:"row_count,omitempty"`
+	// Compare the row count and checksum of each partition, it scans all the data.
+	Checksum bool `json:"checksum,omitempty"`
+	// Schedule a partial snapshot for the divergent table.
+	Repair bool `json:"repair,omitempty"`
+}
+
+// The verify result of a synced table.
+type TableVerifyResult struct {
+	SrcTable   string   `json:"src_table,omitempty"`
+	DestTable  string   `json:"dest_table,omitempty"`
+	Mismatches []string `json:"mismatches,omitempty"`
+	// The repair state and the reason if it is skipped.
+	Repair string `json:"repair,omitempty"`
+
+	srcTableId  int64
+	destTableId int64
+}
+
+func (r *TableVerifyResult) IsConsistent() bool {
+	return len(r.Mismatches) == 0
+}
+
+func (r *TableVerifyResult) addMismatch(format string, args ...any) {
+	r.Mismatches = append(r.Mismatches, fmt.Sprintf(format, args...))
+}
+
+// VerifyReport is the verify result of a job, the fanout dests are verified separately.
+//
+// The partition versions are only comparable when the job has caught up with the src cluster,
+// verify it when the lag is zero or the src tables are not written.
+type VerifyReport struct {
+	Name      string `json:"name"`
+	CommitSeq int64  `json:"commit_seq"`
+	// The binlogs of src after the commit seq when the verify starts, the versions and the row
+	// counts of the tables written by them are divergent, the divergent tables are only repaired
+	// when it is zero.
+	Lag        int64                `json:"lag"`
+	Consistent bool                 `json:"consistent"`
+	Tables     []*TableVerifyResult `json:"tables"`
+	Dests      []*VerifyReport      `json:"dests,omitempty"`
+}
+
+// Verify compares the tables, partitions, indexes and visible versions of the src and dest
+// clusters, and the row count and checksums if required.
+func (j *Job) Verify(options *VerifyOptions) (*VerifyReport, error) {
+	if options == nil {
+		options = &VerifyOptions{}
+	}
+
+	j.lock.Lock()
+	if j.progress == nil {
+		j.lock.Unlock()
+		return nil, xerror.Errorf(xerror.Normal, "job %s is not running", j.Name)
+	}
+	commitSeq := j.progress.CommitSeq
+	isDone := j.progress.IsDone()
+	j.lock.Unlock()
+
+	lag, err := j.getVerifyBinlogLag(commitSeq)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("verify job %s, commit seq: %d, lag: %d, options: %+v", j.Name, commitSeq, lag, options)
+
+	// Use the new metas and specers, since the job is running in another goroutine.
+	srcMeta := j.factory.NewMeta(&j.Src)
+	destMeta := j.factory.NewMeta(&j.Dest)
+	srcTables, err := verifyTableIds(srcMeta)
+	if err != nil {
+		return nil, err
+	}
+	destTables, err := verifyTableIds(destMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := j.verifySrcTables(options, srcTables)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*TableVerifyResult, 0, len(tables))
+	srcTableIds := make([]int64, 0, len(tables))
+	destTableIds := make([]int64, 0, len(tables))
+	destTableNames := make(map[string]struct{})
+	for _, table := range tables {
+		result := &TableVerifyResult{
+			SrcTable:  table,
+			DestTable: j.planDestTableName(table),
+		}
+		results = append(results, result)
+		destTableNames[result.DestTable] = struct{}{}
+
+		srcTableId, ok := srcTables[result.SrcTable]
+		if !ok {
+			result.addMismatch("table %s not found in src", result.SrcTable)
+			continue
+		}
+		result.srcTableId = srcTableId
+		srcTableIds = append(srcTableIds, srcTableId)
+		if destTableId, ok := destTables[result.DestTable]; !ok {
+			result.addMismatch("table %s not found in dest", result.DestTable)
+		} else {
+			result.destTableId = destTableId
+			destTableIds = append(destTableIds, destTableId)
+		}
+	}
+
+	// The extra tables in dest are only reported when the job syncs the whole db.
+	if j.SyncType == DBSync && !j.hasTableSelector() && len(options.Tables) == 0 {
+		for _, table := range sortedKeys(destTables) {
+			if _, ok := destTableNames[table]; ok || isVerifyIgnoredTable(table) {
+				continue
+			}
+			results = append(results, &TableVerifyResult{
+				DestTable:  table,
+				Mismatches: []string{fmt.Sprintf("table %s not found in src", table)},
+			})
+		}
+	}
+
+	srcThriftMeta, err := j.factory.NewThriftMeta(&j.Src, j.factory, srcTableIds)
+	if err != nil {
+		return nil, err
+	}
+	destThriftMeta, err := j.factory.NewThriftMeta(&j.Dest, j.factory, destTableIds)
+	if err != nil {
+		return nil, err
+	}
+
+	srcSpecer := j.factory.NewSpecer(&j.Src)
+	destSpecer := j.factory.NewSpecer(&j.Dest)
+	for _, result := range results {
+		if !result.IsConsistent() {
+			continue
+		}
+
+		srcPartitions, err := srcThriftMeta.GetPartitionRangeMap(result.srcTableId)
+		if err != nil {
+			return nil, err
+		}
+		destPartitions, err := destThriftMeta.GetPartitionRangeMap(result.destTableId)
+		if err != nil {
+			return nil, err
+		}
+		comparePartitions(result, srcPartitions, destPartitions)
+
+		if options.RowCount {
+			if err := compareRowCount(result, srcSpecer, destSpecer); err != nil {
+				return nil, err
+			}
+		}
+		if options.Checksum {
+			if err := comparePartitionChecksums(result, srcSpecer, destSpecer, srcPartitions, destPartitions); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	report := &VerifyReport{
+		Name:       j.Name,
+		CommitSeq:  commitSeq,
+		Lag:        lag,
+		Consistent: true,
+		Tables:     results,
+	}
+	for _, result := range results {
+		if !result.IsConsistent() {
+			report.Consistent = false
+			log.Warnf("verify job %s, table %s is divergent from %s, mismatches: %v",
+				j.Name, result.SrcTable, result.DestTable, result.Mismatches)
+		}
+	}
+
+	if options.Repair && !report.Consistent {
+		// The dest is lagging rather than divergent, if the src is written after the commit seq.
+		reason := ""
+		if !isDone {
+			reason = fmt.Sprintf("the binlog %d is being synced", commitSeq)
+		} else if lag == 0 {
+			// the src might be written during the verify
+			if lag, err = j.getVerifyBinlogLag(commitSeq); err != nil {
+				reason = err.Error()
+			}
+		}
+		if reason == "" && lag != 0 {
+			reason = fmt.Sprintf("the job lags %d binlogs behind commit seq %d, verify again when the lag is 0", lag, commitSeq)
+		}
+
+		if reason != "" {
+			log.Warnf("verify job %s, skip repairing the divergent tables: %s", j.Name, reason)
+			for _, result := range results {
+				if !result.IsConsistent() {
+					result.Repair = fmt.Sprintf("%s: %s", VerifyRepairSkipped, reason)
+				}
+			}
+		} else {
+			j.repairDivergentTables(results, commitSeq)
+		}
+	}
+
+	for _, fanout := range j.fanouts {
+		destReport, err := fanout.Verify(options)
+		if err != nil {
+			return nil, err
+		}
+		report.Dests = append(report.Dests, destReport)
+	}
+	return report, nil
+}
+
+// Get the number of the src binlogs after the commit seq.
+func (j *Job) getVerifyBinlogLag(commitSeq int64) (int64, error) {
+	srcRpc, err := j.factory.NewFeRpc(&j.Src)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := srcRpc.GetBinlogLag(&j.Src, commitSeq)
+	if err != nil {
+		return 0, err
+	}
+	if status := resp.GetStatus(); status != nil && status.StatusCode != tstatus.TStatusCode_OK {
+		return 0, xerror.Errorf(xerror.Normal, "get binlog lag failed, status: %v, msg: %s",
+			status.StatusCode, utils.FirstOr(status.GetErrorMsgs(), ""))
+	}
+	return resp.GetLag(), nil
+}
+
+// The src tables to verify, the tables of the options must be synced by the job.
+func (j *Job) verifySrcTables(options *VerifyOptions, srcTables map[string]int64) ([]string, error) {
+	var tables []string
+	switch {
+	case j.SyncType == TableSync:
+		tables = []string{j.Src.Table}
+	case j.isMultiTableSync():
+		for _, table := range j.Extra.Tables {
+			if j.isTableSelected(table) {
+				tables = append(tables, table)
+			}
+		}
+	default:
+		for _, table := range sortedKeys(srcTables) {
+			if j.isTableSelected(table) {
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	if len(options.Tables) == 0 {
+		return tables, nil
+	}
+
+	synced := make(map[string]struct{}, len(tables))
+	for _, table := range tables {
+		synced[table] = struct{}{}
+	}
+	for _, table := range options.Tables {
+		if _, ok := synced[table]; !ok {
+			return nil, xerror.Errorf(xerror.Normal, "table %s is not synced by job %s", table, j.Name)
+		}
+	}
+	return options.Tables, nil
+}
+
+// Get the olap tables of the db, table name -> table id.
+func verifyTableIds(meta Metaer) (map[string]int64, error) {
+	tables, err := meta.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	tableIds := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		if table.Type == record.TableTypeOlap {
+			tableIds[table.Name] = table.Id
+		}
+	}
+	return tableIds, nil
+}
+
+// The archives of the soft delete and the aliases of the partial sync are not synced tables,
+// both of them are prefixed by __ccr_.
+func isVerifyIgnoredTable(table string) bool {
+	return strings.HasPrefix(table, "__ccr_")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Compare the partition sets, the rollup sets and the visible versions of the partitions.
+// The partitions are matched by the range, since the names might be different after rename.
+func comparePartitions(result *TableVerifyResult, srcPartitions, destPartitions map[string]*PartitionMeta) {
+	srcIndexes := make(map[string]struct{})
+	destIndexes := make(map[string]struct{})
+	for _, partitionRange := range sortedKeys(srcPartitions) {
+		srcPartition := srcPartitions[partitionRange]
+		collectRollups(srcIndexes, srcPartition)
+
+		destPartition, ok := destPartitions[partitionRange]
+		if !ok {
+			result.addMismatch("partition %s not found in dest, range: %s", srcPartition.Name, partitionRange)
+			continue
+		}
+		if srcPartition.VisibleVersion != destPartition.VisibleVersion {
+			result.addMismatch("partition %s visible version mismatch, src: %d, dest: %d",
+				srcPartition.Name, srcPartition.VisibleVersion, destPartition.VisibleVersion)
+		}
+	}
+	for _, partitionRange := range sortedKeys(destPartitions) {
+		destPartition := destPartitions[partitionRange]
+		collectRollups(destIndexes, destPartition)
+
+		if _, ok := srcPartitions[partitionRange]; !ok {
+			result.addMismatch("partition %s not found in src, range: %s", destPartition.Name, partitionRange)
+		}
+	}
+
+	for _, index := range sortedKeys(srcIndexes) {
+		if _, ok := destIndexes[index]; !ok {
+			result.addMismatch("index %s not found in dest", index)
+		}
+	}
+	for _, index := range sortedKeys(destIndexes) {
+		if _, ok := srcIndexes[index]; !ok {
+			result.addMismatch("index %s not found in src", index)
+		}
+	}
+}
+
+// Collect the names of the rollups, the base index is named by the table, which might be
+// renamed in dest.
+func collectRollups(indexes map[string]struct{}, partition *PartitionMeta) {
+	tableName := ""
+	if partition.TableMeta != nil {
+		tableName = partition.TableMeta.Name
+	}
+	for name, index := range partition.IndexNameMap {
+		if !index.IsBaseIndex && name != tableName {
+			indexes[name] = struct{}{}
+		}
+	}
+}
+
+func compareRowCount(result *TableVerifyResult, srcSpecer, destSpecer base.Specer) error {
+	srcCount, err := srcSpecer.CountRows(result.SrcTable, "")
+	if err != nil {
+		return err
+	}
+	destCount, err := destSpecer.CountRows(result.DestTable, "")
+	if err != nil {
+		return err
+	}
+	if srcCount != destCount {
+		result.addMismatch("row count mismatch, src: %d, dest: %d", srcCount, destCount)
+	}
+	return nil
+}
+
+func comparePartitionChecksums(result *TableVerifyResult, srcSpecer, destSpecer base.Specer,
+	srcPartitions, destPartitions map[string]*PartitionMeta) error {
+	for _, partitionRange := range sortedKeys(srcPartitions) {
+		srcPartition := srcPartitions[partitionRange]
+		destPartition, ok := destPartitions[partitionRange]
+		if !ok {
+			continue
+		}
+
+		srcChecksum, err := srcSpecer.GetPartitionChecksum(result.SrcTable, srcPartition.Name)
+		if err != nil {
+			return err
+		}
+		destChecksum, err := destSpecer.GetPartitionChecksum(result.DestTable, destPartition.Name)
+		if err != nil {
+			return err
+		}
+		if srcChecksum.RowCount != destChecksum.RowCount {
+			result.addMismatch("partition %s row count mismatch, src: %d, dest: %d",
+				srcPartition.Name, srcChecksum.RowCount, destChecksum.RowCount)
+		} else if srcChecksum.Checksum != destChecksum.Checksum {
+			result.addMismatch("partition %s checksum mismatch, src: %d, dest: %d",
+				srcPartition.Name, srcChecksum.Checksum, destChecksum.Checksum)
+		}
+	}
+	return nil
+}
+
+// Schedule a partial snapshot for the divergent tables, which are compared at the commit seq.
+// Only one partial snapshot could be in progress, the others are skipped and should be repaired
+// by the next verify.
+func (j *Job) repairDivergentTables(results []*TableVerifyResult, commitSeq int64) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	moved := j.progress.CommitSeq != commitSeq
+	scheduled := false
+	for _, result := range results {
+		if result.IsConsistent() {
+			continue
+		}
+
+		var reason string
+		switch {
+		case result.srcTableId == 0:
+			reason = "the src table is not found"
+		case moved:
+			reason = fmt.Sprintf("the job has synced beyond commit seq %d during the verify", commitSeq)
+		case scheduled:
+			reason = "another partial snapshot is scheduled, verify again after it is done"
+		case !j.isIncrementalSync():
+			reason = fmt.Sprintf("the job is in %s", j.progress.SyncState)
+		}
+		if reason != "" {
+			result.Repair = fmt.Sprintf("%s: %s", VerifyRepairSkipped, reason)
+			continue
+		}
+
+		// replace the dest table if it exists, like the partial sync of the create table binlog.
+		replace := result.destTableId != 0
+		if err := j.newPartialSnapshot(result.srcTableId, result.SrcTable, nil, replace); err != nil {
+			result.Repair = fmt.Sprintf("%s: %s", VerifyRepairSkipped, err.Error())
+			continue
+		}
+		log.Infof("verify job %s, schedule a partial snapshot to repair table %s", j.Name, result.SrcTable)
+		result.Repair = VerifyRepairScheduled
+		scheduled = true
+	}
+}
@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+func newVerifyPartition(table *TableMeta, name, partitionRange string, version int64, indexes ...string) *PartitionMeta {
+	partition := &PartitionMeta{
+		TableMeta:      table,
+		Name:           name,
+		Range:          partitionRange,
+		VisibleVersion: version,
+		IndexNameMap:   make(map[string]*IndexMeta),
+	}
+	partition.IndexNameMap[table.Name] = &IndexMeta{Name: table.Name, IsBaseIndex: true}
+	for _, index := range indexes {
+		partition.IndexNameMap[index] = &IndexMeta{Name: index}
+	}
+	return partition
+}
+
+func TestComparePartitions(t *testing.T) {
+	srcTable := &TableMeta{Name: "orders"}
+	destTable := &TableMeta{Name: "orders_bak"} // renamed in dest
+
+	srcPartitions := map[string]*PartitionMeta{
+		"[1, 2)": newVerifyPartition(srcTable, "p1", "[1, 2)", 3, "r1"),
+		"[2, 3)": newVerifyPartition(srcTable, "p2", "[2, 3)", 5, "r1"),
+	}
+	destPartitions := map[string]*PartitionMeta{
+		"[1, 2)": newVerifyPartition(destTable, "p1", "[1, 2)", 3, "r1"),
+		"[2, 3)": newVerifyPartition(destTable, "p2", "[2, 3)", 5, "r1"),
+	}
+
+	result := &TableVerifyResult{SrcTable: "orders", DestTable: "orders_bak"}
+	comparePartitions(result, srcPartitions, destPartitions)
+	if !result.IsConsistent() {
+		t.Fatalf("expect consistent, mismatches: %v", result.Mismatches)
+	}
+
+	destPartitions["[2, 3)"] = newVerifyPartition(destTable, "p2", "[2, 3)", 4)
+	destPartitions["[3, 4)"] = newVerifyPartition(destTable, "p3", "[3, 4)", 2, "r1")
+	delete(destPartitions, "[1, 2)")
+
+	result = &TableVerifyResult{SrcTable: "orders", DestTable: "orders_bak"}
+	comparePartitions(result, srcPartitions, destPartitions)
+	expected := []string{
+		"partition p1 not found in dest, range: [1, 2)",
+		"partition p2 visible version mismatch, src: 5, dest: 4",
+		"partition p3 not found in src, range: [3, 4)",
+	}
+	if len(result.Mismatches) != len(expected) {
+		t.Fatalf("unexpected mismatches: %v", result.Mismatches)
+	}
+	for i, mismatch := range expected {
+		if result.Mismatches[i] != mismatch {
+			t.Errorf("mismatch %d: expect %q, got %q", i, mismatch, result.Mismatches[i])
+		}
+	}
+}
+
+func TestRepairDivergentTables(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db := test_util.NewMockDB(ctrl)
+	db.EXPECT().UpdateProgress(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	job := &Job{Name: "test_job", SyncType: DBSync}
+	job.progress = NewJobProgress(job.Name, DBSync, db)
+	job.progress.SyncState = DBIncrementalSync
+	job.progress.CommitSeq = 10
+
+	newResults := func() []*TableVerifyResult {
+		return []*TableVerifyResult{
+			{SrcTable: "t1", DestTable: "t1", srcTableId: 1, destTableId: 101},
+			{SrcTable: "t2", DestTable: "t2", srcTableId: 2, destTableId: 102, Mismatches: []string{"row count mismatch"}},
+			{SrcTable: "t3", DestTable: "t3", srcTableId: 3, Mismatches: []string{"table t3 not found in dest"}},
+		}
+	}
+
+	// the job has synced new binlogs after the compared commit seq
+	results := newResults()
+	job.repairDivergentTables(results, 9)
+	for _, result := range results[1:] {
+		if !strings.HasPrefix(result.Repair, VerifyRepairSkipped) {
+			t.Errorf("the repair of table %s should be skipped, got %q", result.SrcTable, result.Repair)
+		}
+	}
+	if job.progress.SyncState != DBIncrementalSync {
+		t.Fatalf("no partial snapshot should be scheduled, state: %s", job.progress.SyncState)
+	}
+
+	results = newResults()
+	job.repairDivergentTables(results, 10)
+	if results[0].Repair != "" || results[1].Repair != VerifyRepairScheduled ||
+		!strings.HasPrefix(results[2].Repair, VerifyRepairSkipped) {
+		t.Errorf("only the first divergent table should be repaired, got %q, %q, %q",
+			results[0].Repair, results[1].Repair, results[2].Repair)
+	}
+	if job.progress.SyncState != DBPartialSync || job.progress.PartialSyncData.Table != "t2" {
+		t.Fatalf("the partial snapshot of t2 should be scheduled, state: %s", job.progress.SyncState)
+	}
+}
@@ -503,6 +503,7 @@ func (m *Meta) UpdateBackends() error {
 		}
 
 		var backend base.Backend
+		backend.ThriftTLS = m.ThriftTLS
 		backend.Id, err = rowParser.GetInt64("BackendId")
 		if err != nil {
 			return xerror.Wrapf(err, xerror.Normal, query)
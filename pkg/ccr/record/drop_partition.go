@@ -18,10 +18,14 @@ package record
 
 import (
 	"encoding/json"
+	"regexp"
+	"strings"
 
 	"github.com/selectdb/ccr_syncer/pkg/xerror"
 )
 
+var dropPartitionNameRegex = regexp.MustCompile("(?i)DROP\\s+PARTITION\\s+(?:IF\\s+EXISTS\\s+)?(`[^`]+`|[^`\\s]+)")
+
 type DropPartition struct {
 	TableId int64  `json:"tableId"`
 	Sql     string `json:"sql"`
@@ -46,3 +50,12 @@ func NewDropPartitionFromJson(data string) (*DropPartition, error) {
 
 	return &dropPartition, nil
 }
+
+// GetPartitionName returns the name of the dropped partition, parsed from the sql.
+func (d *DropPartition) GetPartitionName() string {
+	matches := dropPartitionNameRegex.FindStringSubmatch(d.Sql)
+	if len(matches) < 2 {
+		return ""
+	}
+	return strings.Trim(matches[1], "`")
+}
@@ -0,0 +1,367 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/kafka"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// The types of the sinks.
+const (
+	SinkTypeFile    = "file"
+	SinkTypeWebhook = "webhook"
+	SinkTypeKafka   = "kafka"
+
+	defaultSinkTimeout = 10 * time.Second
+)
+
+// The policies when a sink fails to send the events.
+const (
+	// Retry the events until they are sent, the replication is blocked meanwhile.
+	SinkOnFailureBlock = "block"
+	// Drop the events and go on, the events are lost for the sink.
+	SinkOnFailureDrop = "drop"
+)
+
+// Sink receives the binlog events handled by a job, in the order of the commit seq.
+//
+// The delivery is at-least-once: the events are sent again if the Send failed or the syncer
+// restarted before the offset of the sink was persisted.
+type Sink interface {
+	Send(events []*BinlogEvent) error
+	Close() error
+}
+
+// SinkConfig is the config of a sink of a job, the name identifies the offset of the sink.
+type SinkConfig struct {
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	File    *FileSink    `json:"file,omitempty"`
+	Webhook *WebhookSink `json:"webhook,omitempty"`
+	Kafka   *KafkaSink   `json:"kafka,omitempty"`
+
+	// The policy when the sink fails, SinkOnFailureBlock by default.
+	OnFailure string `json:"on_failure,omitempty"`
+	// The max seconds a failing sink blocks the replication, then the events are dropped.
+	// Zero means no limit, only for the SinkOnFailureBlock.
+	MaxBlockSeconds int `json:"max_block_seconds,omitempty"`
+}
+
+func (c *SinkConfig) Valid() error {
+	if c.Name == "" {
+		return xerror.New(xerror.Normal, "the name of sink is empty")
+	}
+	switch c.OnFailure {
+	case "", SinkOnFailureBlock, SinkOnFailureDrop:
+	default:
+		return xerror.Errorf(xerror.Normal, "unknown on_failure %s of sink %s", c.OnFailure, c.Name)
+	}
+	if c.MaxBlockSeconds < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid max_block_seconds %d of sink %s", c.MaxBlockSeconds, c.Name)
+	}
+
+	switch c.Type {
+	case SinkTypeFile:
+		if c.File == nil {
+			return xerror.Errorf(xerror.Normal, "the file of sink %s is empty", c.Name)
+		}
+		return c.File.Valid()
+	case SinkTypeWebhook:
+		if c.Webhook == nil {
+			return xerror.Errorf(xerror.Normal, "the webhook of sink %s is empty", c.Name)
+		}
+		return c.Webhook.Valid()
+	case SinkTypeKafka:
+		if c.Kafka == nil {
+			return xerror.Errorf(xerror.Normal, "the kafka of sink %s is empty", c.Name)
+		}
+		return c.Kafka.Valid()
+	default:
+		return xerror.Errorf(xerror.Normal, "unknown type %s of sink %s", c.Type, c.Name)
+	}
+}
+
+func (c *SinkConfig) newSink(jobName string) (Sink, error) {
+	switch c.Type {
+	case SinkTypeFile:
+		return c.File.newSink(jobName), nil
+	case SinkTypeWebhook:
+		return c.Webhook.newSink(), nil
+	case SinkTypeKafka:
+		return c.Kafka.newSink(jobName)
+	default:
+		return nil, xerror.Errorf(xerror.Normal, "unknown type %s of sink %s", c.Type, c.Name)
+	}
+}
+
+func sinkTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultSinkTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// FileSink writes the handled binlogs of a job into the rotating files <dir>/<job name>.jsonl,
+// one json line for each binlog.
+type FileSink struct {
+	Dir string `json:"dir"`
+	// The max size in megabytes of a file before it is rotated, 100 by default.
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+	// The max number of the rotated files to retain, zero means retain all.
+	MaxBackups int `json:"max_backups,omitempty"`
+	// The max days to retain the rotated files, zero means retain all.
+	MaxAgeDays int  `json:"max_age_days,omitempty"`
+	Compress   bool `json:"compress,omitempty"`
+}
+
+func (s *FileSink) Valid() error {
+	if s == nil {
+		return nil
+	}
+	if s.Dir == "" {
+		return xerror.New(xerror.Normal, "the dir of file sink is empty")
+	}
+	if s.MaxSizeMB < 0 || s.MaxBackups < 0 || s.MaxAgeDays < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid file sink, max size: %d, max backups: %d, max age: %d",
+			s.MaxSizeMB, s.MaxBackups, s.MaxAgeDays)
+	}
+	return nil
+}
+
+func (s *FileSink) newSink(jobName string) Sink {
+	return &fileSink{
+		writer: &lumberjack.Logger{
+			Filename:   filepath.Join(s.Dir, jobName+".jsonl"),
+			MaxSize:    s.MaxSizeMB,
+			MaxAge:     s.MaxAgeDays,
+			MaxBackups: s.MaxBackups,
+			LocalTime:  true,
+			Compress:   s.Compress,
+		},
+	}
+}
+
+type fileSink struct {
+	writer io.WriteCloser
+}
+
+func (s *fileSink) Send(events []*BinlogEvent) error {
+	var buffer bytes.Buffer
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return xerror.Wrapf(err, xerror.Normal, "marshal binlog event %d failed", event.CommitSeq)
+		}
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+	}
+	if _, err := s.writer.Write(buffer.Bytes()); err != nil {
+		return xerror.Wrap(err, xerror.Normal, "write binlog events to file failed")
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	return s.writer.Close()
+}
+
+// WebhookSink posts the handled binlogs to the url, as a json array of the events.
+//
+// The values of the headers might carry the credentials, so they are encrypted at rest like the
+// passwords of the specs.
+type WebhookSink struct {
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+
+	redacted    bool
+	staleSecret bool
+}
+
+// The alias without the json methods of WebhookSink.
+type webhookSinkJson WebhookSink
+
+// MarshalJSON encrypts the values of the headers if the keyring is configured, or redacts them
+// if the sink is redacted.
+func (s WebhookSink) MarshalJSON() ([]byte, error) {
+	sink := webhookSinkJson(s)
+	if len(s.Headers) > 0 {
+		sink.Headers = make(map[string]string, len(s.Headers))
+		for key, value := range s.Headers {
+			if s.redacted {
+				sink.Headers[key] = base.RedactedSecret
+				continue
+			}
+			encrypted, err := base.EncryptSecret(value)
+			if err != nil {
+				return nil, err
+			}
+			sink.Headers[key] = encrypted
+		}
+	}
+	return json.Marshal(sink)
+}
+
+// UnmarshalJSON decrypts the values of the headers, the plaintext values are accepted too.
+func (s *WebhookSink) UnmarshalJSON(data []byte) error {
+	var sink webhookSinkJson
+	if err := json.Unmarshal(data, &sink); err != nil {
+		return err
+	}
+	*s = WebhookSink(sink)
+
+	for key, value := range s.Headers {
+		decrypted, stale, err := base.DecryptSecret(value)
+		if err != nil {
+			return xerror.Wrapf(err, xerror.Normal, "decrypt the header %s of webhook sink failed", key)
+		}
+		s.Headers[key] = decrypted
+		s.staleSecret = s.staleSecret || stale
+	}
+	return nil
+}
+
+// Whether any header is stored in plaintext or encrypted by a non-primary key.
+func (s *WebhookSink) IsSecretStale() bool {
+	return s.staleSecret
+}
+
+// Redact hides the values of the headers, for the responses and the logs.
+func (s *WebhookSink) Redact() {
+	s.redacted = true
+}
+
+func (s *WebhookSink) Valid() error {
+	if s.URL == "" {
+		return xerror.New(xerror.Normal, "the url of webhook sink is empty")
+	}
+	return nil
+}
+
+func (s *WebhookSink) newSink() Sink {
+	return &webhookSink{
+		config: s,
+		client: &http.Client{Timeout: sinkTimeout(s.TimeoutSeconds)},
+	}
+}
+
+type webhookSink struct {
+	config *WebhookSink
+	client *http.Client
+}
+
+func (s *webhookSink) Send(events []*BinlogEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return xerror.Wrap(err, xerror.Normal, "marshal binlog events failed")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "new webhook request failed, url: %s", s.config.URL)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return xerror.Wrapf(err, xerror.Normal, "post binlog events to %s failed", s.config.URL)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return xerror.Errorf(xerror.Normal, "post binlog events to %s failed, status: %s", s.config.URL, resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// KafkaSink produces the handled binlogs to a partition of the topic, the key of the messages
+// is the job name and the value is the json of the event.
+//
+// The producer is a minimal client of the kafka protocol without the metadata requests, TLS,
+// SASL or compression, so it only works against a plaintext broker which is the leader of the
+// partition, such as a single-broker cluster. Use the webhook sink with a full-featured producer
+// for the other clusters.
+type KafkaSink struct {
+	Brokers        []string `json:"brokers"`
+	Topic          string   `json:"topic"`
+	Partition      int32    `json:"partition,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+}
+
+func (s *KafkaSink) Valid() error {
+	if len(s.Brokers) == 0 || s.Topic == "" {
+		return xerror.New(xerror.Normal, "the brokers and topic of kafka sink are required")
+	}
+	if s.Partition < 0 {
+		return xerror.Errorf(xerror.Normal, "invalid partition %d of kafka sink", s.Partition)
+	}
+	return nil
+}
+
+func (s *KafkaSink) newSink(jobName string) (Sink, error) {
+	producer, err := kafka.NewProducer(s.Brokers, "ccr_syncer", sinkTimeout(s.TimeoutSeconds))
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{config: s, producer: producer, key: []byte(jobName)}, nil
+}
+
+type kafkaSink struct {
+	config   *KafkaSink
+	producer *kafka.Producer
+	key      []byte
+}
+
+func (s *kafkaSink) Send(events []*BinlogEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return xerror.Wrapf(err, xerror.Normal, "marshal binlog event %d failed", event.CommitSeq)
+		}
+		messages = append(messages, kafka.Message{
+			Key:       s.key,
+			Value:     data,
+			Timestamp: time.UnixMilli(event.AppliedAt),
+		})
+	}
+	return s.producer.Produce(s.config.Topic, s.config.Partition, messages)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
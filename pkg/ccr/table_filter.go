@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+)
+
+// The pattern with this prefix is a regular expression, otherwise it is a glob pattern.
+const TableFilterRegexPrefix = "regex:"
+
+type tableMatcher func(table string) bool
+
+// TableFilter selects the source tables of a db sync job by name.
+//
+// A table is synced if it matches any of the Include patterns (or Include is empty),
+// and it doesn't match any of the Exclude patterns.
+type TableFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+
+	includeMatchers []tableMatcher `json:"-"`
+	excludeMatchers []tableMatcher `json:"-"`
+}
+
+func NewTableFilter(include, exclude []string) (*TableFilter, error) {
+	filter := &TableFilter{
+		Include: include,
+		Exclude: exclude,
+	}
+	if err := filter.compile(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func newTableMatcher(pattern string) (tableMatcher, error) {
+	if strings.HasPrefix(pattern, TableFilterRegexPrefix) {
+		expr := strings.TrimPrefix(pattern, TableFilterRegexPrefix)
+		// match the whole table name, as the glob pattern does
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, xerror.Wrapf(err, xerror.Normal, "invalid table filter regex %s", expr)
+		}
+		return re.MatchString, nil
+	}
+
+	// check the syntax of the glob pattern
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "invalid table filter pattern %s", pattern)
+	}
+	return func(table string) bool {
+		matched, _ := path.Match(pattern, table)
+		return matched
+	}, nil
+}
+
+func (f *TableFilter) compile() error {
+	f.includeMatchers = make([]tableMatcher, 0, len(f.Include))
+	for _, pattern := range f.Include {
+		matcher, err := newTableMatcher(pattern)
+		if err != nil {
+			return err
+		}
+		f.includeMatchers = append(f.includeMatchers, matcher)
+	}
+
+	f.excludeMatchers = make([]tableMatcher, 0, len(f.Exclude))
+	for _, pattern := range f.Exclude {
+		matcher, err := newTableMatcher(pattern)
+		if err != nil {
+			return err
+		}
+		f.excludeMatchers = append(f.excludeMatchers, matcher)
+	}
+	return nil
+}
+
+func (f *TableFilter) IsEmpty() bool {
+	return f == nil || (len(f.Include) == 0 && len(f.Exclude) == 0)
+}
+
+// Match returns whether the table should be synced, a nil or empty filter matches all tables.
+func (f *TableFilter) Match(table string) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	if len(f.includeMatchers) > 0 {
+		included := false
+		for _, matcher := range f.includeMatchers {
+			if matcher(table) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, matcher := range f.excludeMatchers {
+		if matcher(table) {
+			return false
+		}
+	}
+	return true
+}
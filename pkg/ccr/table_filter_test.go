@@ -0,0 +1,63 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr_test
+
+import (
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr"
+)
+
+func TestTableFilterMatch(t *testing.T) {
+	type TestCase struct {
+		include, exclude []string
+		table            string
+		expect           bool
+	}
+	tests := []TestCase{
+		{table: "tbl", expect: true},
+		{exclude: []string{"tmp_*"}, table: "tmp_tbl", expect: false},
+		{exclude: []string{"tmp_*"}, table: "tbl_tmp", expect: true},
+		{include: []string{"order_*", "user"}, table: "user", expect: true},
+		{include: []string{"order_*", "user"}, table: "users", expect: false},
+		{include: []string{"order_*"}, exclude: []string{"order_tmp"}, table: "order_tmp", expect: false},
+		{include: []string{"regex:^user_[0-9]+$"}, table: "user_01", expect: true},
+		{include: []string{"regex:^user_[0-9]+$"}, table: "user_x", expect: false},
+		// the regex matches the whole table name
+		{include: []string{"regex:user_[0-9]+"}, table: "user_01", expect: true},
+		{include: []string{"regex:user_[0-9]+"}, table: "tmp_user_01", expect: false},
+		{include: []string{"regex:user_[0-9]+"}, table: "user_01_bak", expect: false},
+		{exclude: []string{"regex:tmp|bak"}, table: "orders_bak", expect: true},
+		{exclude: []string{"regex:tmp|bak"}, table: "bak", expect: false},
+	}
+	for i, test := range tests {
+		filter, err := ccr.NewTableFilter(test.include, test.exclude)
+		if err != nil {
+			t.Fatalf("test %d failed, new table filter: %v", i, err)
+		}
+		if filter.Match(test.table) != test.expect {
+			t.Errorf("test %d failed, table %s, expect %t", i, test.table, test.expect)
+		}
+	}
+
+	if _, err := ccr.NewTableFilter([]string{"regex:("}, nil); err == nil {
+		t.Errorf("invalid regex should be rejected")
+	}
+	if _, err := ccr.NewTableFilter(nil, []string{"[a-"}); err == nil {
+		t.Errorf("invalid glob should be rejected")
+	}
+}
@@ -0,0 +1,90 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package ccr
+
+import (
+	"strings"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+)
+
+// TableRename maps the src table names to the dest table names of a db sync job.
+//
+// The table in Mapping is renamed to the mapped name, otherwise the Prefix and Suffix
+// are added to the src table name.
+type TableRename struct {
+	Mapping map[string]string `json:"mapping,omitempty"`
+	Prefix  string            `json:"prefix,omitempty"`
+	Suffix  string            `json:"suffix,omitempty"`
+}
+
+func (r *TableRename) IsEmpty() bool {
+	return r == nil || (len(r.Mapping) == 0 && r.Prefix == "" && r.Suffix == "")
+}
+
+func (r *TableRename) Valid() error {
+	if r.IsEmpty() {
+		return nil
+	}
+
+	destNames := make(map[string]string)
+	for src, dest := range r.Mapping {
+		if src == "" || dest == "" {
+			return xerror.Errorf(xerror.Normal, "invalid table rename mapping, %s => %s", src, dest)
+		}
+		if other, ok := destNames[dest]; ok {
+			return xerror.Errorf(xerror.Normal, "table %s and %s are renamed to the same table %s", src, other, dest)
+		}
+		destNames[dest] = src
+	}
+	return nil
+}
+
+// DestName returns the dest table name of the src table.
+func (r *TableRename) DestName(srcTable string) string {
+	if r.IsEmpty() {
+		return srcTable
+	}
+	if name, ok := r.Mapping[srcTable]; ok {
+		return name
+	}
+	return r.Prefix + srcTable + r.Suffix
+}
+
+// SrcName returns the src table name of the dest table, false if the dest table is not
+// renamed from any src table.
+func (r *TableRename) SrcName(destTable string) (string, bool) {
+	if r.IsEmpty() {
+		return destTable, true
+	}
+	for src, dest := range r.Mapping {
+		if dest == destTable {
+			return src, true
+		}
+	}
+
+	if len(destTable) <= len(r.Prefix)+len(r.Suffix) ||
+		!strings.HasPrefix(destTable, r.Prefix) || !strings.HasSuffix(destTable, r.Suffix) {
+		return "", false
+	}
+	srcTable := destTable[len(r.Prefix) : len(destTable)-len(r.Suffix)]
+	if _, ok := r.Mapping[srcTable]; ok {
+		// the src table is renamed by the mapping.
+		return "", false
+	}
+	return srcTable, true
+}
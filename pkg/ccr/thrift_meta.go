@@ -66,6 +66,8 @@ func NewThriftMeta(spec *base.Spec, rpcFactory rpc.IRpcFactory, tableIds []int64
 			BePort:   uint16(backend.GetBePort()),
 			HttpPort: uint16(backend.GetHttpPort()),
 			BrpcPort: uint16(backend.GetBrpcPort()),
+
+			ThriftTLS: spec.ThriftTLS,
 		}
 		meta.Backends[backendMeta.Id] = backendMeta
 	}
@@ -0,0 +1,331 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+
+// Package kafka is a minimal producer of the kafka protocol, it only sends the records to a
+// specified partition of a topic, with the Produce API v3 and the record batch v2 format.
+//
+// It does not send the metadata requests, nor support TLS, SASL or compression, so it only works
+// against a plaintext broker which is the leader of the partition. The other brokers are tried in
+// turn if the connected one is not the leader, but the leader must be one of the brokers.
+package kafka
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	apiKeyProduce     int16 = 0
+	apiVersionProduce int16 = 3
+
+	// Wait for all in-sync replicas.
+	AcksAll int16 = -1
+
+	errNone                    int16 = 0
+	errNotLeaderForPartition   int16 = 6
+	errLeaderNotAvailable      int16 = 5
+	errUnknownTopicOrPartition int16 = 3
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type Message struct {
+	Key       []byte
+	Value     []byte
+	Timestamp time.Time
+}
+
+type Producer struct {
+	brokers  []string
+	clientId string
+	timeout  time.Duration
+
+	lock          sync.Mutex
+	conn          net.Conn
+	broker        int // the index of the connected broker
+	correlationId int32
+}
+
+func NewProducer(brokers []string, clientId string, timeout time.Duration) (*Producer, error) {
+	if len(brokers) == 0 {
+		return nil, xerror.New(xerror.Normal, "kafka brokers are empty")
+	}
+	return &Producer{
+		brokers:  brokers,
+		clientId: clientId,
+		timeout:  timeout,
+	}, nil
+}
+
+// Produce sends the messages to the partition of the topic and waits for the acks of all
+// in-sync replicas. If the connected broker is not the leader of the partition, the other
+// brokers are tried.
+func (p *Producer) Produce(topic string, partition int32, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	request := encodeProduceRequest(p.clientId, topic, partition, AcksAll, p.timeout, messages)
+	var lastErr error
+	for i := 0; i < len(p.brokers); i++ {
+		errorCode, err := p.roundTrip(request)
+		if err == nil && errorCode == errNone {
+			return nil
+		}
+
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = xerror.Errorf(xerror.Normal, "produce to %s-%d failed, error code: %d", topic, partition, errorCode)
+			if errorCode != errNotLeaderForPartition && errorCode != errLeaderNotAvailable &&
+				errorCode != errUnknownTopicOrPartition {
+				return lastErr
+			}
+		}
+		log.Warnf("produce to kafka broker %s failed, try the next broker, err: %v", p.brokers[p.broker], lastErr)
+		p.closeConn()
+		p.broker = (p.broker + 1) % len(p.brokers)
+	}
+	return lastErr
+}
+
+func (p *Producer) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closeConn()
+	return nil
+}
+
+func (p *Producer) closeConn() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// Send the request and read the error code of the partition in the response.
+func (p *Producer) roundTrip(request []byte) (int16, error) {
+	if p.conn == nil {
+		conn, err := net.DialTimeout("tcp", p.brokers[p.broker], p.timeout)
+		if err != nil {
+			return 0, xerror.Wrapf(err, xerror.Normal, "connect kafka broker %s failed", p.brokers[p.broker])
+		}
+		p.conn = conn
+	}
+
+	p.correlationId += 1
+	correlationId := p.correlationId
+	// the correlation id is after the size, api key and api version.
+	binary.BigEndian.PutUint32(request[8:12], uint32(correlationId))
+
+	if err := p.conn.SetDeadline(time.Now().Add(2 * p.timeout)); err != nil {
+		p.closeConn()
+		return 0, xerror.Wrap(err, xerror.Normal, "set deadline failed")
+	}
+	if _, err := p.conn.Write(request); err != nil {
+		p.closeConn()
+		return 0, xerror.Wrap(err, xerror.Normal, "write produce request failed")
+	}
+
+	response, err := readResponse(bufio.NewReader(p.conn))
+	if err != nil {
+		p.closeConn()
+		return 0, err
+	}
+	responseCorrelationId, errorCode, err := decodeProduceResponse(response)
+	if err != nil {
+		p.closeConn()
+		return 0, err
+	}
+	if responseCorrelationId != correlationId {
+		p.closeConn()
+		return 0, xerror.Errorf(xerror.Normal, "unexpected correlation id %d, expect %d", responseCorrelationId, correlationId)
+	}
+	return errorCode, nil
+}
+
+func readResponse(reader io.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "read response size failed")
+	}
+	if size < 4 {
+		return nil, xerror.Errorf(xerror.Normal, "invalid response size %d", size)
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(reader, response); err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "read response failed")
+	}
+	return response, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8)     { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16)   { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32)   { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64)   { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *encoder) varint(v int64)  { e.buf = binary.AppendVarint(e.buf, v) }
+func (e *encoder) string(v string) { e.int16(int16(len(v))); e.buf = append(e.buf, v...) }
+
+func (e *encoder) varBytes(v []byte) {
+	if v == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func encodeProduceRequest(clientId, topic string, partition int32, acks int16, timeout time.Duration, messages []Message) []byte {
+	e := &encoder{}
+	e.int32(0) // size, filled at last
+	e.int16(apiKeyProduce)
+	e.int16(apiVersionProduce)
+	e.int32(0) // correlation id, filled before sending
+	e.string(clientId)
+
+	e.int16(-1) // transactional id
+	e.int16(acks)
+	e.int32(int32(timeout.Milliseconds()))
+	e.int32(1) // topics
+	e.string(topic)
+	e.int32(1) // partitions
+	e.int32(partition)
+	batch := encodeRecordBatch(messages)
+	e.int32(int32(len(batch)))
+	e.buf = append(e.buf, batch...)
+
+	binary.BigEndian.PutUint32(e.buf[0:4], uint32(len(e.buf)-4))
+	return e.buf
+}
+
+// Encode the messages in the record batch v2 format (magic 2).
+func encodeRecordBatch(messages []Message) []byte {
+	firstTimestamp := messages[0].Timestamp.UnixMilli()
+	maxTimestamp := firstTimestamp
+	for _, message := range messages {
+		if ts := message.Timestamp.UnixMilli(); ts > maxTimestamp {
+			maxTimestamp = ts
+		}
+	}
+
+	records := &encoder{}
+	for i, message := range messages {
+		record := &encoder{}
+		record.int8(0) // attributes
+		record.varint(message.Timestamp.UnixMilli() - firstTimestamp)
+		record.varint(int64(i)) // offset delta
+		record.varBytes(message.Key)
+		record.varBytes(message.Value)
+		record.varint(0) // headers
+		records.varint(int64(len(record.buf)))
+		records.buf = append(records.buf, record.buf...)
+	}
+
+	// the fields covered by the crc
+	body := &encoder{}
+	body.int16(0) // attributes, no compression
+	body.int32(int32(len(messages) - 1))
+	body.int64(firstTimestamp)
+	body.int64(maxTimestamp)
+	body.int64(-1) // producer id
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(int32(len(messages)))
+	body.buf = append(body.buf, records.buf...)
+
+	batch := &encoder{}
+	batch.int64(0) // base offset
+	// the length of the partition leader epoch, magic, crc and body
+	batch.int32(int32(4 + 1 + 4 + len(body.buf)))
+	batch.int32(-1) // partition leader epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(body.buf, crc32c)))
+	batch.buf = append(batch.buf, body.buf...)
+	return batch.buf
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = xerror.New(xerror.Normal, "the response is truncated")
+		return nil
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) int16() int16 {
+	if v := d.take(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if v := d.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.take(int(d.int16())))
+}
+
+// Decode the correlation id and the error code of the only partition in the response.
+func decodeProduceResponse(response []byte) (int32, int16, error) {
+	d := &decoder{buf: response}
+	correlationId := d.int32()
+	if topics := d.int32(); d.err == nil && topics != 1 {
+		return 0, 0, xerror.Errorf(xerror.Normal, "unexpected topics %d in produce response", topics)
+	}
+	d.string() // topic
+	if partitions := d.int32(); d.err == nil && partitions != 1 {
+		return 0, 0, xerror.Errorf(xerror.Normal, "unexpected partitions %d in produce response", partitions)
+	}
+	d.int32() // partition
+	errorCode := d.int16()
+	if d.err != nil {
+		return 0, 0, d.err
+	}
+	return correlationId, errorCode, nil
+}
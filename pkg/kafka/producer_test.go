@@ -0,0 +1,162 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+// under the License
+package kafka
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+)
+
+type producedRecord struct {
+	key, value string
+}
+
+// fakeBroker decodes the produce requests, and replies with the error code.
+type fakeBroker struct {
+	listener  net.Listener
+	errorCode int16
+	records   chan producedRecord
+}
+
+func newFakeBroker(t *testing.T, errorCode int16) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	broker := &fakeBroker{listener: listener, errorCode: errorCode, records: make(chan producedRecord, 16)}
+	go broker.serve(t)
+	return broker
+}
+
+func (b *fakeBroker) addr() string { return b.listener.Addr().String() }
+
+func (b *fakeBroker) serve(t *testing.T) {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		request, err := readResponse(reader)
+		if err != nil {
+			return
+		}
+		d := &decoder{buf: request}
+		apiKey, apiVersion, correlationId := d.int16(), d.int16(), d.int32()
+		d.string()                      // client id
+		d.int16()                       // transactional id
+		d.int16()                       // acks
+		d.int32()                       // timeout
+		d.int32()                       // topics
+		topic := d.string()             // topic
+		d.int32()                       // partitions
+		partition := d.int32()          // partition
+		batch := d.take(int(d.int32())) // record set
+		if d.err != nil || apiKey != apiKeyProduce || apiVersion != apiVersionProduce {
+			t.Errorf("invalid produce request, api key: %d, api version: %d, err: %v", apiKey, apiVersion, d.err)
+			return
+		}
+		b.decodeRecordBatch(t, batch)
+
+		e := &encoder{}
+		e.int32(0)
+		e.int32(correlationId)
+		e.int32(1)
+		e.string(topic)
+		e.int32(1)
+		e.int32(partition)
+		e.int16(b.errorCode)
+		e.int64(0)  // base offset
+		e.int64(-1) // log append time
+		e.int32(0)  // throttle time
+		binary.BigEndian.PutUint32(e.buf[0:4], uint32(len(e.buf)-4))
+		if _, err := conn.Write(e.buf); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) decodeRecordBatch(t *testing.T, batch []byte) {
+	if len(batch) < 61 || batch[16] != 2 {
+		t.Errorf("invalid record batch: %v", batch)
+		return
+	}
+	crc := binary.BigEndian.Uint32(batch[17:21])
+	if crc != crc32.Checksum(batch[21:], crc32c) {
+		t.Errorf("invalid crc of record batch")
+		return
+	}
+	count := int(binary.BigEndian.Uint32(batch[57:61]))
+	records := batch[61:]
+	for i := 0; i < count; i++ {
+		length, n := binary.Varint(records)
+		record := records[n : n+int(length)]
+		records = records[n+int(length):]
+
+		record = record[1:]          // attributes
+		_, n = binary.Varint(record) // timestamp delta
+		record = record[n:]
+		_, n = binary.Varint(record) // offset delta
+		record = record[n:]
+		keyLength, n := binary.Varint(record)
+		key := record[n : n+int(keyLength)]
+		record = record[n+int(keyLength):]
+		valueLength, n := binary.Varint(record)
+		value := record[n : n+int(valueLength)]
+		b.records <- producedRecord{string(key), string(value)}
+	}
+}
+
+func TestProduce(t *testing.T) {
+	follower := newFakeBroker(t, errNotLeaderForPartition)
+	defer follower.listener.Close()
+	leader := newFakeBroker(t, errNone)
+	defer leader.listener.Close()
+
+	producer, err := NewProducer([]string{follower.addr(), leader.addr()}, "ccr_syncer", time.Second)
+	if err != nil {
+		t.Fatalf("new producer failed: %v", err)
+	}
+	defer producer.Close()
+
+	now := time.Now()
+	messages := []Message{
+		{Key: []byte("job"), Value: []byte("event 1"), Timestamp: now},
+		{Key: []byte("job"), Value: []byte("event 2"), Timestamp: now.Add(time.Millisecond)},
+	}
+	if err := producer.Produce("ccr_events", 0, messages); err != nil {
+		t.Fatalf("produce failed: %v", err)
+	}
+
+	for _, message := range messages {
+		select {
+		case record := <-leader.records:
+			if record.key != string(message.Key) || record.value != string(message.Value) {
+				t.Errorf("unexpected record %v, expect %s", record, message.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("the leader does not receive the record %s", message.Value)
+		}
+	}
+}
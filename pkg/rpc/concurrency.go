@@ -36,6 +36,7 @@ type ConcurrencyWindow struct {
 
 	id        int64
 	inflights int64
+	limit     int64 // zero means FlagMaxIngestConcurrencyPerBackend
 }
 
 func newCongestionWindow(id int64) *ConcurrencyWindow {
@@ -48,11 +49,34 @@ func newCongestionWindow(id int64) *ConcurrencyWindow {
 	}
 }
 
+// NewConcurrencyWindow creates a window with the specified limit, instead of the
+// FlagMaxIngestConcurrencyPerBackend.
+func NewConcurrencyWindow(id int64, limit int64) *ConcurrencyWindow {
+	window := newCongestionWindow(id)
+	window.limit = limit
+	return window
+}
+
+func (cw *ConcurrencyWindow) SetLimit(limit int64) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	cw.limit = limit
+	cw.cond.Broadcast()
+}
+
+func (cw *ConcurrencyWindow) maxInflights() int64 {
+	if cw.limit > 0 {
+		return cw.limit
+	}
+	return FlagMaxIngestConcurrencyPerBackend
+}
+
 func (cw *ConcurrencyWindow) Acquire() {
 	cw.mu.Lock()
 	defer cw.mu.Unlock()
 
-	for cw.inflights+1 > FlagMaxIngestConcurrencyPerBackend {
+	for cw.inflights+1 > cw.maxInflights() {
 		cw.cond.Wait()
 	}
 	cw.inflights += 1
@@ -32,7 +32,6 @@ import (
 	"github.com/selectdb/ccr_syncer/pkg/utils"
 	"github.com/selectdb/ccr_syncer/pkg/xerror"
 
-	"github.com/cloudwego/kitex/client"
 	"github.com/cloudwego/kitex/client/callopt"
 	"github.com/cloudwego/kitex/pkg/kerrors"
 	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
@@ -125,7 +124,7 @@ type FeRpc struct {
 
 func NewFeRpc(spec *base.Spec) (*FeRpc, error) {
 	addr := fmt.Sprintf("%s:%s", spec.Host, spec.ThriftPort)
-	client, err := newSingleFeClient(addr)
+	client, err := newSingleFeClient(addr, spec.ThriftTLS)
 	if err != nil {
 		return nil, xerror.Wrapf(err, xerror.RPC, "NewFeClient error: %v", err)
 	}
@@ -141,7 +140,7 @@ func NewFeRpc(spec *base.Spec) (*FeRpc, error) {
 		}
 
 		// for cached all spec clients
-		if client, err := newSingleFeClient(addr); err != nil {
+		if client, err := newSingleFeClient(addr, spec.ThriftTLS); err != nil {
 			log.Warnf("new fe client error: %+v", err)
 		} else {
 			clients[client.Address()] = client
@@ -224,10 +223,27 @@ func (rpc *FeRpc) getCacheFeAddrs() map[string]bool {
 	return utils.CopyMap(rpc.cachedFeAddrs)
 }
 
+// The fe returns NOT_AUTHORIZED or ANALYSIS_ERROR with "Access denied" if the password is wrong.
+func isAuthFailed(status *tstatus.TStatus) bool {
+	if status == nil {
+		return false
+	}
+	if status.GetStatusCode() == tstatus.TStatusCode_NOT_AUTHORIZED {
+		return true
+	}
+	for _, msg := range status.GetErrorMsgs() {
+		if strings.Contains(msg, "Access denied") {
+			return true
+		}
+	}
+	return false
+}
+
 type retryWithMasterRedirectAndCachedClientsRpc struct {
-	rpc            *FeRpc
-	caller         callerType
-	notriedClients map[string]IFeRpc
+	rpc                  *FeRpc
+	caller               callerType
+	notriedClients       map[string]IFeRpc
+	credentialsRefreshed bool
 }
 
 type call0Result struct {
@@ -257,7 +273,14 @@ func (r *retryWithMasterRedirectAndCachedClientsRpc) call0(masterClient IFeRpc)
 		}
 	}
 
-	// Step 2: check need redirect
+	// Step 2: the secrets might be rotated, resolve them again and retry once
+	if isAuthFailed(resp.GetStatus()) && r.rpc.spec.HasSecretRef() && !r.credentialsRefreshed {
+		r.credentialsRefreshed = true
+		r.rpc.spec.RefreshCredentials()
+		return r.call0(masterClient)
+	}
+
+	// Step 3: check need redirect
 	if resp.GetStatus().GetStatusCode() != tstatus.TStatusCode_NOT_MASTER {
 		return &call0Result{
 			canUseNextAddr: false,
@@ -328,7 +351,7 @@ func (r *retryWithMasterRedirectAndCachedClientsRpc) call() (resultType, error)
 		if ok {
 			masterClient = client
 		} else {
-			masterClient, err = newSingleFeClient(masterAddr)
+			masterClient, err = newSingleFeClient(masterAddr, rpc.spec.ThriftTLS)
 			if err != nil {
 				return nil, xerror.Wrapf(err, xerror.RPC, "NewFeClient [%s] error: %v", masterAddr, err)
 			}
@@ -490,11 +513,21 @@ type Request interface {
 	SetDb(*string)
 }
 
+// resolve the user and password of the spec, which might be the secret references
+func credentials(spec *base.Spec) (*string, *string) {
+	user, password, err := spec.Credentials()
+	if err != nil {
+		log.Warnf("resolve the credentials failed, spec: %s, err: %+v", spec, err)
+	}
+	return &user, &password
+}
+
 // set auth info from spec
 func setAuthInfo[T Request](request T, spec *base.Spec) {
 	// set auth info
-	request.SetUser(&spec.User)
-	request.SetPasswd(&spec.Password)
+	user, password := credentials(spec)
+	request.SetUser(user)
+	request.SetPasswd(password)
 	request.SetDb(&spec.Database)
 }
 
@@ -503,9 +536,14 @@ type singleFeClient struct {
 	client feservice.Client
 }
 
-func newSingleFeClient(addr string) (*singleFeClient, error) {
+func newSingleFeClient(addr string, tlsConfig *utils.TLSConfig) (*singleFeClient, error) {
+	options, err := clientOptions(addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	// create kitex FrontendService client
-	if fe_client, err := feservice.NewClient("FrontendService", client.WithHostPorts(addr), client.WithConnectTimeout(connectTimeout), client.WithRPCTimeout(rpcTimeout)); err != nil {
+	if fe_client, err := feservice.NewClient("FrontendService", options...); err != nil {
 		return nil, xerror.Wrapf(err, xerror.RPC, "NewFeClient error: %v, addr: %s", err, addr)
 	} else {
 		return &singleFeClient{
@@ -820,10 +858,11 @@ func (rpc *singleFeClient) GetMasterToken(spec *base.Spec) (*festruct.TGetMaster
 	log.Tracef("Call GetMasterToken, addr: %s, spec: %s", rpc.Address(), spec)
 
 	client := rpc.client
+	user, password := credentials(spec)
 	req := &festruct.TGetMasterTokenRequest{
 		Cluster:  &spec.Cluster,
-		User:     &spec.User,
-		Password: &spec.Password,
+		User:     user,
+		Password: password,
 	}
 
 	log.Tracef("GetMasterToken user: %s", *req.User)
@@ -841,9 +880,10 @@ func (rpc *singleFeClient) getMeta(spec *base.Spec, reqTables []*festruct.TGetMe
 	reqDb.Id = &spec.DbId
 	reqDb.SetTables(reqTables)
 
+	user, password := credentials(spec)
 	req := &festruct.TGetMetaRequest{
-		User:   &spec.User,
-		Passwd: &spec.Password,
+		User:   user,
+		Passwd: password,
 		Db:     reqDb,
 	}
 
@@ -878,10 +918,11 @@ func (rpc *singleFeClient) GetBackends(spec *base.Spec) (*festruct.TGetBackendMe
 	log.Tracef("GetBackends, addr: %s, spec: %s", rpc.Address(), spec)
 
 	client := rpc.client
+	user, password := credentials(spec)
 	req := &festruct.TGetBackendMetaRequest{
 		Cluster: &spec.Cluster,
-		User:    &spec.User,
-		Passwd:  &spec.Password,
+		User:    user,
+		Passwd:  password,
 	}
 
 	if resp, err := client.GetBackendMeta(context.Background(), req); err != nil {
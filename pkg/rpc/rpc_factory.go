@@ -17,14 +17,19 @@
 package rpc
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"sync"
+	"time"
 
 	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
 	beservice "github.com/selectdb/ccr_syncer/pkg/rpc/kitex_gen/backendservice/backendservice"
+	"github.com/selectdb/ccr_syncer/pkg/utils"
 	"github.com/selectdb/ccr_syncer/pkg/xerror"
 
 	"github.com/cloudwego/kitex/client"
+	"github.com/cloudwego/kitex/pkg/remote/trans/gonet"
 )
 
 type IRpcFactory interface {
@@ -81,7 +86,11 @@ func (rf *RpcFactory) NewBeRpc(be *base.Backend) (IBeRpc, error) {
 
 	// create kitex BackendService client
 	addr := fmt.Sprintf("%s:%d", be.Host, be.BePort)
-	client, err := beservice.NewClient("BackendService", client.WithHostPorts(addr), client.WithConnectTimeout(connectTimeout), client.WithRPCTimeout(rpcTimeout))
+	options, err := clientOptions(addr, be.ThriftTLS)
+	if err != nil {
+		return nil, err
+	}
+	client, err := beservice.NewClient("BackendService", options...)
 	if err != nil {
 		return nil, xerror.Wrapf(err, xerror.Normal, "NewBeClient error: %v", err)
 	}
@@ -96,3 +105,42 @@ func (rf *RpcFactory) NewBeRpc(be *base.Backend) (IBeRpc, error) {
 	rf.beRpcs[*be] = beRpc
 	return beRpc, nil
 }
+
+type tlsDialer struct {
+	config *tls.Config
+}
+
+func (d *tlsDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	config := d.config
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, config)
+}
+
+// The options of the kitex clients, the thrift connections are over tls if tlsConfig is not nil.
+func clientOptions(addr string, tlsConfig *utils.TLSConfig) ([]client.Option, error) {
+	options := []client.Option{
+		client.WithHostPorts(addr),
+		client.WithConnectTimeout(connectTimeout),
+		client.WithRPCTimeout(rpcTimeout),
+	}
+	if tlsConfig == nil {
+		return options, nil
+	}
+
+	config, err := tlsConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	// The netpoll transport does not support tls, use the go net transport instead.
+	options = append(options,
+		client.WithTransHandlerFactory(gonet.NewCliTransHandlerFactory()),
+		client.WithDialer(&tlsDialer{config: config}))
+	return options, nil
+}
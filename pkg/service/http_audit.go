@@ -0,0 +1,233 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/storage"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxAuditLogLimit = 1000
+	redactedValue    = "******"
+)
+
+// auditResponseWriter captures the status and the body of the response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	key = strings.NewReplacer("-", "", "_", "").Replace(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "passwd") ||
+		strings.Contains(key, "secret") || strings.Contains(key, "token") ||
+		strings.Contains(key, "authorization") || strings.Contains(key, "cookie") ||
+		strings.Contains(key, "apikey") || strings.Contains(key, "credential")
+}
+
+// The values of the headers, such as the headers of the webhook sinks, are always redacted,
+// since any of them might carry the credentials.
+func isSecretMapKey(key string) bool {
+	return strings.EqualFold(key, "headers")
+}
+
+func redactAll(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, ok := item.(string); ok {
+				v[key] = redactedValue
+			} else {
+				redactAll(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if _, ok := item.(string); ok {
+				v[i] = redactedValue
+			} else {
+				redactAll(item)
+			}
+		}
+	}
+}
+
+// Redact the secrets of the json value in place, the secret references are kept.
+func redactSecrets(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSecretMapKey(key) {
+				redactAll(item)
+			} else if str, ok := item.(string); ok && isSecretKey(key) {
+				if str != "" && !base.IsSecretRef(str) {
+					v[key] = redactedValue
+				}
+			} else {
+				redactSecrets(item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactSecrets(item)
+		}
+	}
+}
+
+// Parse the job name and the redacted request from the request body.
+func parseAuditRequest(body []byte) (string, string) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return "", ""
+	}
+
+	var request interface{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		// the body might contain secrets, do not record it
+		return "", fmt.Sprintf("<invalid json, %d bytes>", len(body))
+	}
+
+	jobName := ""
+	if fields, ok := request.(map[string]interface{}); ok {
+		jobName, _ = fields["name"].(string)
+	}
+	redactSecrets(request)
+	redacted, err := json.Marshal(request)
+	if err != nil {
+		return jobName, fmt.Sprintf("<marshal failed: %v>", err)
+	}
+	return jobName, string(redacted)
+}
+
+// The identity of the caller, the principal of the http auth or the common name of the
+// client cert.
+func (s *HttpService) auditCaller(r *http.Request) string {
+	if len(s.authenticators) > 0 {
+		principal, err := s.authenticate(r)
+		if err != nil {
+			return "unauthenticated"
+		}
+		return fmt.Sprintf("%s(%s)", principal.Name, principal.Role)
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "cert:" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return "anonymous"
+}
+
+// Wrap the handler to record the call in the audit logs, the requests redirected to other
+// syncers are recorded by the syncer which owns the job.
+func (s *HttpService) withAudit(action string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Warnf("read the request body of %s failed: %v", action, err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		aw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(aw, r)
+		if aw.status >= 300 && aw.status < 400 {
+			return
+		}
+
+		var result defaultResult
+		if err := json.Unmarshal(aw.body.Bytes(), &result); err != nil {
+			result.Success = false
+			result.ErrorMsg = fmt.Sprintf("status %d", aw.status)
+		}
+		sourceIp, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			sourceIp = r.RemoteAddr
+		}
+		jobName, request := parseAuditRequest(body)
+		auditLog := &storage.AuditLog{
+			Timestamp: now.UnixMilli(),
+			JobName:   jobName,
+			Action:    action,
+			Caller:    s.auditCaller(r),
+			SourceIp:  sourceIp,
+			Request:   request,
+			Success:   result.Success && aw.status < 300,
+			Result:    result.ErrorMsg,
+		}
+		if err := s.db.AddAuditLog(auditLog); err != nil {
+			log.Errorf("add audit log failed: %+v, action: %s, job: %s, caller: %s",
+				err, action, jobName, auditLog.Caller)
+		}
+	})
+}
+
+func (s *HttpService) auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	log.Infof("get audit logs")
+
+	type result struct {
+		*defaultResult
+		AuditLogs []*storage.AuditLog `json:"audit_logs"`
+	}
+
+	var auditResult *result
+	defer func() { writeJson(w, auditResult) }()
+
+	// Parse the JSON request body, the audit logs are stored in the meta db, so the request is
+	// served by any syncer.
+	var filter storage.AuditLogFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil && err != io.EOF {
+		log.Warnf("get audit logs failed: %+v", err)
+		auditResult = &result{defaultResult: newErrorResult(err.Error())}
+		return
+	}
+	if filter.Limit > maxAuditLogLimit {
+		filter.Limit = maxAuditLogLimit
+	}
+
+	auditLogs, err := s.db.GetAuditLogs(&filter)
+	if err != nil {
+		log.Warnf("get audit logs failed: %+v", err)
+		auditResult = &result{defaultResult: newErrorResult(err.Error())}
+		return
+	}
+
+	auditResult = &result{
+		defaultResult: newSuccessResult(),
+		AuditLogs:     auditLogs,
+	}
+}
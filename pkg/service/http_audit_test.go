@@ -0,0 +1,52 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAuditRequest(t *testing.T) {
+	body := `{
+		"name": "ccr_test",
+		"src": {"host": "localhost", "user": "root", "password": "src_password"},
+		"dest": {"host": "localhost", "user": "root", "password": "env:DORIS_DEST_PW"},
+		"sinks": [
+			{"type": "kafka", "sasl_password": "kafka_password"},
+			{"type": "webhook", "url": "http://hook", "headers": {"X-Signature": "hook_signature"}},
+			{"type": "webhook", "Authorization": "Bearer hook_auth", "Cookie": "hook_cookie", "x-api-key": "hook_api_key"}
+		]
+	}`
+	jobName, request := parseAuditRequest([]byte(body))
+	if jobName != "ccr_test" {
+		t.Fatalf("unexpected job name: %s", jobName)
+	}
+	for _, secret := range []string{"src_password", "kafka_password", "hook_signature", "hook_auth", "hook_cookie", "hook_api_key"} {
+		if strings.Contains(request, secret) {
+			t.Fatalf("the secret %s should be redacted: %s", secret, request)
+		}
+	}
+	if !strings.Contains(request, "env:DORIS_DEST_PW") || !strings.Contains(request, "localhost") ||
+		!strings.Contains(request, "X-Signature") {
+		t.Fatalf("the secret references and other fields should be kept: %s", request)
+	}
+
+	if _, request = parseAuditRequest([]byte(`{"password": "p`)); strings.Contains(request, "password") {
+		t.Fatalf("the invalid json should not be recorded: %s", request)
+	}
+}
@@ -0,0 +1,388 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	redirectTokenParam = "ccr_redirect_token"
+	redirectTokenTTL   = time.Minute
+)
+
+var httpAuthConfigFile string
+
+func init() {
+	flag.StringVar(&httpAuthConfigFile, "http_auth_config", "",
+		"the json file of the tokens, users and roles to access the http api, empty means no authentication")
+}
+
+// Role is the permission level of the http api, a role is allowed to call the apis of the
+// lower roles.
+type Role int
+
+const (
+	RoleNone     Role = 0 // no authentication is required
+	RoleViewer   Role = 1
+	RoleOperator Role = 2
+	RoleAdmin    Role = 3
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleNone:
+		return "none"
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseRole(role string) (Role, error) {
+	switch role {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, xerror.Errorf(xerror.Normal, "unknown role: %s", role)
+	}
+}
+
+func (r *Role) UnmarshalJSON(data []byte) error {
+	var role string
+	if err := json.Unmarshal(data, &role); err != nil {
+		return err
+	}
+	parsed, err := ParseRole(role)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// Principal is the authenticated caller of the http api.
+type Principal struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+
+	// The job of the redirected request, the request is only allowed to access it.
+	redirectJob string
+}
+
+// Authenticator authenticates the http request, returns nil if the request does not carry the
+// credentials of the authenticator, or an error if the credentials are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type AuthToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  Role   `json:"role"`
+}
+
+type AuthUser struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+// HttpAuthConfig is the content of the http auth config file.
+type HttpAuthConfig struct {
+	// The static bearer tokens: "Authorization: Bearer <token>".
+	Tokens []*AuthToken `json:"tokens,omitempty"`
+	// The users of the http basic authentication.
+	Users []*AuthUser `json:"users,omitempty"`
+	// The secret shared by all syncers to sign the redirected requests, so the redirected
+	// requests are authenticated by the syncer which owns the job.
+	RedirectSecret string `json:"redirect_secret,omitempty"`
+}
+
+func loadHttpAuthConfig(path string) (*HttpAuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "read http auth config %s failed", path)
+	}
+
+	var config HttpAuthConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "parse http auth config %s failed", path)
+	}
+	for _, token := range config.Tokens {
+		if token.Token == "" || token.Role == RoleNone {
+			return nil, xerror.Errorf(xerror.Normal, "the token or role of token %s is empty", token.Name)
+		}
+	}
+	for _, user := range config.Users {
+		if user.User == "" || user.Role == RoleNone {
+			return nil, xerror.Errorf(xerror.Normal, "the user or role of user %s is empty", user.User)
+		}
+	}
+	return &config, nil
+}
+
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+type tokenAuthenticator struct {
+	tokens []*AuthToken
+}
+
+func (a *tokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, nil
+	}
+	for _, t := range a.tokens {
+		if secretEqual(strings.TrimSpace(token), t.Token) {
+			return &Principal{Name: t.Name, Role: t.Role}, nil
+		}
+	}
+	return nil, xerror.New(xerror.Normal, "invalid bearer token")
+}
+
+type basicAuthenticator struct {
+	users []*AuthUser
+}
+
+func (a *basicAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	for _, u := range a.users {
+		if u.User == user && secretEqual(password, u.Password) {
+			return &Principal{Name: u.User, Role: u.Role}, nil
+		}
+	}
+	return nil, xerror.Errorf(xerror.Normal, "invalid user or password of %s", user)
+}
+
+// The redirected request carries a token signed by the syncer which redirects it, since the
+// clients might not send the credentials to another host.
+type redirectAuthenticator struct {
+	secret []byte
+}
+
+// The token is bound to the method, path and job of the redirected request, so it can not be
+// replayed to access other apis or jobs.
+type redirectToken struct {
+	Principal
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Job      string `json:"job"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+func (a *redirectAuthenticator) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *redirectAuthenticator) newToken(principal *Principal, method, path, job string, now time.Time) (string, error) {
+	data, err := json.Marshal(&redirectToken{
+		Principal: *principal,
+		Method:    method,
+		Path:      path,
+		Job:       job,
+		ExpireAt:  now.Add(redirectTokenTTL).Unix(),
+	})
+	if err != nil {
+		return "", xerror.Wrap(err, xerror.Normal, "marshal redirect token failed")
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + a.sign(payload), nil
+}
+
+func (a *redirectAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.URL.Query().Get(redirectTokenParam)
+	if token == "" {
+		return nil, nil
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(a.sign(payload))) {
+		return nil, xerror.New(xerror.Normal, "invalid redirect token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "decode redirect token failed")
+	}
+	var parsed redirectToken
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, xerror.Wrap(err, xerror.Normal, "parse redirect token failed")
+	}
+	if time.Now().Unix() > parsed.ExpireAt {
+		return nil, xerror.New(xerror.Normal, "the redirect token is expired")
+	}
+	if parsed.Method != r.Method || parsed.Path != r.URL.Path || parsed.Job == "" {
+		return nil, xerror.Errorf(xerror.Normal, "the redirect token is not issued for %s %s", r.Method, r.URL.Path)
+	}
+
+	principal := parsed.Principal
+	principal.redirectJob = parsed.Job
+	return &principal, nil
+}
+
+type principalKey struct{}
+
+// Get the authenticated caller of the request, nil if the authentication is disabled.
+func requestPrincipal(r *http.Request) *Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Whether the caller has the role, it is always true if the authentication is disabled.
+func hasRole(r *http.Request, role Role) bool {
+	principal := requestPrincipal(r)
+	return principal == nil || principal.Role >= role
+}
+
+func withPrincipal(r *http.Request, principal *Principal) context.Context {
+	return context.WithValue(r.Context(), principalKey{}, principal)
+}
+
+// Load the authenticators from the http auth config, the authentication is disabled if
+// the config is not set.
+func (s *HttpService) initAuth() error {
+	if httpAuthConfigFile == "" {
+		log.Warnf("the http auth config is not set, the http api is not authenticated")
+		return nil
+	}
+
+	config, err := loadHttpAuthConfig(httpAuthConfigFile)
+	if err != nil {
+		return err
+	}
+	s.RegisterAuthenticator(&tokenAuthenticator{tokens: config.Tokens})
+	s.RegisterAuthenticator(&basicAuthenticator{users: config.Users})
+	if config.RedirectSecret != "" {
+		s.redirectAuth = &redirectAuthenticator{secret: []byte(config.RedirectSecret)}
+		s.RegisterAuthenticator(s.redirectAuth)
+	}
+	log.Infof("load http auth config %s, %d tokens, %d users", httpAuthConfigFile, len(config.Tokens), len(config.Users))
+	return nil
+}
+
+// RegisterAuthenticator adds an authenticator, such as a sso or vault-style backend. The http
+// api requires the authentication once any authenticator is registered.
+func (s *HttpService) RegisterAuthenticator(authenticator Authenticator) {
+	s.authenticators = append(s.authenticators, authenticator)
+}
+
+func (s *HttpService) authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range s.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, xerror.New(xerror.Normal, "authentication required")
+}
+
+// Wrap the handler with the authentication and the role check.
+func (s *HttpService) withAuth(role Role, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.authenticators) == 0 || role == RoleNone {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := s.authenticate(r)
+		if err != nil {
+			log.Warnf("authenticate %s from %s failed: %v", r.URL.Path, r.RemoteAddr, err)
+			w.Header().Set("WWW-Authenticate", `Basic realm="ccr_syncer"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJson(w, newErrorResult(err.Error()))
+			return
+		}
+		if principal.Role < role {
+			log.Warnf("%s (%s) is not allowed to access %s, requires %s", principal.Name, principal.Role, r.URL.Path, role)
+			w.WriteHeader(http.StatusForbidden)
+			writeJson(w, newErrorResult("permission denied, requires role "+role.String()))
+			return
+		}
+
+		handler.ServeHTTP(w, r.WithContext(withPrincipal(r, principal)))
+	})
+}
+
+// Sign the redirect url for the authenticated caller, so the syncer owning the job accepts it.
+// Returns false if the url is not signed.
+func (s *HttpService) signRedirectUrl(redirectUrl, jobName string, r *http.Request) (string, bool) {
+	principal := requestPrincipal(r)
+	if s.redirectAuth == nil || principal == nil {
+		return redirectUrl, false
+	}
+
+	token, err := s.redirectAuth.newToken(principal, r.Method, r.URL.Path, jobName, time.Now())
+	if err != nil {
+		log.Warnf("sign the redirect url failed: %+v", err)
+		return redirectUrl, false
+	}
+	parsed, err := url.Parse(redirectUrl)
+	if err != nil {
+		log.Warnf("parse the redirect url %s failed: %+v", redirectUrl, err)
+		return redirectUrl, false
+	}
+	query := parsed.Query()
+	query.Set(redirectTokenParam, token)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), true
+}
+
+// Whether the redirected request is allowed to access the job, the redirect token is only
+// valid for the job it is issued for.
+func isRedirectJobAllowed(r *http.Request, jobName string) bool {
+	principal := requestPrincipal(r)
+	return principal == nil || principal.redirectJob == "" || principal.redirectJob == jobName
+}
@@ -0,0 +1,124 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHttpAuth(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "http_auth.json")
+	config := `{
+		"tokens": [{"name": "monitor", "token": "viewer_token", "role": "viewer"}],
+		"users": [{"user": "ops", "password": "ops_password", "role": "operator"}],
+		"redirect_secret": "shared_secret"
+	}`
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("write http auth config failed: %v", err)
+	}
+	httpAuthConfigFile = configFile
+	defer func() { httpAuthConfigFile = "" }()
+
+	s := NewHttpServer("127.0.0.1", 9190, nil, nil)
+	if err := s.initAuth(); err != nil {
+		t.Fatalf("init auth failed: %v", err)
+	}
+
+	var principal *Principal
+	handler := func(w http.ResponseWriter, r *http.Request) { principal = requestPrincipal(r) }
+	serve := func(role Role, r *http.Request) int {
+		w := httptest.NewRecorder()
+		s.withAuth(role, http.HandlerFunc(handler)).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/get_lag", nil)
+	if code := serve(RoleViewer, r); code != http.StatusUnauthorized {
+		t.Fatalf("the request without credentials should be rejected, code: %d", code)
+	}
+	r.Header.Set("Authorization", "Bearer viewer_token")
+	if code := serve(RoleViewer, r); code != http.StatusOK || principal.Name != "monitor" {
+		t.Fatalf("the viewer should be allowed, code: %d", code)
+	}
+	if code := serve(RoleOperator, r); code != http.StatusForbidden {
+		t.Fatalf("the viewer should not pause the job, code: %d", code)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/pause", nil)
+	r.SetBasicAuth("ops", "wrong_password")
+	if code := serve(RoleOperator, r); code != http.StatusUnauthorized {
+		t.Fatalf("the wrong password should be rejected, code: %d", code)
+	}
+	r.SetBasicAuth("ops", "ops_password")
+	if code := serve(RoleOperator, r); code != http.StatusOK || principal.Role != RoleOperator {
+		t.Fatalf("the operator should be allowed, code: %d", code)
+	}
+	if code := serve(RoleAdmin, r); code != http.StatusForbidden {
+		t.Fatalf("the operator should not delete the job, code: %d", code)
+	}
+
+	// the redirected request is authenticated by the signed token, without the credentials
+	redirectUrl, ok := s.signRedirectUrl("http://127.0.0.1:9191/pause", "job1", r.WithContext(withPrincipal(r, principal)))
+	if !ok {
+		t.Fatalf("the redirect url should be signed")
+	}
+	r = httptest.NewRequest(http.MethodPost, redirectUrl, nil)
+	if code := serve(RoleOperator, r); code != http.StatusOK || principal.Name != "ops" {
+		t.Fatalf("the redirected request should be allowed, code: %d, url: %s", code, redirectUrl)
+	}
+	r = r.WithContext(withPrincipal(r, principal))
+	if !isRedirectJobAllowed(r, "job1") || isRedirectJobAllowed(r, "job2") {
+		t.Fatalf("the redirected request should only access job1")
+	}
+	r = httptest.NewRequest(http.MethodPost, redirectUrl+"x", nil)
+	if code := serve(RoleOperator, r); code != http.StatusUnauthorized {
+		t.Fatalf("the tampered redirect token should be rejected, code: %d", code)
+	}
+	r = httptest.NewRequest(http.MethodGet, redirectUrl, nil)
+	if code := serve(RoleOperator, r); code != http.StatusUnauthorized {
+		t.Fatalf("the redirect token should be bound to the method, code: %d", code)
+	}
+	r = httptest.NewRequest(http.MethodPost, strings.Replace(redirectUrl, "/pause", "/delete", 1), nil)
+	if code := serve(RoleOperator, r); code != http.StatusUnauthorized {
+		t.Fatalf("the redirect token should be bound to the path, code: %d", code)
+	}
+
+	if code := serve(RoleNone, httptest.NewRequest(http.MethodGet, "/version", nil)); code != http.StatusOK {
+		t.Fatalf("the version should not require authentication, code: %d", code)
+	}
+}
+
+func TestVerifyRepairRequiresAdmin(t *testing.T) {
+	s := NewHttpServer("127.0.0.1", 9190, nil, nil)
+	verify := func(principal *Principal, body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader(body))
+		r = r.WithContext(withPrincipal(r, principal))
+		w := httptest.NewRecorder()
+		s.verifyHandler(w, r)
+		return w.Code
+	}
+
+	operator := &Principal{Name: "ops", Role: RoleOperator}
+	if code := verify(operator, `{"name": "ccr_test", "repair": true}`); code != http.StatusForbidden {
+		t.Fatalf("the operator should not repair, code: %d", code)
+	}
+}
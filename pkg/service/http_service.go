@@ -79,6 +79,9 @@ type HttpService struct {
 
 	db         storage.DB
 	jobManager *ccr.JobManager
+
+	authenticators []Authenticator
+	redirectAuth   *redirectAuthenticator
 }
 
 func NewHttpServer(host string, port int, db storage.DB, jobManager *ccr.JobManager) *HttpService {
@@ -101,6 +104,37 @@ type CreateCcrRequest struct {
 	// For table sync, allow to create ccr job even if the target table already exists.
 	AllowTableExists bool `json:"allow_table_exists"`
 	ReuseBinlogLabel bool `json:"reuse_binlog_label"`
+	// Skip the upserts written by the syncer, for the jobs syncing in both directions.
+	Bidirectional bool `json:"bidirectional"`
+	// For db sync, only sync the tables selected by these patterns. A pattern is a glob,
+	// or a regular expression if it has the prefix "regex:".
+	IncludeTables []string `json:"include_tables"`
+	ExcludeTables []string `json:"exclude_tables"`
+	// Sync the explicit tables of the src database in one job, the src/dest table must be empty.
+	Tables []string `json:"tables"`
+	// For db sync, rename the dest tables by a name mapping or a fixed prefix/suffix.
+	TableRename *ccr.TableRename `json:"table_rename"`
+	// The extra destinations, the binlogs of src will be synced to all of them.
+	Dests []base.Spec `json:"dests"`
+	// Sync until the commit seq or the binlog timestamp (in milliseconds), then stop.
+	StopAtCommitSeq int64 `json:"stop_at_commit_seq"`
+	StopAtTimestamp int64 `json:"stop_at_timestamp"`
+	// Limit when and how fast the binlogs are applied to the dest.
+	Schedule *ccr.SyncSchedule `json:"schedule"`
+	// The rules to apply, skip or pause the DDL binlogs.
+	DDLPolicy *ccr.DDLPolicy `json:"ddl_policy"`
+	// Archive the dropped tables and partitions instead of dropping them.
+	SoftDelete *ccr.SoftDelete `json:"soft_delete"`
+	// Export the handled binlogs to the rotating jsonl files.
+	FileSink *ccr.FileSink `json:"file_sink"`
+	// Export the handled binlogs to the sinks, such as webhook and kafka.
+	Sinks []*ccr.SinkConfig `json:"sinks"`
+	// Limit the automatic full syncs, or require an approval before them.
+	FullSyncPolicy *ccr.FullSyncPolicy `json:"full_sync_policy"`
+	// Extend the binlog ttl of src while the job is not running.
+	BinlogLease *ccr.BinlogLease `json:"binlog_lease"`
+	// Only check the request and return the plan of the job, without creating anything.
+	DryRun bool `json:"dry_run"`
 }
 
 // Stringer
@@ -130,17 +164,7 @@ func (s *HttpService) versionHandler(w http.ResponseWriter, r *http.Request) {
 func createCcr(request *CreateCcrRequest, db storage.DB, jobManager *ccr.JobManager) error {
 	log.Infof("create ccr %s", request)
 
-	ctx := &ccr.JobContext{
-		Context:          context.Background(),
-		Src:              request.Src,
-		Dest:             request.Dest,
-		SkipError:        request.SkipError,
-		AllowTableExists: request.AllowTableExists,
-		ReuseBinlogLabel: request.ReuseBinlogLabel,
-		Db:               db,
-		Factory:          jobManager.GetFactory(),
-	}
-	job, err := ccr.NewJobFromService(request.Name, ctx)
+	job, err := newJobFromRequest(request, db, jobManager, false)
 	if err != nil {
 		return err
 	}
@@ -154,6 +178,47 @@ func createCcr(request *CreateCcrRequest, db storage.DB, jobManager *ccr.JobMana
 	return nil
 }
 
+// planCcr checks the request and returns what the job would do, without creating anything.
+func planCcr(request *CreateCcrRequest, db storage.DB, jobManager *ccr.JobManager) (*ccr.JobPlan, error) {
+	log.Infof("plan ccr %s", request)
+
+	job, err := newJobFromRequest(request, db, jobManager, true)
+	if err != nil {
+		return nil, err
+	}
+	return job.Plan()
+}
+
+func newJobFromRequest(request *CreateCcrRequest, db storage.DB, jobManager *ccr.JobManager, dryRun bool) (*ccr.Job, error) {
+	ctx := &ccr.JobContext{
+		Context:          context.Background(),
+		Src:              request.Src,
+		Dest:             request.Dest,
+		SkipError:        request.SkipError,
+		AllowTableExists: request.AllowTableExists,
+		ReuseBinlogLabel: request.ReuseBinlogLabel,
+		Bidirectional:    request.Bidirectional,
+		IncludeTables:    request.IncludeTables,
+		ExcludeTables:    request.ExcludeTables,
+		Tables:           request.Tables,
+		TableRename:      request.TableRename,
+		Dests:            request.Dests,
+		StopAtCommitSeq:  request.StopAtCommitSeq,
+		StopAtTimestamp:  request.StopAtTimestamp,
+		Schedule:         request.Schedule,
+		DDLPolicy:        request.DDLPolicy,
+		SoftDelete:       request.SoftDelete,
+		FileSink:         request.FileSink,
+		Sinks:            request.Sinks,
+		FullSyncPolicy:   request.FullSyncPolicy,
+		BinlogLease:      request.BinlogLease,
+		Db:               db,
+		Factory:          jobManager.GetFactory(),
+		DryRun:           dryRun,
+	}
+	return ccr.NewJobFromService(request.Name, ctx)
+}
+
 // return exit(bool)
 func (s *HttpService) redirect(jobName string, w http.ResponseWriter, r *http.Request) bool {
 	if jobExist, err := s.db.IsJobExist(jobName); err != nil {
@@ -177,22 +242,64 @@ func (s *HttpService) redirect(jobName string, w http.ResponseWriter, r *http.Re
 	}
 
 	if belongHost == s.hostInfo {
+		if !isRedirectJobAllowed(r, jobName) {
+			log.Warnf("the redirect token is not issued for job %s, uri is %s", jobName, r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			writeJson(w, newErrorResult("permission denied, the redirect token is not issued for job "+jobName))
+			return true
+		}
 		return false
 	}
 
 	log.Infof("%s is located in syncer %s, please redirect to %s", jobName, belongHost, belongHost)
-	redirectUrl := fmt.Sprintf("http://%s", belongHost+r.RequestURI)
-	http.Redirect(w, r, redirectUrl, http.StatusSeeOther)
-	log.Infof("the redirect url is %s", redirectUrl)
+	redirectUrl := fmt.Sprintf("%s://%s", httpScheme(), belongHost+r.RequestURI)
+	// Do not log the query, it might carry the redirect token.
+	log.Infof("the redirect url is %s://%s%s", httpScheme(), belongHost, r.URL.Path)
+	if signedUrl, ok := s.signRedirectUrl(redirectUrl, jobName, r); ok {
+		// The signed token is bound to the method, keep the method and body of the request.
+		http.Redirect(w, r, signedUrl, http.StatusTemporaryRedirect)
+	} else {
+		http.Redirect(w, r, redirectUrl, http.StatusSeeOther)
+	}
 	return true
 }
 
+type planCcrResult struct {
+	*defaultResult
+	Creatable bool         `json:"creatable"`
+	Plan      *ccr.JobPlan `json:"plan,omitempty"`
+}
+
+// The dry run of /create_ccr, returns the plan of the job.
+func (s *HttpService) planCcr(request *CreateCcrRequest) *planCcrResult {
+	plan, err := planCcr(request, s.db, s.jobManager)
+	if err != nil {
+		log.Warnf("plan ccr failed: %+v", err)
+		return &planCcrResult{
+			defaultResult: newErrorResult(err.Error()),
+		}
+	}
+
+	return &planCcrResult{
+		defaultResult: newSuccessResult(),
+		Creatable:     plan.IsCreatable(),
+		Plan:          plan,
+	}
+}
+
 // HttpServer serving /create_ccr by json http rpc
 func (s *HttpService) createHandler(w http.ResponseWriter, r *http.Request) {
 	log.Infof("create ccr")
 
 	var createResult *defaultResult
-	defer func() { writeJson(w, createResult) }()
+	var planResult *planCcrResult
+	defer func() {
+		if planResult != nil {
+			writeJson(w, planResult)
+		} else {
+			writeJson(w, createResult)
+		}
+	}()
 
 	// Parse the JSON request body
 	var request CreateCcrRequest
@@ -203,6 +310,11 @@ func (s *HttpService) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if request.DryRun {
+		planResult = s.planCcr(&request)
+		return
+	}
+
 	// Call the createCcr function to create the CCR
 	if err = createCcr(&request, s.db, s.jobManager); err != nil {
 		log.Warnf("create ccr failed: %+v", err)
@@ -580,7 +692,8 @@ func (s *HttpService) jobProgressHandler(w http.ResponseWriter, r *http.Request)
 
 	type result struct {
 		*defaultResult
-		JobProgress ccr.JobProgress `json:"job_progress"`
+		JobProgress  ccr.JobProgress             `json:"job_progress"`
+		DestProgress map[string]*ccr.JobProgress `json:"dest_progress,omitempty"`
 	}
 
 	var jobResult *result
@@ -626,13 +739,39 @@ func (s *HttpService) jobProgressHandler(w http.ResponseWriter, r *http.Request)
 			}
 			return
 		}
+		destProgress, err := getFanoutJobProgress(request.Name, &jobProgress)
+		if err != nil {
+			log.Warnf("get fan-out job progress failed: %+v", err)
+			jobResult = &result{
+				defaultResult: newErrorResult(err.Error()),
+			}
+			return
+		}
 		jobProgress.PersistData = ""
+		jobProgress.DestProgresses = nil
 		jobResult = &result{
 			defaultResult: newSuccessResult(),
 			JobProgress:   jobProgress,
+			DestProgress:  destProgress,
 		}
 	}
+}
 
+// Get the progress of the fan-out jobs, which are saved in the progress of the job.
+func getFanoutJobProgress(jobName string, jobProgress *ccr.JobProgress) (map[string]*ccr.JobProgress, error) {
+	var destProgress map[string]*ccr.JobProgress
+	for index, data := range jobProgress.DestProgresses {
+		var progress ccr.JobProgress
+		if err := json.Unmarshal(data, &progress); err != nil {
+			return nil, xerror.Wrapf(err, xerror.Normal, "unmarshal progress of dest %d failed", index+1)
+		}
+		progress.PersistData = ""
+		if destProgress == nil {
+			destProgress = make(map[string]*ccr.JobProgress)
+		}
+		destProgress[ccr.FanoutJobName(jobName, index)] = &progress
+	}
+	return destProgress, nil
 }
 
 // get job details
@@ -684,6 +823,7 @@ func (s *HttpService) jobDetailHandler(w http.ResponseWriter, r *http.Request) {
 			defaultResult: newErrorResult(err.Error()),
 		}
 	} else {
+		jobDetail.RedactSecrets()
 		jobResult = &result{
 			defaultResult: newSuccessResult(),
 			JobDetail:     &jobDetail,
@@ -848,6 +988,250 @@ func (s *HttpService) skipBinlogHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (s *HttpService) stopAtHandler(w http.ResponseWriter, r *http.Request) {
+	var result *defaultResult
+	defer func() { writeJson(w, result) }()
+
+	// Parse the JSON request body
+	var request struct {
+		CcrCommonRequest
+		StopAtCommitSeq int64 `json:"stop_at_commit_seq"`
+		StopAtTimestamp int64 `json:"stop_at_timestamp"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("update stop at failed: %+v", err)
+		result = newErrorResult(err.Error())
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("update stop at failed: name is empty")
+		result = newErrorResult("name is empty")
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	log.Infof("update stop at of job %s, commit seq %d, timestamp %d",
+		request.Name, request.StopAtCommitSeq, request.StopAtTimestamp)
+	if err := s.jobManager.SetStopAt(request.Name, request.StopAtCommitSeq, request.StopAtTimestamp); err != nil {
+		log.Warnf("update stop at failed: %+v", err)
+		result = newErrorResult(err.Error())
+	} else {
+		result = newSuccessResult()
+	}
+}
+
+func (s *HttpService) updateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var result *defaultResult
+	defer func() { writeJson(w, result) }()
+
+	// Parse the JSON request body
+	var request struct {
+		CcrCommonRequest
+		Schedule *ccr.SyncSchedule `json:"schedule"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("update schedule failed: %+v", err)
+		result = newErrorResult(err.Error())
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("update schedule failed: name is empty")
+		result = newErrorResult("name is empty")
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	log.Infof("update schedule of job %s: %+v", request.Name, request.Schedule)
+	if err := s.jobManager.UpdateSchedule(request.Name, request.Schedule); err != nil {
+		log.Warnf("update schedule failed: %+v", err)
+		result = newErrorResult(err.Error())
+	} else {
+		result = newSuccessResult()
+	}
+}
+
+func (s *HttpService) updateDDLPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	var result *defaultResult
+	defer func() { writeJson(w, result) }()
+
+	// Parse the JSON request body
+	var request struct {
+		CcrCommonRequest
+		DDLPolicy *ccr.DDLPolicy `json:"ddl_policy"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("update ddl policy failed: %+v", err)
+		result = newErrorResult(err.Error())
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("update ddl policy failed: name is empty")
+		result = newErrorResult("name is empty")
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	log.Infof("update ddl policy of job %s: %+v", request.Name, request.DDLPolicy)
+	if err := s.jobManager.UpdateDDLPolicy(request.Name, request.DDLPolicy); err != nil {
+		log.Warnf("update ddl policy failed: %+v", err)
+		result = newErrorResult(err.Error())
+	} else {
+		result = newSuccessResult()
+	}
+}
+
+func (s *HttpService) listPendingApprovalsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Infof("list pending approvals")
+
+	type result struct {
+		*defaultResult
+		Approvals []*ccr.PendingApproval `json:"approvals"`
+	}
+
+	approvals := s.jobManager.ListPendingApprovals()
+	writeJson(w, &result{
+		defaultResult: newSuccessResult(),
+		Approvals:     approvals,
+	})
+}
+
+func (s *HttpService) approveBinlogHandler(w http.ResponseWriter, r *http.Request) {
+	var result *defaultResult
+	defer func() { writeJson(w, result) }()
+
+	// Parse the JSON request body
+	var request struct {
+		CcrCommonRequest
+		CommitSeq int64 `json:"commit_seq"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("approve binlog failed: %+v", err)
+		result = newErrorResult(err.Error())
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("approve binlog failed: name is empty")
+		result = newErrorResult("name is empty")
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	log.Infof("approve binlog %d of job %s", request.CommitSeq, request.Name)
+	if err := s.jobManager.ApproveBinlog(request.Name, request.CommitSeq); err != nil {
+		log.Warnf("approve binlog failed: %+v", err)
+		result = newErrorResult(err.Error())
+	} else {
+		result = newSuccessResult()
+	}
+}
+
+func (s *HttpService) rejectBinlogHandler(w http.ResponseWriter, r *http.Request) {
+	var result *defaultResult
+	defer func() { writeJson(w, result) }()
+
+	// Parse the JSON request body
+	var request struct {
+		CcrCommonRequest
+		CommitSeq int64 `json:"commit_seq"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("reject binlog failed: %+v", err)
+		result = newErrorResult(err.Error())
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("reject binlog failed: name is empty")
+		result = newErrorResult("name is empty")
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	log.Infof("reject binlog %d of job %s", request.CommitSeq, request.Name)
+	if err := s.jobManager.RejectBinlog(request.Name, request.CommitSeq); err != nil {
+		log.Warnf("reject binlog failed: %+v", err)
+		result = newErrorResult(err.Error())
+	} else {
+		result = newSuccessResult()
+	}
+}
+
+func (s *HttpService) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	log.Infof("verify job")
+
+	type result struct {
+		*defaultResult
+		Report *ccr.VerifyReport `json:"report,omitempty"`
+	}
+	var verifyResult *result
+	defer func() { writeJson(w, verifyResult) }()
+
+	// Parse the JSON request body
+	var request struct {
+		CcrCommonRequest
+		ccr.VerifyOptions
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("verify job failed: %+v", err)
+		verifyResult = &result{defaultResult: newErrorResult(err.Error())}
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("verify job failed: name is empty")
+		verifyResult = &result{defaultResult: newErrorResult("name is empty")}
+		return
+	}
+
+	// The repair replaces the dest tables, it requires the same role as desync and delete.
+	if request.Repair && !hasRole(r, RoleAdmin) {
+		log.Warnf("verify job %s failed: repair requires role %s", request.Name, RoleAdmin)
+		w.WriteHeader(http.StatusForbidden)
+		verifyResult = &result{defaultResult: newErrorResult("permission denied, repair requires role " + RoleAdmin.String())}
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	report, err := s.jobManager.Verify(request.Name, &request.VerifyOptions)
+	if err != nil {
+		log.Warnf("verify job %s failed: %+v", request.Name, err)
+		verifyResult = &result{defaultResult: newErrorResult(err.Error())}
+		return
+	}
+	verifyResult = &result{
+		defaultResult: newSuccessResult(),
+		Report:        report,
+	}
+}
+
 func (s *HttpService) failpointHandler(w http.ResponseWriter, r *http.Request) {
 	log.Infof("inject failpoint")
 
@@ -888,34 +1272,103 @@ func (s *HttpService) failpointHandler(w http.ResponseWriter, r *http.Request) {
 	result = newSuccessResult()
 }
 
+func (s *HttpService) approveFullSyncHandler(w http.ResponseWriter, r *http.Request) {
+	log.Infof("approve full sync")
+
+	var approveResult *defaultResult
+	defer func() { writeJson(w, approveResult) }()
+
+	// Parse the JSON request body
+	var request CcrCommonRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Warnf("approve full sync failed: %+v", err)
+
+		approveResult = newErrorResult(err.Error())
+		return
+	}
+
+	if request.Name == "" {
+		log.Warnf("approve full sync failed: name is empty")
+
+		approveResult = newErrorResult("name is empty")
+		return
+	}
+
+	if s.redirect(request.Name, w, r) {
+		return
+	}
+
+	if err = s.jobManager.ApproveFullSync(request.Name); err != nil {
+		log.Warnf("approve full sync of job %s failed: %+v", request.Name, err)
+
+		approveResult = newErrorResult(err.Error())
+		return
+	}
+
+	approveResult = newSuccessResult()
+}
+
+func (s *HttpService) handleFunc(pattern string, role Role, handler http.HandlerFunc) {
+	s.mux.Handle(pattern, s.withAuth(role, handler))
+}
+
+// handle the mutating api, the calls are recorded in the audit logs, including the rejected ones.
+func (s *HttpService) handleAuditedFunc(pattern string, role Role, handler http.HandlerFunc) {
+	s.mux.Handle(pattern, s.withAudit(strings.TrimPrefix(pattern, "/"), s.withAuth(role, handler)))
+}
+
 func (s *HttpService) RegisterHandlers() {
-	s.mux.HandleFunc("/version", s.versionHandler)
-	s.mux.HandleFunc("/create_ccr", s.createHandler)
-	s.mux.HandleFunc("/pause", s.pauseHandler)
-	s.mux.HandleFunc("/resume", s.resumeHandler)
-	s.mux.HandleFunc("/delete", s.deleteHandler)
-	s.mux.HandleFunc("/desync", s.desyncHandler)
-	s.mux.HandleFunc("/get_lag", s.getLagHandler)
-	s.mux.HandleFunc("/list_jobs", s.listJobsHandler)
-	s.mux.HandleFunc("/job_detail", s.jobDetailHandler)
-	s.mux.HandleFunc("/job_status", s.statusHandler)
-	s.mux.HandleFunc("/job_progress", s.jobProgressHandler)
-	s.mux.HandleFunc("/force_fullsync", s.forceFullsyncHandler)
-	s.mux.HandleFunc("/features", s.featuresHandler)
-	s.mux.HandleFunc("/update_host_mapping", s.updateHostMappingHandler)
-	s.mux.HandleFunc("/job_skip_binlog", s.skipBinlogHandler)
-	s.mux.HandleFunc("/failpoint", s.failpointHandler)
-	s.mux.Handle("/metrics", promhttp.Handler())
+	s.handleFunc("/version", RoleNone, s.versionHandler)
+	s.handleAuditedFunc("/create_ccr", RoleAdmin, s.createHandler)
+	s.handleAuditedFunc("/pause", RoleOperator, s.pauseHandler)
+	s.handleAuditedFunc("/resume", RoleOperator, s.resumeHandler)
+	s.handleAuditedFunc("/delete", RoleAdmin, s.deleteHandler)
+	s.handleAuditedFunc("/desync", RoleAdmin, s.desyncHandler)
+	s.handleFunc("/get_lag", RoleViewer, s.getLagHandler)
+	s.handleFunc("/list_jobs", RoleViewer, s.listJobsHandler)
+	s.handleFunc("/job_detail", RoleViewer, s.jobDetailHandler)
+	s.handleFunc("/job_status", RoleViewer, s.statusHandler)
+	s.handleFunc("/job_progress", RoleViewer, s.jobProgressHandler)
+	s.handleAuditedFunc("/force_fullsync", RoleOperator, s.forceFullsyncHandler)
+	s.handleFunc("/features", RoleViewer, s.featuresHandler)
+	s.handleAuditedFunc("/update_host_mapping", RoleAdmin, s.updateHostMappingHandler)
+	s.handleAuditedFunc("/job_skip_binlog", RoleOperator, s.skipBinlogHandler)
+	s.handleAuditedFunc("/update_stop_at", RoleOperator, s.stopAtHandler)
+	s.handleAuditedFunc("/update_schedule", RoleOperator, s.updateScheduleHandler)
+	s.handleAuditedFunc("/update_ddl_policy", RoleOperator, s.updateDDLPolicyHandler)
+	s.handleFunc("/list_pending_approvals", RoleViewer, s.listPendingApprovalsHandler)
+	s.handleAuditedFunc("/approve_binlog", RoleOperator, s.approveBinlogHandler)
+	s.handleAuditedFunc("/reject_binlog", RoleOperator, s.rejectBinlogHandler)
+	s.handleAuditedFunc("/verify", RoleOperator, s.verifyHandler)
+	s.handleAuditedFunc("/approve_fullsync", RoleOperator, s.approveFullSyncHandler)
+	s.handleAuditedFunc("/failpoint", RoleAdmin, s.failpointHandler)
+	s.handleFunc("/audit_log", RoleViewer, s.auditLogHandler)
+	s.mux.Handle("/metrics", s.withAuth(RoleViewer, promhttp.Handler()))
 }
 
 func (s *HttpService) Start() error {
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Infof("Server listening on %s", addr)
 
+	if err := s.initAuth(); err != nil {
+		return err
+	}
 	s.RegisterHandlers()
 
-	s.server = &http.Server{Addr: addr, Handler: s.mux}
-	err := s.server.ListenAndServe()
+	tlsConfig, err := httpServerTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	s.server = &http.Server{Addr: addr, Handler: s.mux, TLSConfig: tlsConfig}
+	if tlsConfig != nil {
+		log.Infof("serve https, client cert required: %t", tlsConfig.ClientCAs != nil)
+		// the cert is loaded into the tls config already
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		err = s.server.ListenAndServe()
+	}
 	if err == nil {
 		return nil
 	} else if err == http.ErrServerClosed {
@@ -0,0 +1,149 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/selectdb/ccr_syncer/pkg/ccr"
+	"github.com/selectdb/ccr_syncer/pkg/ccr/base"
+	"github.com/selectdb/ccr_syncer/pkg/test_util"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestApproveAndRejectBinlog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const hostInfo = "127.0.0.1:9190"
+	db := test_util.NewMockDB(ctrl)
+	jobInfos := make(map[string]string)
+	db.EXPECT().GetJobInfo(gomock.Any()).DoAndReturn(func(name string) (string, error) {
+		return jobInfos[name], nil
+	}).AnyTimes()
+	db.EXPECT().UpdateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(name, info string) error {
+		jobInfos[name] = info
+		return nil
+	}).AnyTimes()
+	db.EXPECT().IsJobExist(gomock.Any()).Return(true, nil).AnyTimes()
+	db.EXPECT().GetJobBelong(gomock.Any()).Return(hostInfo, nil).AnyTimes()
+	// the jobs are not synced in this test, they exit once they are recovered
+	db.EXPECT().IsProgressExist(gomock.Any()).Return(false, errors.New("no progress")).AnyTimes()
+
+	newJobInfo := func(name string, state ccr.JobState, pendingCommitSeq int64) string {
+		job := &ccr.Job{
+			Name:     name,
+			SyncType: ccr.DBSync,
+			Src:      base.Spec{Database: "src_db"},
+			Dest:     base.Spec{Database: "dest_db"},
+			State:    state,
+		}
+		if pendingCommitSeq > 0 {
+			job.Extra.PendingApproval = &ccr.DDLEvent{CommitSeq: pendingCommitSeq, BinlogType: "DROP_TABLE"}
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			t.Fatalf("marshal job failed, err: %v", err)
+		}
+		return string(data)
+	}
+
+	factory := ccr.NewFactory(nil, ccr.NewMetaFactory(), base.NewSpecerFactory(), nil)
+	jobManager := ccr.NewJobManager(db, factory, hostInfo)
+	defer jobManager.Stop()
+	s := NewHttpServer("127.0.0.1", 9190, db, jobManager)
+
+	jobNames := []string{"approve_job", "reject_job", "running_job"}
+	jobInfos["approve_job"] = newJobInfo("approve_job", ccr.JobWaitingApproval, 10)
+	jobInfos["reject_job"] = newJobInfo("reject_job", ccr.JobWaitingApproval, 10)
+	jobInfos["running_job"] = newJobInfo("running_job", ccr.JobRunning, 0)
+	if err := jobManager.Recover(jobNames); err != nil {
+		t.Fatalf("recover jobs failed, err: %v", err)
+	}
+	if approvals := jobManager.ListPendingApprovals(); len(approvals) != 2 {
+		t.Fatalf("expect 2 pending approvals, got %d", len(approvals))
+	}
+
+	decide := func(handler http.HandlerFunc, body string) *defaultResult {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		var result defaultResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("unmarshal result failed, body: %s, err: %v", w.Body.String(), err)
+		}
+		return &result
+	}
+	savedExtra := func(name string) *ccr.JobExtra {
+		var job ccr.Job
+		if err := json.Unmarshal([]byte(jobInfos[name]), &job); err != nil {
+			t.Fatalf("unmarshal job %s failed, err: %v", name, err)
+		}
+		return &job.Extra
+	}
+
+	// the job without the binlog waiting for approval
+	for _, handler := range []http.HandlerFunc{s.approveBinlogHandler, s.rejectBinlogHandler} {
+		if result := decide(handler, `{"name": "running_job", "commit_seq": 10}`); result.Success {
+			t.Fatalf("the running job has no binlog to decide")
+		}
+		if result := decide(handler, `{"name": "approve_job", "commit_seq": 11}`); result.Success {
+			t.Fatalf("the binlog 11 is not waiting for approval")
+		}
+	}
+	if result := decide(s.approveBinlogHandler, `{"commit_seq": 10}`); result.Success {
+		t.Fatalf("the request without name should fail")
+	}
+	if state := newJobInfo("running_job", ccr.JobRunning, 0); jobInfos["running_job"] != state {
+		t.Fatalf("the running job should not be changed, got %s", jobInfos["running_job"])
+	}
+
+	if result := decide(s.approveBinlogHandler, `{"name": "approve_job", "commit_seq": 10}`); !result.Success {
+		t.Fatalf("approve binlog failed, err: %s", result.ErrorMsg)
+	}
+	if extra := savedExtra("approve_job"); extra.ApprovedCommitSeq != 10 || extra.PendingApproval != nil || extra.SkipBinlog {
+		t.Fatalf("the binlog should be approved, extra: %+v", extra)
+	}
+
+	if result := decide(s.rejectBinlogHandler, `{"name": "reject_job", "commit_seq": 10}`); !result.Success {
+		t.Fatalf("reject binlog failed, err: %s", result.ErrorMsg)
+	}
+	if extra := savedExtra("reject_job"); extra.ApprovedCommitSeq != 0 || extra.PendingApproval != nil ||
+		!extra.SkipBinlog || extra.SkipCommitSeq != 10 || extra.SkipBy != ccr.SkipBySilence {
+		t.Fatalf("the binlog should be skipped, extra: %+v", extra)
+	}
+
+	for _, name := range []string{"approve_job", "reject_job"} {
+		if status, err := jobManager.GetJobStatus(name); err != nil || status.State != ccr.JobRunning.String() {
+			t.Fatalf("job %s should be running, status: %+v, err: %v", name, status, err)
+		}
+	}
+	if approvals := jobManager.ListPendingApprovals(); len(approvals) != 0 {
+		t.Fatalf("expect no pending approvals, got %d", len(approvals))
+	}
+
+	// decide again
+	if result := decide(s.approveBinlogHandler, `{"name": "reject_job", "commit_seq": 10}`); result.Success {
+		t.Fatalf("the rejected binlog should not be approved")
+	}
+}
@@ -0,0 +1,63 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package service
+
+import (
+	"crypto/tls"
+	"flag"
+
+	"github.com/selectdb/ccr_syncer/pkg/utils"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+)
+
+var (
+	httpTLSCertFile     string
+	httpTLSKeyFile      string
+	httpTLSClientCAFile string
+)
+
+func init() {
+	flag.StringVar(&httpTLSCertFile, "http_tls_cert", "", "the cert file of the https listener, empty means http")
+	flag.StringVar(&httpTLSKeyFile, "http_tls_key", "", "the key file of the https listener")
+	flag.StringVar(&httpTLSClientCAFile, "http_tls_client_ca", "", "the ca file to verify the client certs of the https listener, empty means no client cert is required")
+}
+
+func httpTLSEnabled() bool {
+	return httpTLSCertFile != ""
+}
+
+// The scheme of the http api, all syncers should use the same tls settings, since the requests
+// are redirected to the syncer which owns the job.
+func httpScheme() string {
+	if httpTLSEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+func httpServerTLSConfig() (*tls.Config, error) {
+	if !httpTLSEnabled() {
+		if httpTLSKeyFile != "" || httpTLSClientCAFile != "" {
+			return nil, xerror.New(xerror.Normal, "the http_tls_cert is required to enable the https listener")
+		}
+		return nil, nil
+	}
+	if httpTLSKeyFile == "" {
+		return nil, xerror.New(xerror.Normal, "the http_tls_key is required to enable the https listener")
+	}
+	return utils.NewServerTLSConfig(httpTLSCertFile, httpTLSKeyFile, httpTLSClientCAFile)
+}
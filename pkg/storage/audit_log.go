@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+)
+
+const defaultAuditLogLimit = 100
+
+// AuditLog records a mutating call of the http api, the audit logs are append-only.
+type AuditLog struct {
+	Id        int64  `json:"id"`
+	Timestamp int64  `json:"timestamp"` // in milliseconds
+	JobName   string `json:"job_name"`
+	Action    string `json:"action"`
+	Caller    string `json:"caller"`
+	SourceIp  string `json:"source_ip"`
+	Request   string `json:"request"` // the request body, the secrets are redacted
+	Success   bool   `json:"success"`
+	Result    string `json:"result,omitempty"` // the error message if failed
+}
+
+// AuditLogFilter filters the audit logs, the zero values mean no limit.
+type AuditLogFilter struct {
+	JobName   string `json:"name"`
+	StartTime int64  `json:"start_time"` // in milliseconds, inclusive
+	EndTime   int64  `json:"end_time"`   // in milliseconds, exclusive
+	Limit     int    `json:"limit"`      // 100 by default
+}
+
+// Build the query of the audit logs, newest first. The placeholder returns the i-th (from 1)
+// placeholder of the args, since postgresql uses $1 rather than ?.
+func (f *AuditLogFilter) query(table string, placeholder func(i int) string) (string, []interface{}) {
+	conditions := make([]string, 0, 3)
+	args := make([]interface{}, 0, 4)
+	if f.JobName != "" {
+		args = append(args, f.JobName)
+		conditions = append(conditions, "job_name = "+placeholder(len(args)))
+	}
+	if f.StartTime > 0 {
+		args = append(args, f.StartTime)
+		conditions = append(conditions, "timestamp >= "+placeholder(len(args)))
+	}
+	if f.EndTime > 0 {
+		args = append(args, f.EndTime)
+		conditions = append(conditions, "timestamp < "+placeholder(len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT id, timestamp, job_name, action, caller, source_ip, request, success, result FROM %s", table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditLogLimit
+	}
+	args = append(args, limit)
+	query += " ORDER BY id DESC LIMIT " + placeholder(len(args))
+	return query, args
+}
+
+func questionPlaceholder(int) string { return "?" }
+
+func dollarPlaceholder(i int) string { return fmt.Sprintf("$%d", i) }
+
+func scanAuditLogs(rows *sql.Rows) ([]*AuditLog, error) {
+	defer rows.Close()
+
+	auditLogs := make([]*AuditLog, 0)
+	for rows.Next() {
+		var auditLog AuditLog
+		if err := rows.Scan(&auditLog.Id, &auditLog.Timestamp, &auditLog.JobName, &auditLog.Action,
+			&auditLog.Caller, &auditLog.SourceIp, &auditLog.Request, &auditLog.Success, &auditLog.Result); err != nil {
+			return nil, xerror.Wrap(err, xerror.DB, "scan audit log failed")
+		}
+		auditLogs = append(auditLogs, &auditLog)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "get audit logs failed")
+	}
+	return auditLogs, nil
+}
@@ -0,0 +1,64 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteAuditLogs(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "ccr.db"))
+	if err != nil {
+		t.Fatalf("new sqlite db failed: %v", err)
+	}
+
+	for i, jobName := range []string{"job_a", "job_b", "job_a"} {
+		auditLog := &AuditLog{
+			Timestamp: int64(1000 * (i + 1)),
+			JobName:   jobName,
+			Action:    "pause",
+			Caller:    "ops(operator)",
+			SourceIp:  "127.0.0.1",
+			Request:   `{"name":"` + jobName + `"}`,
+			Success:   i != 2,
+		}
+		if err := db.AddAuditLog(auditLog); err != nil {
+			t.Fatalf("add audit log failed: %v", err)
+		}
+	}
+
+	auditLogs, err := db.GetAuditLogs(&AuditLogFilter{JobName: "job_a"})
+	if err != nil {
+		t.Fatalf("get audit logs failed: %v", err)
+	}
+	if len(auditLogs) != 2 || auditLogs[0].Timestamp != 3000 || auditLogs[0].Success || !auditLogs[1].Success {
+		t.Fatalf("the audit logs of job_a should be newest first, got %+v", auditLogs)
+	}
+
+	auditLogs, err = db.GetAuditLogs(&AuditLogFilter{StartTime: 2000, EndTime: 3000})
+	if err != nil {
+		t.Fatalf("get audit logs failed: %v", err)
+	}
+	if len(auditLogs) != 1 || auditLogs[0].JobName != "job_b" || auditLogs[0].Caller != "ops(operator)" {
+		t.Fatalf("the audit logs should be filtered by time, got %+v", auditLogs)
+	}
+
+	if auditLogs, _ = db.GetAuditLogs(&AuditLogFilter{Limit: 1}); len(auditLogs) != 1 {
+		t.Fatalf("the audit logs should be limited, got %d", len(auditLogs))
+	}
+}
@@ -56,6 +56,8 @@ type DB interface {
 	GetJobInfo(jobName string) (string, error)
 	// Get job_belong
 	GetJobBelong(jobName string) (string, error)
+	// Get the names of all jobs
+	GetAllJobNames() ([]string, error)
 
 	// Update ccr sync progress
 	UpdateProgress(jobName string, progress string) error
@@ -77,6 +79,11 @@ type DB interface {
 
 	// GetAllData
 	GetAllData() (map[string][]string, error)
+
+	// Append an audit log
+	AddAuditLog(auditLog *AuditLog) error
+	// Get the audit logs, newest first
+	GetAuditLogs(filter *AuditLogFilter) ([]*AuditLog, error)
 }
 
 func SetDBOptions(db *sql.DB) {
@@ -26,6 +26,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/selectdb/ccr_syncer/pkg/utils"
 	"github.com/selectdb/ccr_syncer/pkg/xerror"
 )
 
@@ -38,8 +39,18 @@ type MysqlDB struct {
 	dbName string
 }
 
-func NewMysqlDB(host string, port int, user string, password string, remoteDBName string) (DB, error) {
-	dbForDDL, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/?maxAllowedPacket=%d", user, password, host, port, maxAllowedPacket))
+// NewMysqlDB connects to the mysql meta db, the connections are plaintext if tlsConfig is nil.
+func NewMysqlDB(host string, port int, user string, password string, remoteDBName string, tlsConfig *utils.TLSConfig) (DB, error) {
+	params := fmt.Sprintf("maxAllowedPacket=%d", maxAllowedPacket)
+	if tlsConfig != nil {
+		tlsName, err := tlsConfig.MysqlTLSName()
+		if err != nil {
+			return nil, err
+		}
+		params += "&tls=" + tlsName
+	}
+
+	dbForDDL, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/?%s", user, password, host, port, params))
 	if err != nil {
 		return nil, xerror.Wrapf(err, xerror.DB, "mysql: open %s@tcp(%s:%s) failed", user, host, password)
 	}
@@ -49,7 +60,7 @@ func NewMysqlDB(host string, port int, user string, password string, remoteDBNam
 	}
 	dbForDDL.Close()
 
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?maxAllowedPacket=%d", user, password, host, port, remoteDBName, maxAllowedPacket))
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s", user, password, host, port, remoteDBName, params))
 	if err != nil {
 		return nil, xerror.Wrapf(err, xerror.DB, "mysql: open mysql in db %s@tcp(%s:%d)/%s failed", user, host, port, remoteDBName)
 	}
@@ -68,6 +79,10 @@ func NewMysqlDB(host string, port int, user string, password string, remoteDBNam
 		return nil, xerror.Wrap(err, xerror.DB, "mysql: create table syncers failed")
 	}
 
+	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS audit_logs (`id` BIGINT AUTO_INCREMENT PRIMARY KEY, `timestamp` BIGINT, `job_name` VARCHAR(512), `action` VARCHAR(64), `caller` VARCHAR(256), `source_ip` VARCHAR(64), `request` LONGTEXT, `success` BOOLEAN, `result` TEXT, INDEX idx_job_time (`job_name`, `timestamp`))"); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "mysql: create table audit_logs failed")
+	}
+
 	return &MysqlDB{db: db, dbName: remoteDBName}, nil
 }
 
@@ -169,6 +184,27 @@ func (s *MysqlDB) GetJobBelong(jobName string) (string, error) {
 	return belong, nil
 }
 
+func (s *MysqlDB) GetAllJobNames() ([]string, error) {
+	rows, err := s.db.Query("SELECT job_name FROM jobs")
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "mysql: get all job names failed")
+	}
+	defer rows.Close()
+
+	jobNames := make([]string, 0)
+	for rows.Next() {
+		var jobName string
+		if err := rows.Scan(&jobName); err != nil {
+			return nil, xerror.Wrap(err, xerror.DB, "mysql: scan job name failed")
+		}
+		jobNames = append(jobNames, jobName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "mysql: get all job names failed")
+	}
+	return jobNames, nil
+}
+
 func (s *MysqlDB) UpdateProgress(jobName string, progress string) error {
 	// quoteProgress := strings.ReplaceAll(progress, "\"", "\\\"")
 	encodeProgress := base64.StdEncoding.EncodeToString([]byte(progress))
@@ -447,3 +483,21 @@ func (s *MysqlDB) GetAllData() (map[string][]string, error) {
 
 	return ans, nil
 }
+
+func (s *MysqlDB) AddAuditLog(auditLog *AuditLog) error {
+	query := "INSERT INTO audit_logs (timestamp, job_name, action, caller, source_ip, request, success, result) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	if _, err := s.db.Exec(query, auditLog.Timestamp, auditLog.JobName, auditLog.Action, auditLog.Caller,
+		auditLog.SourceIp, auditLog.Request, auditLog.Success, auditLog.Result); err != nil {
+		return xerror.Wrapf(err, xerror.DB, "mysql: add audit log of job %s failed", auditLog.JobName)
+	}
+	return nil
+}
+
+func (s *MysqlDB) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLog, error) {
+	query, args := filter.query("audit_logs", questionPlaceholder)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "mysql: get audit logs failed")
+	}
+	return scanAuditLogs(rows)
+}
@@ -26,6 +26,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	_ "github.com/lib/pq"
+	"github.com/selectdb/ccr_syncer/pkg/utils"
 	"github.com/selectdb/ccr_syncer/pkg/xerror"
 )
 
@@ -34,8 +35,16 @@ type PostgresqlDB struct {
 	dbName string
 }
 
-func NewPostgresqlDB(host string, port int, user string, password string, remoteDBName string) (DB, error) {
-	url := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", user, password, host, port, "postgres")
+// NewPostgresqlDB connects to the postgresql meta db, the ssl is disabled if tlsConfig is nil.
+func NewPostgresqlDB(host string, port int, user string, password string, remoteDBName string, tlsConfig *utils.TLSConfig) (DB, error) {
+	sslParams := "sslmode=disable"
+	if tlsConfig != nil {
+		if err := tlsConfig.Valid(); err != nil {
+			return nil, err
+		}
+		sslParams = tlsConfig.PostgresqlParams()
+	}
+	url := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?%s", user, password, host, port, "postgres", sslParams)
 	db, err := sql.Open("postgres", url)
 	if err != nil {
 		return nil, xerror.Wrapf(err, xerror.DB, "postgresql: open %s:%d failed", host, port)
@@ -59,6 +68,14 @@ func NewPostgresqlDB(host string, port int, user string, password string, remote
 		return nil, xerror.Wrap(err, xerror.DB, "postgresql: create table syncers failed")
 	}
 
+	if _, err = db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.audit_logs (id BIGSERIAL PRIMARY KEY, timestamp BIGINT, job_name VARCHAR(512), action VARCHAR(64), caller VARCHAR(256), source_ip VARCHAR(64), request TEXT, success BOOLEAN, result TEXT)", remoteDBName)); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "postgresql: create table audit_logs failed")
+	}
+
+	if _, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_audit_logs_job_time ON %s.audit_logs (job_name, timestamp)", remoteDBName)); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "postgresql: create index of audit_logs failed")
+	}
+
 	return &PostgresqlDB{db: db, dbName: remoteDBName}, nil
 }
 
@@ -160,6 +177,27 @@ func (s *PostgresqlDB) GetJobBelong(jobName string) (string, error) {
 	return belong, nil
 }
 
+func (s *PostgresqlDB) GetAllJobNames() ([]string, error) {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT job_name FROM %s.jobs", s.dbName))
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "postgresql: get all job names failed")
+	}
+	defer rows.Close()
+
+	jobNames := make([]string, 0)
+	for rows.Next() {
+		var jobName string
+		if err := rows.Scan(&jobName); err != nil {
+			return nil, xerror.Wrap(err, xerror.DB, "postgresql: scan job name failed")
+		}
+		jobNames = append(jobNames, jobName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "postgresql: get all job names failed")
+	}
+	return jobNames, nil
+}
+
 func (s *PostgresqlDB) UpdateProgress(jobName string, progress string) error {
 	// quoteProgress := strings.ReplaceAll(progress, "\"", "\\\"")
 	encodeProgress := base64.StdEncoding.EncodeToString([]byte(progress))
@@ -445,3 +483,21 @@ func (s *PostgresqlDB) GetAllData() (map[string][]string, error) {
 
 	return ans, nil
 }
+
+func (s *PostgresqlDB) AddAuditLog(auditLog *AuditLog) error {
+	query := fmt.Sprintf("INSERT INTO %s.audit_logs (timestamp, job_name, action, caller, source_ip, request, success, result) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)", s.dbName)
+	if _, err := s.db.Exec(query, auditLog.Timestamp, auditLog.JobName, auditLog.Action, auditLog.Caller,
+		auditLog.SourceIp, auditLog.Request, auditLog.Success, auditLog.Result); err != nil {
+		return xerror.Wrapf(err, xerror.DB, "postgresql: add audit log of job %s failed", auditLog.JobName)
+	}
+	return nil
+}
+
+func (s *PostgresqlDB) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLog, error) {
+	query, args := filter.query(s.dbName+".audit_logs", dollarPlaceholder)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "postgresql: get audit logs failed")
+	}
+	return scanAuditLogs(rows)
+}
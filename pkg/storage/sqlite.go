@@ -53,6 +53,14 @@ func NewSQLiteDB(dbPath string) (DB, error) {
 		return nil, xerror.Wrap(err, xerror.DB, "sqlite: create table syncers failed")
 	}
 
+	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS audit_logs (id INTEGER PRIMARY KEY AUTOINCREMENT, timestamp INTEGER, job_name TEXT, action TEXT, caller TEXT, source_ip TEXT, request TEXT, success BOOLEAN, result TEXT)"); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "sqlite: create table audit_logs failed")
+	}
+
+	if _, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_audit_logs_job_time ON audit_logs (job_name, timestamp)"); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "sqlite: create index of audit_logs failed")
+	}
+
 	return &SQLiteDB{db: db}, nil
 }
 
@@ -153,6 +161,27 @@ func (s *SQLiteDB) GetJobBelong(jobName string) (string, error) {
 	return belong, nil
 }
 
+func (s *SQLiteDB) GetAllJobNames() ([]string, error) {
+	rows, err := s.db.Query("SELECT job_name FROM jobs")
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "sqlite: get all job names failed")
+	}
+	defer rows.Close()
+
+	jobNames := make([]string, 0)
+	for rows.Next() {
+		var jobName string
+		if err := rows.Scan(&jobName); err != nil {
+			return nil, xerror.Wrap(err, xerror.DB, "sqlite: scan job name failed")
+		}
+		jobNames = append(jobNames, jobName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "sqlite: get all job names failed")
+	}
+	return jobNames, nil
+}
+
 func (s *SQLiteDB) UpdateProgress(jobName string, progress string) error {
 	if result, err := s.db.Exec("INSERT INTO progresses VALUES (?, ?) ON CONFLICT (job_name) DO UPDATE SET progress = ?", jobName, progress, progress); err != nil {
 		return xerror.Wrap(err, xerror.DB, "sqlite: update progress failed")
@@ -420,3 +449,21 @@ func (s *SQLiteDB) GetAllData() (map[string][]string, error) {
 
 	return ans, nil
 }
+
+func (s *SQLiteDB) AddAuditLog(auditLog *AuditLog) error {
+	query := "INSERT INTO audit_logs (timestamp, job_name, action, caller, source_ip, request, success, result) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	if _, err := s.db.Exec(query, auditLog.Timestamp, auditLog.JobName, auditLog.Action, auditLog.Caller,
+		auditLog.SourceIp, auditLog.Request, auditLog.Success, auditLog.Result); err != nil {
+		return xerror.Wrapf(err, xerror.DB, "sqlite: add audit log of job %s failed", auditLog.JobName)
+	}
+	return nil
+}
+
+func (s *SQLiteDB) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLog, error) {
+	query, args := filter.query("audit_logs", questionPlaceholder)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, xerror.Wrap(err, xerror.DB, "sqlite: get audit logs failed")
+	}
+	return scanAuditLogs(rows)
+}
@@ -23,6 +23,7 @@ package test_util
 import (
 	reflect "reflect"
 
+	storage "github.com/selectdb/ccr_syncer/pkg/storage"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -49,6 +50,20 @@ func (m *MockDB) EXPECT() *MockDBMockRecorder {
 	return m.recorder
 }
 
+// AddAuditLog mocks base method.
+func (m *MockDB) AddAuditLog(auditLog *storage.AuditLog) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAuditLog", auditLog)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAuditLog indicates an expected call of AddAuditLog.
+func (mr *MockDBMockRecorder) AddAuditLog(auditLog interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAuditLog", reflect.TypeOf((*MockDB)(nil).AddAuditLog), auditLog)
+}
+
 // AddJob mocks base method.
 func (m *MockDB) AddJob(jobName, jobInfo, hostInfo string) error {
 	m.ctrl.T.Helper()
@@ -92,6 +107,36 @@ func (mr *MockDBMockRecorder) GetAllData() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllData", reflect.TypeOf((*MockDB)(nil).GetAllData))
 }
 
+// GetAllJobNames mocks base method.
+func (m *MockDB) GetAllJobNames() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllJobNames")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllJobNames indicates an expected call of GetAllJobNames.
+func (mr *MockDBMockRecorder) GetAllJobNames() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllJobNames", reflect.TypeOf((*MockDB)(nil).GetAllJobNames))
+}
+
+// GetAuditLogs mocks base method.
+func (m *MockDB) GetAuditLogs(filter *storage.AuditLogFilter) ([]*storage.AuditLog, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLogs", filter)
+	ret0, _ := ret[0].([]*storage.AuditLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLogs indicates an expected call of GetAuditLogs.
+func (mr *MockDBMockRecorder) GetAuditLogs(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLogs", reflect.TypeOf((*MockDB)(nil).GetAuditLogs), filter)
+}
+
 // GetDeadSyncers mocks base method.
 func (m *MockDB) GetDeadSyncers(expiredTime int64) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,158 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/selectdb/ccr_syncer/pkg/xerror"
+)
+
+// TLSConfig is the tls config of the connections to a server, the paths of the CA, cert and
+// key files are in PEM format.
+type TLSConfig struct {
+	// The CA to verify the server certificate, the system CAs are used if it is empty.
+	CA string `json:"ca,omitempty"`
+	// The client certificate and key, for the mutual tls.
+	Cert string `json:"cert,omitempty"`
+	Key  string `json:"key,omitempty"`
+	// The server name to verify the server certificate, the host is used if it is empty.
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+func (c *TLSConfig) Valid() error {
+	_, err := c.ClientConfig()
+	return err
+}
+
+func (c *TLSConfig) String() string {
+	return fmt.Sprintf("TLSConfig{CA: %s, Cert: %s, Key: %s, ServerName: %s, InsecureSkipVerify: %t}",
+		c.CA, c.Cert, c.Key, c.ServerName, c.InsecureSkipVerify)
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "read ca file %s failed", caFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, xerror.Errorf(xerror.Normal, "no certificate is found in ca file %s", caFile)
+	}
+	return pool, nil
+}
+
+// ClientConfig builds the tls config of the client side.
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if (c.Cert == "") != (c.Key == "") {
+		return nil, xerror.New(xerror.Normal, "the tls cert and key should be set together")
+	}
+
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CA != "" {
+		pool, err := loadCertPool(c.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if c.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, xerror.Wrapf(err, xerror.Normal, "load tls cert %s and key %s failed", c.Cert, c.Key)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+var registeredMysqlTLS sync.Map // name -> bool
+
+// MysqlTLSName registers the tls config to the mysql driver, returns the name used by the
+// `tls` param of the DSN.
+func (c *TLSConfig) MysqlTLSName() (string, error) {
+	hash := sha256.Sum256([]byte(c.String()))
+	name := "ccr_" + hex.EncodeToString(hash[:8])
+	if _, ok := registeredMysqlTLS.Load(name); ok {
+		return name, nil
+	}
+
+	config, err := c.ClientConfig()
+	if err != nil {
+		return "", err
+	}
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", xerror.Wrapf(err, xerror.Normal, "register mysql tls config %s failed", name)
+	}
+	registeredMysqlTLS.Store(name, true)
+	return name, nil
+}
+
+// PostgresqlParams returns the ssl params of the postgresql connection url.
+func (c *TLSConfig) PostgresqlParams() string {
+	params := url.Values{}
+	switch {
+	case c.InsecureSkipVerify:
+		params.Set("sslmode", "require")
+	case c.CA != "":
+		params.Set("sslmode", "verify-full")
+		params.Set("sslrootcert", c.CA)
+	default:
+		params.Set("sslmode", "verify-full")
+	}
+	if c.Cert != "" {
+		params.Set("sslcert", c.Cert)
+		params.Set("sslkey", c.Key)
+	}
+	return params.Encode()
+}
+
+// NewServerTLSConfig builds the tls config of the server side, the client certificates are
+// required and verified by the client CA if it is not empty.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, xerror.Wrapf(err, xerror.Normal, "load tls cert %s and key %s failed", certFile, keyFile)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
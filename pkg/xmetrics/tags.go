@@ -84,6 +84,21 @@ func (j *jobMetrics) HandledBinlogNum() IMetricsTag {
 	return j
 }
 
+func (j *jobMetrics) SchemaDriftTables() IMetricsTag {
+	j.tags = append(j.tags, "schemaDriftTables")
+	return j
+}
+
+func (j *jobMetrics) BinlogLag() IMetricsTag {
+	j.tags = append(j.tags, "binlogLag")
+	return j
+}
+
+func (j *jobMetrics) BinlogRetentionRatio() IMetricsTag {
+	j.tags = append(j.tags, "binlogRetentionRatio")
+	return j
+}
+
 // error metrics
 type errorMetrics struct {
 	metricsTag
@@ -60,3 +60,12 @@ func ConsumeBinlog(jobName string, commitSeq int64) {
 
 	metrics.IncrCounter(DashboardMetrics().BinlogNum().Tag(), 1)
 }
+
+func SchemaDrift(jobName string, tables int) {
+	metrics.SetGauge(JobMetrics(jobName).SchemaDriftTables().Tag(), float32(tables))
+}
+
+func BinlogRetention(jobName string, lag int64, ratio float64) {
+	metrics.SetGauge(JobMetrics(jobName).BinlogLag().Tag(), float32(lag))
+	metrics.SetGauge(JobMetrics(jobName).BinlogRetentionRatio().Tag(), float32(ratio))
+}